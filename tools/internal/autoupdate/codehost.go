@@ -0,0 +1,62 @@
+package autoupdate
+
+import (
+	"context"
+	"strings"
+)
+
+// CodeHost is the service that hosts the artifact-mirror repository, and that
+// autoupdate opens pull requests against. It exists so that autoupdate is not
+// tied to any one service, and so that it can be tested without one.
+type CodeHost interface {
+	// ListPullRequests returns all pull requests, in any state, that merge
+	// headBranch into baseBranch.
+	ListPullRequests(ctx context.Context, headBranch, baseBranch string) ([]PullRequest, error)
+	// ListOpenPullRequests returns the open pull requests into baseBranch
+	// whose head branch starts with headBranchPrefix.
+	ListOpenPullRequests(ctx context.Context, headBranchPrefix, baseBranch string) ([]PullRequest, error)
+	// CreatePullRequest opens a new pull request.
+	CreatePullRequest(ctx context.Context, newPullRequest NewPullRequest) (PullRequest, error)
+	// ValidateReviewers checks, without making any requests, that
+	// reviewers can be requested by RequestReviewers. It is used to catch
+	// bad reviewers before a pull request is opened.
+	ValidateReviewers(reviewers []string) error
+	// RequestReviewers requests reviews on an existing pull request. Each
+	// reviewer is either a username or a team in the format org/team.
+	RequestReviewers(ctx context.Context, number int, reviewers []string) error
+	// AddLabels adds labels to an existing pull request.
+	AddLabels(ctx context.Context, number int, labels []string) error
+}
+
+// PullRequest is a pull request as returned by a CodeHost.
+type PullRequest struct {
+	Number     int
+	URL        string
+	HeadBranch string
+	BaseBranch string
+	Title      string
+	Body       string
+}
+
+// NewPullRequest contains the information needed to open a pull request.
+type NewPullRequest struct {
+	HeadBranch string
+	BaseBranch string
+	Title      string
+	Body       string
+}
+
+// splitReviewers separates reviewers in the format used by
+// ConfigEntry.Reviewers into users and teams. Teams keep their org prefix.
+func splitReviewers(reviewers []string) ([]string, []string) {
+	users := make([]string, 0, len(reviewers))
+	teams := make([]string, 0)
+	for _, reviewer := range reviewers {
+		if strings.Contains(reviewer, "/") {
+			teams = append(teams, reviewer)
+		} else {
+			users = append(users, reviewer)
+		}
+	}
+	return users, teams
+}
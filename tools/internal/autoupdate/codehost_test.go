@@ -0,0 +1,159 @@
+package autoupdate
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-github/v80/github"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGitHub(t *testing.T) {
+	newGitHub := func(t *testing.T, handler http.Handler) *GitHub {
+		t.Helper()
+		server := httptest.NewServer(handler)
+		t.Cleanup(server.Close)
+		client := github.NewClient(nil)
+		baseURL, err := url.Parse(server.URL + "/")
+		assert.NoError(t, err)
+		client.BaseURL = baseURL
+		return NewGitHub(client, "test-owner", "test-repo")
+	}
+
+	t.Run("ListPullRequests should filter by owner-qualified head branch", func(t *testing.T) {
+		mux := http.NewServeMux()
+		mux.HandleFunc("GET /repos/test-owner/test-repo/pulls", func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "test-owner:autoupdate/test/abcd", r.URL.Query().Get("head"))
+			assert.Equal(t, "master", r.URL.Query().Get("base"))
+			assert.Equal(t, "all", r.URL.Query().Get("state"))
+			_, _ = w.Write([]byte(`[{"number": 3, "html_url": "https://github.com/test-owner/test-repo/pull/3", "head": {"ref": "autoupdate/test/abcd"}, "base": {"ref": "master"}}]`))
+		})
+		gh := newGitHub(t, mux)
+
+		pullRequests, err := gh.ListPullRequests(t.Context(), "autoupdate/test/abcd", "master")
+		assert.NoError(t, err)
+		assert.Equal(t, []PullRequest{{
+			Number:     3,
+			URL:        "https://github.com/test-owner/test-repo/pull/3",
+			HeadBranch: "autoupdate/test/abcd",
+			BaseBranch: "master",
+		}}, pullRequests)
+	})
+
+	t.Run("ListOpenPullRequests should filter by head branch prefix across pages", func(t *testing.T) {
+		mux := http.NewServeMux()
+		mux.HandleFunc("GET /repos/test-owner/test-repo/pulls", func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "master", r.URL.Query().Get("base"))
+			assert.Equal(t, "open", r.URL.Query().Get("state"))
+			if r.URL.Query().Get("page") == "" {
+				w.Header().Set("Link", `<http://`+r.Host+r.URL.Path+`?page=2>; rel="next"`)
+				_, _ = w.Write([]byte(`[{"number": 1, "head": {"ref": "autoupdate/other/abcd"}, "base": {"ref": "master"}}]`))
+				return
+			}
+			_, _ = w.Write([]byte(`[{"number": 2, "head": {"ref": "autoupdate/test/abcd"}, "base": {"ref": "master"}}]`))
+		})
+		gh := newGitHub(t, mux)
+
+		pullRequests, err := gh.ListOpenPullRequests(t.Context(), "autoupdate/test/", "master")
+		assert.NoError(t, err)
+		assert.Equal(t, []PullRequest{{
+			Number:     2,
+			HeadBranch: "autoupdate/test/abcd",
+			BaseBranch: "master",
+		}}, pullRequests)
+	})
+
+	t.Run("RequestReviewers should split users and teams", func(t *testing.T) {
+		mux := http.NewServeMux()
+		mux.HandleFunc("POST /repos/test-owner/test-repo/pulls/3/requested_reviewers", func(w http.ResponseWriter, r *http.Request) {
+			var request github.ReviewersRequest
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+			assert.Equal(t, []string{"user"}, request.Reviewers)
+			assert.Equal(t, []string{"team"}, request.TeamReviewers)
+			_, _ = w.Write([]byte(`{"number": 3}`))
+		})
+		gh := newGitHub(t, mux)
+
+		err := gh.RequestReviewers(t.Context(), 3, []string{"user", "test-owner/team"})
+		assert.NoError(t, err)
+	})
+
+	t.Run("RequestReviewers should return error for team of another org", func(t *testing.T) {
+		gh := newGitHub(t, http.NewServeMux())
+
+		err := gh.RequestReviewers(t.Context(), 3, []string{"other-org/team"})
+		assert.EqualError(t, err, `team "other-org/team" does not belong to test-owner`)
+	})
+
+	t.Run("ValidateReviewers should only accept teams of the owner", func(t *testing.T) {
+		gh := newGitHub(t, http.NewServeMux())
+
+		assert.NoError(t, gh.ValidateReviewers([]string{"user", "test-owner/team"}))
+		assert.EqualError(t, gh.ValidateReviewers([]string{"user", "other-org/team"}), `team "other-org/team" does not belong to test-owner`)
+	})
+}
+
+func TestGitLab(t *testing.T) {
+	newGitLab := func(t *testing.T, handler http.Handler) *GitLab {
+		t.Helper()
+		server := httptest.NewServer(handler)
+		t.Cleanup(server.Close)
+		return NewGitLab(server.URL+"/api/v4", "test-group/test-project", "test-token")
+	}
+
+	t.Run("CreatePullRequest should create a merge request", func(t *testing.T) {
+		mux := http.NewServeMux()
+		mux.HandleFunc("POST /api/v4/projects/{project}/merge_requests", func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "test-group/test-project", r.PathValue("project"))
+			assert.Equal(t, "test-token", r.Header.Get("PRIVATE-TOKEN"))
+			var payload map[string]any
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+			assert.Equal(t, "autoupdate/test/abcd", payload["source_branch"])
+			assert.Equal(t, "master", payload["target_branch"])
+			_, _ = w.Write([]byte(`{"iid": 5, "web_url": "https://gitlab.example/mr/5", "source_branch": "autoupdate/test/abcd", "target_branch": "master", "title": "title"}`))
+		})
+		gl := newGitLab(t, mux)
+
+		pullRequest, err := gl.CreatePullRequest(t.Context(), NewPullRequest{
+			HeadBranch: "autoupdate/test/abcd",
+			BaseBranch: "master",
+			Title:      "title",
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, PullRequest{
+			Number:     5,
+			URL:        "https://gitlab.example/mr/5",
+			HeadBranch: "autoupdate/test/abcd",
+			BaseBranch: "master",
+			Title:      "title",
+		}, pullRequest)
+	})
+
+	t.Run("RequestReviewers should look up user IDs", func(t *testing.T) {
+		mux := http.NewServeMux()
+		mux.HandleFunc("GET /api/v4/users", func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "user", r.URL.Query().Get("username"))
+			_, _ = w.Write([]byte(`[{"id": 42}]`))
+		})
+		mux.HandleFunc("PUT /api/v4/projects/{project}/merge_requests/5", func(w http.ResponseWriter, r *http.Request) {
+			var payload map[string]any
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+			assert.Equal(t, []any{float64(42)}, payload["reviewer_ids"])
+			_, _ = w.Write([]byte(`{}`))
+		})
+		gl := newGitLab(t, mux)
+
+		err := gl.RequestReviewers(t.Context(), 5, []string{"user"})
+		assert.NoError(t, err)
+	})
+
+	t.Run("RequestReviewers should return error for teams", func(t *testing.T) {
+		gl := newGitLab(t, http.NewServeMux())
+
+		err := gl.RequestReviewers(t.Context(), 5, []string{"org/team"})
+		assert.EqualError(t, err, "GitLab does not support team reviewers: org/team")
+	})
+}
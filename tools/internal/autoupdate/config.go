@@ -17,25 +17,54 @@ import (
 	"github.com/rancher/artifact-mirror/internal/paths"
 	"github.com/rancher/artifact-mirror/internal/regsync"
 
-	"github.com/google/go-github/v80/github"
 	"sigs.k8s.io/yaml"
 )
 
 type ConfigEntry struct {
 	Name          string
+	GitTag        *GitTag        `json:",omitempty"`
 	GithubRelease *GithubRelease `json:",omitempty"`
+	HelmChart     *HelmChart     `json:",omitempty"`
 	HelmLatest    *HelmLatest    `json:",omitempty"`
+	Manifest      *Manifest      `json:",omitempty"`
 	Registry      *Registry      `json:",omitempty"`
-	Reviewers     []string       `json:",omitempty"`
+	ReleaseAsset  *ReleaseAsset  `json:",omitempty"`
+	// Labels are added to pull requests created for this entry.
+	Labels []string `json:",omitempty"`
+	// MaxTagsPerPullRequest limits the number of tags added by a single
+	// pull request. Updates with more tags are split into several pull
+	// requests, starting with the newest tags. 0 means no limit.
+	MaxTagsPerPullRequest int `json:",omitempty"`
+	// MaxPullRequests limits the number of pull requests of the entry that
+	// are open at once. The tags that do not fit are held back
+	// and proposed in a later run, once some of the open pull requests are
+	// merged or closed. 0 means no limit.
+	MaxPullRequests int      `json:",omitempty"`
+	Reviewers       []string `json:",omitempty"`
+	// Versions selects which of the tags found by the update strategy
+	// are proposed. It works the same way for every update strategy, and
+	// must not repeat the VersionConstraint, LatestOnly or Latest of the
+	// update strategy.
+	Versions *VersionPolicy `json:",omitempty"`
+	// MinAge holds back tags until they were published at least this long
+	// ago, e.g. "72h". Deferred tags are proposed in a later run. It is not
+	// supported by the GitTag and HelmLatest update strategies.
+	MinAge *Duration `json:",omitempty"`
 }
 
 type AutoUpdateOptions struct {
-	BaseBranch   string
-	ConfigYaml   *config.Config
-	DryRun       bool
-	GithubOwner  string
-	GithubRepo   string
-	GithubClient *github.Client
+	BaseBranch string
+	ConfigYaml *config.Config
+	DryRun     bool
+	CodeHost   CodeHost
+	// TagResolver, if set, is used to check that each proposed tag can be
+	// pulled. Tags that cannot be pulled are left out of the pull request.
+	TagResolver TagResolver
+	// Credentials, if set, are used by update strategies to authenticate
+	// to registries.
+	Credentials *RegistryCredentials
+	// EntryTimeout, if greater than 0, limits the time that Run may take.
+	EntryTimeout time.Duration
 }
 
 // AutoupdateArtifactRef is used to map a given update artifact to an entry in config.yaml.
@@ -88,15 +117,27 @@ func (entry ConfigEntry) Validate() error {
 		return errors.New("must specify Name")
 	}
 	count := 0
+	if entry.GitTag != nil {
+		count++
+	}
 	if entry.GithubRelease != nil {
 		count++
 	}
+	if entry.HelmChart != nil {
+		count++
+	}
 	if entry.HelmLatest != nil {
 		count++
 	}
+	if entry.Manifest != nil {
+		count++
+	}
 	if entry.Registry != nil {
 		count++
 	}
+	if entry.ReleaseAsset != nil {
+		count++
+	}
 
 	if count > 1 {
 		return errors.New("must specify only one autoupdate strategy")
@@ -105,18 +146,59 @@ func (entry ConfigEntry) Validate() error {
 		return errors.New("must specify an autoupdate strategy")
 	}
 
-	if entry.GithubRelease != nil {
+	if entry.GitTag != nil {
+		if err := entry.GitTag.Validate(); err != nil {
+			return fmt.Errorf("GitTag failed validation: %w", err)
+		}
+	} else if entry.GithubRelease != nil {
 		if err := entry.GithubRelease.Validate(); err != nil {
 			return fmt.Errorf("GithubRelease failed validation: %w", err)
 		}
+	} else if entry.HelmChart != nil {
+		if err := entry.HelmChart.Validate(); err != nil {
+			return fmt.Errorf("HelmChart failed validation: %w", err)
+		}
 	} else if entry.HelmLatest != nil {
 		if err := entry.HelmLatest.Validate(); err != nil {
 			return fmt.Errorf("HelmLatest failed validation: %w", err)
 		}
+	} else if entry.Manifest != nil {
+		if err := entry.Manifest.Validate(); err != nil {
+			return fmt.Errorf("Manifest failed validation: %w", err)
+		}
 	} else if entry.Registry != nil {
 		if err := entry.Registry.Validate(); err != nil {
 			return fmt.Errorf("Registry failed validation: %w", err)
 		}
+	} else if entry.ReleaseAsset != nil {
+		if err := entry.ReleaseAsset.Validate(); err != nil {
+			return fmt.Errorf("ReleaseAsset failed validation: %w", err)
+		}
+	}
+
+	if entry.Versions != nil {
+		if err := entry.Versions.Validate(); err != nil {
+			return fmt.Errorf("Versions failed validation: %w", err)
+		}
+		if err := entry.validateVersions(); err != nil {
+			return err
+		}
+	}
+
+	if entry.MinAge != nil {
+		if err := entry.checkMinAgeSupport(); err != nil {
+			return err
+		}
+		if *entry.MinAge < 0 {
+			return errors.New("MinAge must not be negative")
+		}
+	}
+
+	if entry.MaxTagsPerPullRequest < 0 {
+		return errors.New("MaxTagsPerPullRequest must not be negative")
+	}
+	if entry.MaxPullRequests < 0 {
+		return errors.New("MaxPullRequests must not be negative")
 	}
 
 	if len(entry.Reviewers) == 0 {
@@ -135,29 +217,136 @@ func (entry ConfigEntry) Validate() error {
 	return nil
 }
 
+// UpdateArtifacts is what an update strategy finds.
+type UpdateArtifacts struct {
+	// Artifacts are the artifacts, with the tags that were found.
+	Artifacts []*config.Artifact
+	// PublishTimes are the publish times of the tags of Artifacts, keyed by
+	// "<source artifact>:<tag>", for update strategies that learn them
+	// while finding the tags. Tags whose publish time is unknown are left
+	// out.
+	PublishTimes map[string]time.Time
+	// CreationTimes are the creation times of the tags of Artifacts, keyed
+	// like PublishTimes, for update strategies that order tags by them.
+	CreationTimes map[string]time.Time
+	// UnmappedImages are images that were found, but that are not in the
+	// Artifacts of the update strategy.
+	UnmappedImages []string
+	// SkippedReleases are releases that were left out, to be tried again
+	// in a later run.
+	SkippedReleases []SkippedRelease
+}
+
+// SkippedRelease is a release that an update strategy left out.
+type SkippedRelease struct {
+	Tag    string
+	Reason string
+}
+
 // GetUpdateArtifacts returns a slice of Artifacts that depends on the
 // configured update strategy. The returned Artifacts may be from
 // any source, and they may be gathered in any way. The intention
 // is that they are new Artifacts (or new tags of existing Artifacts) that
 // we want to mirror.
-func (entry ConfigEntry) GetUpdateArtifacts() ([]*config.Artifact, error) {
+func (entry ConfigEntry) GetUpdateArtifacts(ctx context.Context) (UpdateArtifacts, error) {
 	switch {
+	case entry.GitTag != nil:
+		return entry.GitTag.GetUpdateArtifacts(ctx)
 	case entry.GithubRelease != nil:
-		return entry.GithubRelease.GetUpdateArtifacts()
+		return entry.GithubRelease.GetUpdateArtifacts(ctx)
+	case entry.HelmChart != nil:
+		return entry.HelmChart.GetUpdateArtifacts(ctx)
 	case entry.HelmLatest != nil:
-		return entry.HelmLatest.GetUpdateArtifacts()
+		return entry.HelmLatest.GetUpdateArtifacts(ctx)
+	case entry.Manifest != nil:
+		return entry.Manifest.GetUpdateArtifacts(ctx)
 	case entry.Registry != nil:
-		return entry.Registry.GetUpdateArtifacts()
+		return entry.Registry.GetUpdateArtifacts(ctx)
+	case entry.ReleaseAsset != nil:
+		return entry.ReleaseAsset.GetUpdateArtifacts(ctx)
 	default:
-		return nil, errors.New("did not find update strategy")
+		return UpdateArtifacts{}, errors.New("did not find update strategy")
+	}
+}
+
+// setCredentials passes credentials to the update strategies that query
+// registries.
+func (entry ConfigEntry) setCredentials(credentials *RegistryCredentials) {
+	if entry.HelmChart != nil {
+		entry.HelmChart.credentials = credentials
+	}
+	if entry.Registry != nil {
+		entry.Registry.credentials = credentials
+	}
+}
+
+// setVersions passes Versions to the update strategies that use it to
+// avoid fetching releases whose tags would be dropped.
+func (entry ConfigEntry) setVersions() {
+	if entry.Manifest != nil {
+		entry.Manifest.versions = entry.Versions
+	}
+	if entry.ReleaseAsset != nil {
+		entry.ReleaseAsset.versions = entry.Versions
 	}
 }
 
-func (entry ConfigEntry) Run(ctx context.Context, opts AutoUpdateOptions) error {
-	newArtifacts, err := entry.GetUpdateArtifacts()
+// Run finds updates for entry and makes pull requests for them. The
+// returned EntryResult describes what was done, and is also populated
+// when an error is returned.
+func (entry ConfigEntry) Run(ctx context.Context, opts AutoUpdateOptions) (EntryResult, error) {
+	if opts.EntryTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.EntryTimeout)
+		defer cancel()
+	}
+	start := time.Now()
+	result := EntryResult{
+		Name:    entry.Name,
+		Outcome: OutcomeNoUpdates,
+	}
+	err := entry.run(ctx, opts, &result)
+	result.Duration = time.Since(start)
+	if errors.Is(err, context.DeadlineExceeded) && opts.EntryTimeout > 0 {
+		err = fmt.Errorf("timed out after %s: %w", opts.EntryTimeout, err)
+	}
+	if err != nil {
+		result.setOutcome(OutcomeError)
+		result.Error = err.Error()
+	}
+	return result, err
+}
+
+func (entry ConfigEntry) run(ctx context.Context, opts AutoUpdateOptions, result *EntryResult) error {
+	// Reviewers are only requested once the pull request is open, and
+	// existing pull requests are not revisited, so bad reviewers must be
+	// caught before then.
+	if err := opts.CodeHost.ValidateReviewers(entry.Reviewers); err != nil {
+		return fmt.Errorf("invalid reviewers for %s: %w", entry.Name, err)
+	}
+	entry.setCredentials(opts.Credentials)
+	entry.setVersions()
+	updateArtifacts, err := entry.GetUpdateArtifacts(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to get latest artifacts for %s: %w", entry.Name, err)
 	}
+	for _, skippedRelease := range updateArtifacts.SkippedReleases {
+		fmt.Printf("%s: skipping release %s: %s\n", entry.Name, skippedRelease.Tag, skippedRelease.Reason)
+	}
+	result.UnmappedImages = updateArtifacts.UnmappedImages
+	for _, image := range result.UnmappedImages {
+		fmt.Printf("%s: found image %s that is not in Artifacts\n", entry.Name, image)
+	}
+	return entry.runWithArtifacts(ctx, opts, updateArtifacts, result)
+}
+
+// runWithArtifacts does everything that Run does after the update artifacts
+// have been retrieved from the update strategy.
+func (entry ConfigEntry) runWithArtifacts(ctx context.Context, opts AutoUpdateOptions, updateArtifacts UpdateArtifacts, result *EntryResult) error {
+	newArtifacts := updateArtifacts.Artifacts
+	if entry.Versions != nil {
+		newArtifacts = entry.Versions.SelectArtifactTags(newArtifacts, opts.ConfigYaml)
+	}
 
 	accumulator := config.NewArtifactAccumulator()
 	accumulator.AddArtifacts(opts.ConfigYaml.Artifacts...)
@@ -177,37 +366,158 @@ func (entry ConfigEntry) Run(ctx context.Context, opts AutoUpdateOptions) error
 		return nil
 	}
 
+	deferredTags := make([]SkippedTag, 0)
+	if entry.MinAge != nil {
+		var err error
+		artifactsToUpdate, deferredTags, err = entry.deferRecentTags(artifactsToUpdate, updateArtifacts.PublishTimes, time.Now())
+		if err != nil {
+			return fmt.Errorf("failed to apply MinAge: %w", err)
+		}
+		result.DeferredTags = append(result.DeferredTags, deferredTags...)
+		for _, deferredTag := range deferredTags {
+			fmt.Printf("%s: deferring %s:%s: %s\n", entry.Name, deferredTag.SourceArtifact, deferredTag.Tag, deferredTag.Reason)
+		}
+		if len(artifactsToUpdate) == 0 {
+			fmt.Printf("%s: no updates older than MinAge found\n", entry.Name)
+			return nil
+		}
+	}
+
+	skippedTags := make([]SkippedTag, 0)
+	if opts.TagResolver != nil {
+		var err error
+		artifactsToUpdate, skippedTags, err = filterResolvableTags(ctx, opts.TagResolver, artifactsToUpdate)
+		if err != nil {
+			return fmt.Errorf("failed to check that tags can be pulled: %w", err)
+		}
+		result.SkippedTags = append(result.SkippedTags, skippedTags...)
+		for _, skippedTag := range skippedTags {
+			fmt.Printf("%s: skipping %s:%s: %s\n", entry.Name, skippedTag.SourceArtifact, skippedTag.Tag, skippedTag.Reason)
+		}
+		if len(artifactsToUpdate) == 0 {
+			fmt.Printf("%s: no pullable updates found\n", entry.Name)
+			return nil
+		}
+	}
+
+	openPullRequests := make([]PullRequest, 0)
+	if entry.MaxTagsPerPullRequest > 0 {
+		// Split pull requests are made over several runs, so the tags
+		// that earlier runs already proposed are left out.
+		var err error
+		openPullRequests, err = opts.CodeHost.ListOpenPullRequests(ctx, entry.branchPrefix(), opts.BaseBranch)
+		if err != nil {
+			return fmt.Errorf("failed to list pull requests: %w", err)
+		}
+		var openTags []string
+		artifactsToUpdate, openTags = removePullRequestTags(artifactsToUpdate, openPullRequests)
+		result.ProposedTags = append(result.ProposedTags, openTags...)
+		for _, pullRequest := range openPullRequests {
+			result.PullRequestURLs = append(result.PullRequestURLs, pullRequest.URL)
+			result.setOutcome(OutcomeExistingPullRequest)
+		}
+		if len(artifactsToUpdate) == 0 {
+			fmt.Printf("%s: all updates are in open pull requests\n", entry.Name)
+			return nil
+		}
+	}
+
+	groups, err := splitArtifacts(artifactsToUpdate, entry.MaxTagsPerPullRequest, entry.tagOrdering(), updateArtifacts.CreationTimes)
+	if err != nil {
+		return fmt.Errorf("failed to split artifacts: %w", err)
+	}
+	heldBackTags := make([]SkippedTag, 0)
+	if entry.MaxPullRequests > 0 {
+		groups, heldBackTags = entry.limitPullRequests(groups, len(openPullRequests))
+		result.DeferredTags = append(result.DeferredTags, heldBackTags...)
+		for _, heldBackTag := range heldBackTags {
+			fmt.Printf("%s: deferring %s:%s: %s\n", entry.Name, heldBackTag.SourceArtifact, heldBackTag.Tag, heldBackTag.Reason)
+		}
+	}
+
+	errs := make([]error, 0)
+	for i, group := range groups {
+		// The tags that are left out are only listed in the first pull
+		// request, rather than repeated in each of them.
+		if i == 1 {
+			skippedTags, deferredTags, heldBackTags = nil, nil, nil
+		}
+		if err := entry.proposeUpdate(ctx, opts, group, skippedTags, deferredTags, heldBackTags, result); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// limitPullRequests keeps the first of groups that fit in MaxPullRequests
+// alongside openPullRequests, the number of pull requests of entry that are
+// already open, and returns the tags of the others as held back tags.
+func (entry ConfigEntry) limitPullRequests(groups [][]*config.Artifact, openPullRequests int) ([][]*config.Artifact, []SkippedTag) {
+	free := max(entry.MaxPullRequests-openPullRequests, 0)
+	if len(groups) <= free {
+		return groups, nil
+	}
+	heldBackTags := make([]SkippedTag, 0)
+	for _, group := range groups[free:] {
+		for _, artifact := range group {
+			for _, tag := range artifact.Tags {
+				heldBackTags = append(heldBackTags, SkippedTag{
+					SourceArtifact: artifact.SourceArtifact,
+					Tag:            tag,
+					Reason:         fmt.Sprintf("held back to keep at most MaxPullRequests (%d) pull requests open", entry.MaxPullRequests),
+				})
+			}
+		}
+	}
+	return groups[:free], heldBackTags
+}
+
+// branchPrefix returns the prefix of the branches of the pull requests made
+// for entry.
+func (entry ConfigEntry) branchPrefix() string {
+	return fmt.Sprintf("autoupdate/%s/", entry.Name)
+}
+
+// branchName returns the name of the branch of the pull request that adds
+// artifactsToUpdate.
+func (entry ConfigEntry) branchName(artifactsToUpdate []*config.Artifact) (string, error) {
 	artifactSetHash, err := hashArtifactSet(artifactsToUpdate)
 	if err != nil {
-		return fmt.Errorf("failed to hash set of artifacts that need updates: %w", err)
-	}
-	branchName := fmt.Sprintf("autoupdate/%s/%s", entry.Name, artifactSetHash)
-
-	// When filtering pull requests by head branch, the github API
-	// requires that the head branch is in the format <owner>:<branch>.
-	// In the case of branches pushed using GITHUB_TOKEN in rancher/artifact-mirror,
-	// owner is "rancher". When running in a personal repo, setting GITHUB_TOKEN
-	// to a PAT makes owner the same as the user's github username.
-	headBranch := opts.GithubOwner + ":" + branchName
-	requestContext, cancel := context.WithTimeout(ctx, 10*time.Second)
-	defer cancel()
-	pullRequests, _, err := opts.GithubClient.PullRequests.List(requestContext, opts.GithubOwner, opts.GithubRepo, &github.PullRequestListOptions{
-		Head:  headBranch,
-		Base:  opts.BaseBranch,
-		State: "all",
-	})
+		return "", fmt.Errorf("failed to hash set of artifacts that need updates: %w", err)
+	}
+	return entry.branchPrefix() + artifactSetHash, nil
+}
+
+// proposeUpdate makes a pull request that adds artifactsToUpdate, unless
+// one already exists.
+func (entry ConfigEntry) proposeUpdate(ctx context.Context, opts AutoUpdateOptions, artifactsToUpdate []*config.Artifact, skippedTags, deferredTags, heldBackTags []SkippedTag, result *EntryResult) error {
+	for _, artifactToUpdate := range artifactsToUpdate {
+		result.ProposedTags = append(result.ProposedTags, artifactToUpdate.CombineSourceArtifactAndTags()...)
+	}
+
+	branchName, err := entry.branchName(artifactsToUpdate)
+	if err != nil {
+		return err
+	}
+
+	pullRequests, err := opts.CodeHost.ListPullRequests(ctx, branchName, opts.BaseBranch)
 	if err != nil {
 		return fmt.Errorf("failed to list pull requests: %w", err)
 	}
+	for _, pullRequest := range pullRequests {
+		result.PullRequestURLs = append(result.PullRequestURLs, pullRequest.URL)
+	}
 	if len(pullRequests) == 1 {
-		fmt.Printf("%s: found existing PR with head branch %s: %s\n", entry.Name, headBranch, pullRequests[0].GetHTMLURL())
+		result.setOutcome(OutcomeExistingPullRequest)
+		fmt.Printf("%s: found existing PR with head branch %s: %s\n", entry.Name, branchName, pullRequests[0].URL)
 		return nil
 	} else if len(pullRequests) > 1 {
 		pullRequestString := ""
 		for _, pullRequest := range pullRequests {
-			pullRequestString = pullRequestString + "\n- " + pullRequest.GetHTMLURL()
+			pullRequestString = pullRequestString + "\n- " + pullRequest.URL
 		}
-		fmt.Printf("%s: warning: found multiple existing PRs with head branch %s:%s\n", entry.Name, headBranch, pullRequestString)
+		fmt.Printf("%s: warning: found multiple existing PRs with head branch %s:%s\n", entry.Name, branchName, pullRequestString)
+		result.setOutcome(OutcomeExistingPullRequest)
 		return nil
 	}
 
@@ -219,23 +529,107 @@ func (entry ConfigEntry) Run(ctx context.Context, opts AutoUpdateOptions) error
 			}
 		}
 		fmt.Print(msg)
+		result.setOutcome(OutcomeDryRun)
 		return nil
 	}
 
-	return entry.CreateArtifactUpdatePullRequest(ctx, opts, branchName, artifactsToUpdate)
+	pullRequest, err := entry.CreateArtifactUpdatePullRequest(ctx, opts, branchName, artifactsToUpdate, skippedTags, deferredTags, heldBackTags)
+	if pullRequest.URL != "" {
+		result.PullRequestURLs = append(result.PullRequestURLs, pullRequest.URL)
+		result.setOutcome(OutcomePullRequestCreated)
+	}
+	return err
 }
 
-func (entry ConfigEntry) CreateArtifactUpdatePullRequest(ctx context.Context, opts AutoUpdateOptions, branchName string, artifactsToUpdate []*config.Artifact) error {
+// CreateArtifactUpdatePullRequest commits artifactsToUpdate to a new branch
+// and opens a pull request for it. If the pull request was created, it is
+// returned even if a later step fails.
+func (entry ConfigEntry) CreateArtifactUpdatePullRequest(ctx context.Context, opts AutoUpdateOptions, branchName string, artifactsToUpdate []*config.Artifact, skippedTags, deferredTags, heldBackTags []SkippedTag) (PullRequest, error) {
+	if err := entry.commitAndPushArtifactUpdate(ctx, opts, branchName, artifactsToUpdate); err != nil {
+		return PullRequest{}, err
+	}
+
+	tagCount := 0
+	for _, artifactToUpdate := range artifactsToUpdate {
+		tagCount = tagCount + len(artifactToUpdate.Tags)
+	}
+	title := fmt.Sprintf("[autoupdate] Add %d tag(s) for `%s`", tagCount, entry.Name)
+	body := "This PR was created by the autoupdate workflow.\n\n" + addedTagsHeading
+	for _, artifactToUpdate := range artifactsToUpdate {
+		for _, fullArtifact := range artifactToUpdate.CombineSourceArtifactAndTags() {
+			body = body + "\n- `" + fullArtifact + "`"
+		}
+	}
+	if len(skippedTags) > 0 {
+		body = body + "\n\nThe following tags were found, but could not be pulled. They will be proposed again in a later run:"
+		for _, skippedTag := range skippedTags {
+			body = body + fmt.Sprintf("\n- `%s:%s`: %s", skippedTag.SourceArtifact, skippedTag.Tag, skippedTag.Reason)
+		}
+	}
+	if len(deferredTags) > 0 {
+		body = body + fmt.Sprintf("\n\nThe following tags were found, but were published less than MinAge (%s) ago. They will be proposed in a later run:", entry.MinAge)
+		for _, deferredTag := range deferredTags {
+			body = body + fmt.Sprintf("\n- `%s:%s`: %s", deferredTag.SourceArtifact, deferredTag.Tag, deferredTag.Reason)
+		}
+	}
+	if len(heldBackTags) > 0 {
+		body = body + fmt.Sprintf("\n\nThe following tags were found, but were held back to keep at most MaxPullRequests (%d) pull requests open. They will be proposed in a later run:", entry.MaxPullRequests)
+		for _, heldBackTag := range heldBackTags {
+			body = body + fmt.Sprintf("\n- `%s:%s`", heldBackTag.SourceArtifact, heldBackTag.Tag)
+		}
+	}
+	pullRequest, err := opts.CodeHost.CreatePullRequest(ctx, NewPullRequest{
+		HeadBranch: branchName,
+		BaseBranch: opts.BaseBranch,
+		Title:      title,
+		Body:       body,
+	})
+	if err != nil {
+		return PullRequest{}, fmt.Errorf("failed to create pull request: %w", err)
+	}
+
+	fmt.Printf("%s: created pull request: %s\n", entry.Name, pullRequest.URL)
+
+	if err := opts.CodeHost.RequestReviewers(ctx, pullRequest.Number, entry.Reviewers); err != nil {
+		return pullRequest, fmt.Errorf("failed to request reviewers for pull request %s: %w", pullRequest.URL, err)
+	}
+	if len(entry.Labels) > 0 {
+		if err := opts.CodeHost.AddLabels(ctx, pullRequest.Number, entry.Labels); err != nil {
+			return pullRequest, fmt.Errorf("failed to add labels to pull request %s: %w", pullRequest.URL, err)
+		}
+	}
+
+	return pullRequest, nil
+}
+
+// commitAndPushArtifactUpdate commits artifactsToUpdate to a new branch
+// and pushes it. The git commands are not cancelled along with ctx, since
+// stopping them midway would leave changes behind in the working tree that
+// later entries would commit. If any step fails, the changes are discarded
+// and opts.BaseBranch is checked out again.
+func (entry ConfigEntry) commitAndPushArtifactUpdate(ctx context.Context, opts AutoUpdateOptions, branchName string, artifactsToUpdate []*config.Artifact) (err error) {
+	gitCtx := context.WithoutCancel(ctx)
+	defer func() {
+		if err == nil {
+			return
+		}
+		if discardErr := git.DiscardChanges(gitCtx, opts.BaseBranch); discardErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to discard changes: %w", discardErr))
+		}
+	}()
+
+	// The accumulator modifies the artifacts it is given, so we work on a
+	// copy of opts.ConfigYaml in case more pull requests are made from it.
+	configYaml := opts.ConfigYaml.DeepCopy()
 	accumulator := config.NewArtifactAccumulator()
-	accumulator.AddArtifacts(opts.ConfigYaml.Artifacts...)
+	accumulator.AddArtifacts(configYaml.Artifacts...)
 
-	if err := git.CreateAndCheckoutBranch(opts.BaseBranch, branchName); err != nil {
+	if err := git.CreateAndCheckoutBranch(gitCtx, opts.BaseBranch, branchName); err != nil {
 		return fmt.Errorf("failed to create and checkout branch %s: %w", branchName, err)
 	}
 	for _, artifactToUpdate := range artifactsToUpdate {
 		// We can reuse the accumulator here because we are making a sequence
 		// of commits, each of which makes an addition from artifactsToUpdate.
-		configYaml := opts.ConfigYaml
 		accumulator.AddArtifacts(artifactToUpdate)
 		configYaml.Artifacts = accumulator.Artifacts()
 		if err := config.Write(paths.ConfigYaml, configYaml); err != nil {
@@ -252,43 +646,14 @@ func (entry ConfigEntry) CreateArtifactUpdatePullRequest(ctx context.Context, op
 
 		tagString := strings.Join(artifactToUpdate.Tags, ", ")
 		msg := fmt.Sprintf("Add tag(s) %s for artifact %s", tagString, artifactToUpdate.SourceArtifact)
-		if err := git.Commit(msg); err != nil {
+		if err := git.Commit(gitCtx, msg); err != nil {
 			return fmt.Errorf("failed to commit changes for artifact %s: %w", artifactToUpdate.SourceArtifact, err)
 		}
 	}
-	if err := git.PushBranch(branchName, "origin"); err != nil {
+	if err := git.PushBranch(gitCtx, branchName, "origin"); err != nil {
 		return fmt.Errorf("failed to push branch %s: %w", branchName, err)
 	}
 
-	tagCount := 0
-	for _, artifactToUpdate := range artifactsToUpdate {
-		tagCount = tagCount + len(artifactToUpdate.Tags)
-	}
-	title := fmt.Sprintf("[autoupdate] Add %d tag(s) for `%s`", tagCount, entry.Name)
-	body := "This PR was created by the autoupdate workflow.\n\nIt adds the following artifact tags:"
-	for _, artifactToUpdate := range artifactsToUpdate {
-		for _, fullArtifact := range artifactToUpdate.CombineSourceArtifactAndTags() {
-			body = body + "\n- `" + fullArtifact + "`"
-		}
-	}
-	maintainerCanModify := true
-	newPullRequest := &github.NewPullRequest{
-		Base:                &opts.BaseBranch,
-		Head:                &branchName,
-		Title:               &title,
-		Body:                &body,
-		MaintainerCanModify: &maintainerCanModify,
-	}
-
-	requestContext, cancel := context.WithTimeout(ctx, 10*time.Second)
-	defer cancel()
-	pullRequest, _, err := opts.GithubClient.PullRequests.Create(requestContext, opts.GithubOwner, opts.GithubRepo, newPullRequest)
-	if err != nil {
-		return fmt.Errorf("failed to create pull request: %w", err)
-	}
-
-	fmt.Printf("%s: created pull request: %s\n", entry.Name, pullRequest.GetHTMLURL())
-
 	return nil
 }
 
@@ -1,15 +1,26 @@
 package autoupdate
 
 import (
+	"context"
+	"errors"
+	"net/http"
+	"os/exec"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/rancher/artifact-mirror/internal/config"
+	"github.com/rancher/artifact-mirror/internal/git"
+	"github.com/rancher/artifact-mirror/internal/paths"
+	"github.com/rancher/artifact-mirror/internal/regsync"
 
 	"github.com/stretchr/testify/assert"
 )
 
 func TestConfigEntry(t *testing.T) {
 	t.Run("Validate", func(t *testing.T) {
+		minAge := Duration(72 * time.Hour)
+		negativeMinAge := Duration(-time.Hour)
 		type testCase struct {
 			Message       string
 			ConfigEntry   ConfigEntry
@@ -29,6 +40,32 @@ func TestConfigEntry(t *testing.T) {
 				},
 				ExpectedError: "",
 			},
+			{
+				Message: "should return nil for a valid ConfigEntry with GitTag",
+				ConfigEntry: ConfigEntry{
+					Name: "test-entry",
+					GitTag: &GitTag{
+						Owner:      "test-owner",
+						Repository: "test-repo",
+						Artifacts:  []AutoupdateArtifactRef{{SourceArtifact: "rancher/rancher"}},
+					},
+					Reviewers: []string{"user"},
+				},
+				ExpectedError: "",
+			},
+			{
+				Message: "should return nil for a valid ConfigEntry with HelmChart",
+				ConfigEntry: ConfigEntry{
+					Name: "test-entry",
+					HelmChart: &HelmChart{
+						Repository: "oci://dp.apps.rancher.io/charts",
+						Chart:      "redis",
+						Artifacts:  []AutoupdateArtifactRef{{SourceArtifact: "dp.apps.rancher.io/charts/redis"}},
+					},
+					Reviewers: []string{"user"},
+				},
+				ExpectedError: "",
+			},
 			{
 				Message: "should return nil for a valid ConfigEntry with HelmLatest",
 				ConfigEntry: ConfigEntry{
@@ -45,6 +82,21 @@ func TestConfigEntry(t *testing.T) {
 				},
 				ExpectedError: "",
 			},
+			{
+				Message: "should return nil for a valid ConfigEntry with Manifest",
+				ConfigEntry: ConfigEntry{
+					Name: "test-entry",
+					Manifest: &Manifest{
+						Owner:      "test-owner",
+						Repository: "test-repo",
+						Path:       "config/default",
+						Kustomize:  true,
+						Artifacts:  []AutoupdateArtifactRef{{SourceArtifact: "rancher/rancher"}},
+					},
+					Reviewers: []string{"user"},
+				},
+				ExpectedError: "",
+			},
 			{
 				Message: "should return nil for a valid ConfigEntry with Registry",
 				ConfigEntry: ConfigEntry{
@@ -99,6 +151,171 @@ func TestConfigEntry(t *testing.T) {
 				},
 				ExpectedError: "must specify only one autoupdate strategy",
 			},
+			{
+				Message: "should return error for negative MaxTagsPerPullRequest",
+				ConfigEntry: ConfigEntry{
+					Name: "test-entry",
+					GithubRelease: &GithubRelease{
+						Owner:      "test-owner",
+						Repository: "test-repo",
+						Artifacts:  []AutoupdateArtifactRef{{SourceArtifact: "rancher/rancher"}},
+					},
+					MaxTagsPerPullRequest: -1,
+					Reviewers:             []string{"user"},
+				},
+				ExpectedError: "MaxTagsPerPullRequest must not be negative",
+			},
+			{
+				Message: "should return error for negative MaxPullRequests",
+				ConfigEntry: ConfigEntry{
+					Name: "test-entry",
+					GithubRelease: &GithubRelease{
+						Owner:      "test-owner",
+						Repository: "test-repo",
+						Artifacts:  []AutoupdateArtifactRef{{SourceArtifact: "rancher/rancher"}},
+					},
+					MaxPullRequests: -1,
+					Reviewers:       []string{"user"},
+				},
+				ExpectedError: "MaxPullRequests must not be negative",
+			},
+			{
+				Message: "should return nil for MinAge with a strategy that supports it",
+				ConfigEntry: ConfigEntry{
+					Name: "test-entry",
+					GithubRelease: &GithubRelease{
+						Owner:      "test-owner",
+						Repository: "test-repo",
+						Artifacts:  []AutoupdateArtifactRef{{SourceArtifact: "rancher/rancher"}},
+					},
+					MinAge:    &minAge,
+					Reviewers: []string{"user"},
+				},
+				ExpectedError: "",
+			},
+			{
+				Message: "should return error for MinAge with a strategy that does not support it",
+				ConfigEntry: ConfigEntry{
+					Name: "test-entry",
+					GitTag: &GitTag{
+						Owner:      "test-owner",
+						Repository: "test-repo",
+						Artifacts:  []AutoupdateArtifactRef{{SourceArtifact: "rancher/rancher"}},
+					},
+					MinAge:    &minAge,
+					Reviewers: []string{"user"},
+				},
+				ExpectedError: "MinAge is not supported by this update strategy",
+			},
+			{
+				Message: "should return nil for MinAge with a registry that reports push times",
+				ConfigEntry: ConfigEntry{
+					Name: "test-entry",
+					Registry: &Registry{
+						Artifacts: []AutoupdateArtifactRef{{SourceArtifact: "rancher/rancher"}, {SourceArtifact: "quay.io/test-org/test-artifact"}},
+					},
+					MinAge:    &minAge,
+					Reviewers: []string{"user"},
+				},
+				ExpectedError: "",
+			},
+			{
+				Message: "should return error for MinAge with a registry that does not report push times",
+				ConfigEntry: ConfigEntry{
+					Name: "test-entry",
+					Registry: &Registry{
+						Artifacts: []AutoupdateArtifactRef{{SourceArtifact: "rancher/rancher"}, {SourceArtifact: "ghcr.io/test-org/test-artifact"}},
+					},
+					MinAge:    &minAge,
+					Reviewers: []string{"user"},
+				},
+				ExpectedError: "MinAge is not supported for ghcr.io/test-org/test-artifact: only Docker Hub and Quay report push times",
+			},
+			{
+				Message: "should return error for MinAge with a chart in an OCI repository",
+				ConfigEntry: ConfigEntry{
+					Name: "test-entry",
+					HelmChart: &HelmChart{
+						Repository: "oci://registry.example/charts",
+						Chart:      "test-chart",
+						Artifacts:  []AutoupdateArtifactRef{{SourceArtifact: "registry.example/charts/test-chart"}},
+					},
+					MinAge:    &minAge,
+					Reviewers: []string{"user"},
+				},
+				ExpectedError: "MinAge is not supported for charts in OCI repositories, which do not report push times",
+			},
+			{
+				Message: "should return error for negative MinAge",
+				ConfigEntry: ConfigEntry{
+					Name: "test-entry",
+					GithubRelease: &GithubRelease{
+						Owner:      "test-owner",
+						Repository: "test-repo",
+						Artifacts:  []AutoupdateArtifactRef{{SourceArtifact: "rancher/rancher"}},
+					},
+					MinAge:    &negativeMinAge,
+					Reviewers: []string{"user"},
+				},
+				ExpectedError: "MinAge must not be negative",
+			},
+			{
+				Message: "should return nil for Versions with a strategy that selects all versions",
+				ConfigEntry: ConfigEntry{
+					Name: "test-entry",
+					GithubRelease: &GithubRelease{
+						Owner:      "test-owner",
+						Repository: "test-repo",
+						Artifacts:  []AutoupdateArtifactRef{{SourceArtifact: "rancher/rancher"}},
+					},
+					Versions:  &VersionPolicy{Constraint: ">=1.0.0", LatestPatchPerMinor: true},
+					Reviewers: []string{"user"},
+				},
+				ExpectedError: "",
+			},
+			{
+				Message: "should return error for Versions.Constraint with VersionConstraint",
+				ConfigEntry: ConfigEntry{
+					Name: "test-entry",
+					GitTag: &GitTag{
+						Owner:             "test-owner",
+						Repository:        "test-repo",
+						Artifacts:         []AutoupdateArtifactRef{{SourceArtifact: "rancher/rancher"}},
+						VersionConstraint: ">=1.0.0",
+					},
+					Versions:  &VersionPolicy{Constraint: "<2.0.0"},
+					Reviewers: []string{"user"},
+				},
+				ExpectedError: "must not specify both Versions.Constraint and VersionConstraint",
+			},
+			{
+				Message: "should return error for Versions that pick tags with Latest",
+				ConfigEntry: ConfigEntry{
+					Name: "test-entry",
+					Registry: &Registry{
+						Artifacts: []AutoupdateArtifactRef{{SourceArtifact: "rancher/rancher"}},
+						Latest:    true,
+					},
+					Versions:  &VersionPolicy{KeepLatest: 2},
+					Reviewers: []string{"user"},
+				},
+				ExpectedError: "must only specify MinimumVersion in Versions when LatestOnly or Latest is true",
+			},
+			{
+				Message: "should return nil for MinimumVersion with LatestOnly",
+				ConfigEntry: ConfigEntry{
+					Name: "test-entry",
+					GithubRelease: &GithubRelease{
+						Owner:      "test-owner",
+						Repository: "test-repo",
+						Artifacts:  []AutoupdateArtifactRef{{SourceArtifact: "rancher/rancher"}},
+						LatestOnly: true,
+					},
+					Versions:  &VersionPolicy{MinimumVersion: MinimumVersionNewest},
+					Reviewers: []string{"user"},
+				},
+				ExpectedError: "",
+			},
 			{
 				Message: "should return nil for valid reviewers",
 				ConfigEntry: ConfigEntry{
@@ -258,3 +475,433 @@ func TestGetBranchHash(t *testing.T) {
 		assert.NotEqual(t, hash1, hash2)
 	})
 }
+
+// fakeTagResolver returns the error stored under <source artifact>:<tag>,
+// or nil if there is none.
+type fakeTagResolver map[string]error
+
+func (f fakeTagResolver) ResolveTag(_ context.Context, sourceArtifact, tag string) error {
+	return f[sourceArtifact+":"+tag]
+}
+
+// setupGitRepo creates a git repository with a bare remote named origin
+// and a master branch that contains configYaml, and changes the working
+// directory to it for the duration of the test.
+func setupGitRepo(t *testing.T, configYaml *config.Config) {
+	t.Helper()
+	t.Setenv("GIT_AUTHOR_NAME", "test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+
+	remoteDir := t.TempDir()
+	repoDir := t.TempDir()
+	runGit := func(dir string, args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v failed: %s: %s", args, err, output)
+		}
+	}
+	runGit(remoteDir, "init", "--bare", "--initial-branch", "master")
+	runGit(repoDir, "init", "--initial-branch", "master")
+	runGit(repoDir, "remote", "add", "origin", remoteDir)
+
+	t.Chdir(repoDir)
+	originalConfigYaml := paths.ConfigYaml
+	paths.ConfigYaml = "config.yaml"
+	t.Cleanup(func() { paths.ConfigYaml = originalConfigYaml })
+	if err := config.Write(paths.ConfigYaml, configYaml); err != nil {
+		t.Fatalf("failed to write %s: %s", paths.ConfigYaml, err)
+	}
+	runGit(repoDir, "add", "--all")
+	runGit(repoDir, "commit", "--message", "initial commit")
+}
+
+func TestRun(t *testing.T) {
+	newConfigYaml := func(t *testing.T) *config.Config {
+		t.Helper()
+		artifact, err := config.NewArtifact("test-org/test-artifact", []string{"v1.0.0"}, "", nil, nil)
+		assert.NoError(t, err)
+		return &config.Config{
+			Artifacts: []*config.Artifact{artifact},
+			Repositories: []config.Repository{
+				{BaseUrl: "docker.io/rancher", DefaultTarget: true},
+			},
+		}
+	}
+	entry := ConfigEntry{
+		Name:      "test-entry",
+		Labels:    []string{"autoupdate"},
+		Reviewers: []string{"user", "org/team"},
+	}
+
+	t.Run("should create a pull request that adds new tags", func(t *testing.T) {
+		configYaml := newConfigYaml(t)
+		setupGitRepo(t, configYaml)
+		codeHost := NewFakeCodeHost()
+		opts := AutoUpdateOptions{
+			BaseBranch: "master",
+			ConfigYaml: configYaml.DeepCopy(),
+			CodeHost:   codeHost,
+		}
+		newArtifact, err := config.NewArtifact("test-org/test-artifact", []string{"v1.0.0", "v1.1.0"}, "", nil, nil)
+		assert.NoError(t, err)
+
+		result := &EntryResult{}
+		err = entry.runWithArtifacts(t.Context(), opts, UpdateArtifacts{Artifacts: []*config.Artifact{newArtifact}}, result)
+		assert.NoError(t, err)
+
+		assert.Len(t, codeHost.PullRequests, 1)
+		pullRequest := codeHost.PullRequests[0]
+		assert.Equal(t, OutcomePullRequestCreated, result.Outcome)
+		assert.Equal(t, []string{"test-org/test-artifact:v1.1.0"}, result.ProposedTags)
+		assert.Equal(t, []string{pullRequest.URL}, result.PullRequestURLs)
+		assert.Equal(t, "master", pullRequest.BaseBranch)
+		assert.True(t, strings.HasPrefix(pullRequest.HeadBranch, "autoupdate/test-entry/"))
+		assert.Equal(t, "[autoupdate] Add 1 tag(s) for `test-entry`", pullRequest.Title)
+		assert.Contains(t, pullRequest.Body, "- `test-org/test-artifact:v1.1.0`")
+		assert.Equal(t, []string{"user", "org/team"}, codeHost.Reviewers[pullRequest.Number])
+		assert.Equal(t, []string{"autoupdate"}, codeHost.Labels[pullRequest.Number])
+
+		updatedConfigYaml, err := config.Parse(paths.ConfigYaml)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"v1.0.0", "v1.1.0"}, updatedConfigYaml.Artifacts[0].Tags)
+		regsyncYaml, err := regsync.ReadConfig(paths.RegsyncYaml)
+		assert.NoError(t, err)
+		assert.Len(t, regsyncYaml.Sync, 2)
+	})
+
+	t.Run("should leave tags that cannot be resolved out of the pull request", func(t *testing.T) {
+		configYaml := newConfigYaml(t)
+		setupGitRepo(t, configYaml)
+		codeHost := NewFakeCodeHost()
+		opts := AutoUpdateOptions{
+			BaseBranch: "master",
+			ConfigYaml: configYaml.DeepCopy(),
+			CodeHost:   codeHost,
+			TagResolver: fakeTagResolver{
+				"test-org/test-artifact:v1.2.0": ErrTagNotFound,
+			},
+		}
+		newArtifact, err := config.NewArtifact("test-org/test-artifact", []string{"v1.1.0", "v1.2.0"}, "", nil, nil)
+		assert.NoError(t, err)
+
+		err = entry.runWithArtifacts(t.Context(), opts, UpdateArtifacts{Artifacts: []*config.Artifact{newArtifact}}, &EntryResult{})
+		assert.NoError(t, err)
+
+		assert.Len(t, codeHost.PullRequests, 1)
+		pullRequest := codeHost.PullRequests[0]
+		assert.Equal(t, "[autoupdate] Add 1 tag(s) for `test-entry`", pullRequest.Title)
+		assert.Contains(t, pullRequest.Body, "- `test-org/test-artifact:v1.1.0`")
+		assert.Contains(t, pullRequest.Body, "- `test-org/test-artifact:v1.2.0`: tag not found")
+		updatedConfigYaml, err := config.Parse(paths.ConfigYaml)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"v1.0.0", "v1.1.0"}, updatedConfigYaml.Artifacts[0].Tags)
+	})
+
+	t.Run("should leave tags that are younger than MinAge out of the pull request", func(t *testing.T) {
+		configYaml := newConfigYaml(t)
+		setupGitRepo(t, configYaml)
+		codeHost := NewFakeCodeHost()
+		opts := AutoUpdateOptions{
+			BaseBranch: "master",
+			ConfigYaml: configYaml.DeepCopy(),
+			CodeHost:   codeHost,
+		}
+		minAgeEntry := entry
+		minAge := Duration(72 * time.Hour)
+		minAgeEntry.MinAge = &minAge
+		minAgeEntry.GithubRelease = &GithubRelease{}
+		newArtifact, err := config.NewArtifact("test-org/test-artifact", []string{"v1.1.0", "v1.2.0"}, "", nil, nil)
+		assert.NoError(t, err)
+		updateArtifacts := UpdateArtifacts{
+			Artifacts: []*config.Artifact{newArtifact},
+			PublishTimes: map[string]time.Time{
+				"test-org/test-artifact:v1.1.0": time.Now().Add(-96 * time.Hour),
+				"test-org/test-artifact:v1.2.0": time.Now().Add(-time.Hour),
+			},
+		}
+
+		result := &EntryResult{}
+		err = minAgeEntry.runWithArtifacts(t.Context(), opts, updateArtifacts, result)
+		assert.NoError(t, err)
+
+		assert.Len(t, codeHost.PullRequests, 1)
+		pullRequest := codeHost.PullRequests[0]
+		assert.Equal(t, []string{"test-org/test-artifact:v1.1.0"}, result.ProposedTags)
+		assert.Len(t, result.DeferredTags, 1)
+		assert.Equal(t, "v1.2.0", result.DeferredTags[0].Tag)
+		assert.Contains(t, pullRequest.Body, "- `test-org/test-artifact:v1.1.0`")
+		assert.Contains(t, pullRequest.Body, "published less than MinAge (72h0m0s) ago")
+		assert.Contains(t, pullRequest.Body, "- `test-org/test-artifact:v1.2.0`: published 1h0m0s ago")
+	})
+
+	t.Run("should commit and push even if the context is done", func(t *testing.T) {
+		configYaml := newConfigYaml(t)
+		setupGitRepo(t, configYaml)
+		opts := AutoUpdateOptions{
+			BaseBranch: "master",
+			ConfigYaml: configYaml.DeepCopy(),
+			CodeHost:   NewFakeCodeHost(),
+		}
+		newArtifact, err := config.NewArtifact("test-org/test-artifact", []string{"v1.1.0"}, "", nil, nil)
+		assert.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(t.Context())
+		cancel()
+		err = entry.commitAndPushArtifactUpdate(ctx, opts, "autoupdate/test-entry/cancelled", []*config.Artifact{newArtifact})
+		assert.NoError(t, err)
+		clean, err := git.IsWorkingTreeClean(t.Context())
+		assert.NoError(t, err)
+		assert.True(t, clean)
+		output, err := exec.Command("git", "ls-remote", "--heads", "origin", "autoupdate/test-entry/cancelled").Output()
+		assert.NoError(t, err)
+		assert.NotEmpty(t, output)
+	})
+
+	t.Run("should discard changes and check out the base branch if a git step fails", func(t *testing.T) {
+		configYaml := newConfigYaml(t)
+		setupGitRepo(t, configYaml)
+		assert.NoError(t, exec.Command("git", "remote", "remove", "origin").Run())
+		opts := AutoUpdateOptions{
+			BaseBranch: "master",
+			ConfigYaml: configYaml.DeepCopy(),
+			CodeHost:   NewFakeCodeHost(),
+		}
+		newArtifact, err := config.NewArtifact("test-org/test-artifact", []string{"v1.1.0"}, "", nil, nil)
+		assert.NoError(t, err)
+
+		err = entry.commitAndPushArtifactUpdate(t.Context(), opts, "autoupdate/test-entry/failed", []*config.Artifact{newArtifact})
+		assert.ErrorContains(t, err, "failed to push branch autoupdate/test-entry/failed")
+		clean, err := git.IsWorkingTreeClean(t.Context())
+		assert.NoError(t, err)
+		assert.True(t, clean)
+		output, err := exec.Command("git", "branch", "--show-current").Output()
+		assert.NoError(t, err)
+		assert.Equal(t, "master", strings.TrimSpace(string(output)))
+		updatedConfigYaml, err := config.Parse(paths.ConfigYaml)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"v1.0.0"}, updatedConfigYaml.Artifacts[0].Tags)
+	})
+
+	t.Run("should not create a pull request when no tags can be resolved", func(t *testing.T) {
+		configYaml := newConfigYaml(t)
+		codeHost := NewFakeCodeHost()
+		opts := AutoUpdateOptions{
+			BaseBranch: "master",
+			ConfigYaml: configYaml.DeepCopy(),
+			CodeHost:   codeHost,
+			TagResolver: fakeTagResolver{
+				"test-org/test-artifact:v1.1.0": ErrTagNotFound,
+			},
+		}
+		newArtifact, err := config.NewArtifact("test-org/test-artifact", []string{"v1.1.0"}, "", nil, nil)
+		assert.NoError(t, err)
+
+		err = entry.runWithArtifacts(t.Context(), opts, UpdateArtifacts{Artifacts: []*config.Artifact{newArtifact}}, &EntryResult{})
+		assert.NoError(t, err)
+		assert.Empty(t, codeHost.PullRequests)
+	})
+
+	t.Run("should return error when a tag cannot be checked", func(t *testing.T) {
+		configYaml := newConfigYaml(t)
+		codeHost := NewFakeCodeHost()
+		opts := AutoUpdateOptions{
+			BaseBranch: "master",
+			ConfigYaml: configYaml.DeepCopy(),
+			CodeHost:   codeHost,
+			TagResolver: fakeTagResolver{
+				"test-org/test-artifact:v1.1.0": errors.New("unexpected status code 401"),
+			},
+		}
+		newArtifact, err := config.NewArtifact("test-org/test-artifact", []string{"v1.1.0"}, "", nil, nil)
+		assert.NoError(t, err)
+
+		result := &EntryResult{}
+		err = entry.runWithArtifacts(t.Context(), opts, UpdateArtifacts{Artifacts: []*config.Artifact{newArtifact}}, result)
+		assert.EqualError(t, err, "failed to check that tags can be pulled: unexpected status code 401")
+		assert.Empty(t, result.SkippedTags)
+		assert.Empty(t, codeHost.PullRequests)
+	})
+
+	t.Run("should split updates into several pull requests according to limits", func(t *testing.T) {
+		configYaml := newConfigYaml(t)
+		setupGitRepo(t, configYaml)
+		codeHost := NewFakeCodeHost()
+		// Every open pull request of the entry counts towards
+		// MaxPullRequests.
+		_, err := codeHost.CreatePullRequest(t.Context(), NewPullRequest{
+			HeadBranch: entry.branchPrefix() + "unrelated",
+			BaseBranch: "master",
+		})
+		assert.NoError(t, err)
+		opts := AutoUpdateOptions{
+			BaseBranch: "master",
+			ConfigYaml: configYaml.DeepCopy(),
+			CodeHost:   codeHost,
+		}
+		limitedEntry := entry
+		limitedEntry.MaxTagsPerPullRequest = 1
+		limitedEntry.MaxPullRequests = 3
+		newArtifacts := func(tags ...string) UpdateArtifacts {
+			newArtifact, err := config.NewArtifact("test-org/test-artifact", tags, "", nil, nil)
+			assert.NoError(t, err)
+			return UpdateArtifacts{Artifacts: []*config.Artifact{newArtifact}}
+		}
+		heldBack := []SkippedTag{{
+			SourceArtifact: "test-org/test-artifact",
+			Tag:            "v1.1.0",
+			Reason:         "held back to keep at most MaxPullRequests (3) pull requests open",
+		}}
+
+		result := &EntryResult{}
+		err = limitedEntry.runWithArtifacts(t.Context(), opts, newArtifacts("v1.1.0", "v1.3.0", "v1.2.0"), result)
+		assert.NoError(t, err)
+		assert.Len(t, codeHost.PullRequests, 3)
+		assert.Contains(t, codeHost.PullRequests[1].Body, "- `test-org/test-artifact:v1.3.0`")
+		assert.NotContains(t, codeHost.PullRequests[1].Body, "v1.2.0")
+		assert.Contains(t, codeHost.PullRequests[2].Body, "- `test-org/test-artifact:v1.2.0`")
+		assert.NotContains(t, codeHost.PullRequests[2].Body, "v1.3.0")
+		assert.NotEqual(t, codeHost.PullRequests[1].HeadBranch, codeHost.PullRequests[2].HeadBranch)
+		// The held back tags are only listed in the first pull request.
+		assert.Contains(t, codeHost.PullRequests[1].Body, "held back to keep at most MaxPullRequests (3) pull requests open. They will be proposed in a later run:\n- `test-org/test-artifact:v1.1.0`")
+		assert.NotContains(t, codeHost.PullRequests[2].Body, "v1.1.0")
+		assert.Equal(t, heldBack, result.DeferredTags)
+
+		// While the pull requests are open, their tags are left out and the
+		// held back tags wait.
+		opts.ConfigYaml = configYaml.DeepCopy()
+		result = &EntryResult{}
+		err = limitedEntry.runWithArtifacts(t.Context(), opts, newArtifacts("v1.1.0", "v1.3.0", "v1.2.0"), result)
+		assert.NoError(t, err)
+		assert.Len(t, codeHost.PullRequests, 3)
+		assert.Equal(t, OutcomeExistingPullRequest, result.Outcome)
+		assert.ElementsMatch(t, []string{"test-org/test-artifact:v1.3.0", "test-org/test-artifact:v1.2.0"}, result.ProposedTags)
+		assert.Equal(t, heldBack, result.DeferredTags)
+
+		// Once a pull request is merged, its tag is no longer new, and the
+		// held back tag takes its place. The tag of the other open pull
+		// request is not proposed again.
+		codeHost.Closed[codeHost.PullRequests[1].Number] = true
+		opts.ConfigYaml = configYaml.DeepCopy()
+		result = &EntryResult{}
+		err = limitedEntry.runWithArtifacts(t.Context(), opts, newArtifacts("v1.1.0", "v1.2.0"), result)
+		assert.NoError(t, err)
+		assert.Len(t, codeHost.PullRequests, 4)
+		assert.Contains(t, codeHost.PullRequests[3].Body, "- `test-org/test-artifact:v1.1.0`")
+		assert.NotContains(t, codeHost.PullRequests[3].Body, "v1.2.0")
+		assert.Empty(t, result.DeferredTags)
+		assert.Equal(t, OutcomePullRequestCreated, result.Outcome)
+	})
+
+	t.Run("should not create a pull request when one already exists for the branch", func(t *testing.T) {
+		configYaml := newConfigYaml(t)
+		setupGitRepo(t, configYaml)
+		codeHost := NewFakeCodeHost()
+		opts := AutoUpdateOptions{
+			BaseBranch: "master",
+			ConfigYaml: configYaml.DeepCopy(),
+			CodeHost:   codeHost,
+		}
+		newArtifact, err := config.NewArtifact("test-org/test-artifact", []string{"v1.1.0"}, "", nil, nil)
+		assert.NoError(t, err)
+		err = entry.runWithArtifacts(t.Context(), opts, UpdateArtifacts{Artifacts: []*config.Artifact{newArtifact}}, &EntryResult{})
+		assert.NoError(t, err)
+		assert.Len(t, codeHost.PullRequests, 1)
+
+		opts.ConfigYaml = configYaml.DeepCopy()
+		newArtifact, err = config.NewArtifact("test-org/test-artifact", []string{"v1.1.0"}, "", nil, nil)
+		assert.NoError(t, err)
+		result := &EntryResult{}
+		err = entry.runWithArtifacts(t.Context(), opts, UpdateArtifacts{Artifacts: []*config.Artifact{newArtifact}}, result)
+		assert.NoError(t, err)
+		assert.Len(t, codeHost.PullRequests, 1)
+		assert.Equal(t, OutcomeExistingPullRequest, result.Outcome)
+		assert.Equal(t, []string{codeHost.PullRequests[0].URL}, result.PullRequestURLs)
+	})
+
+	t.Run("should not create a pull request when there are no new tags", func(t *testing.T) {
+		configYaml := newConfigYaml(t)
+		codeHost := NewFakeCodeHost()
+		opts := AutoUpdateOptions{
+			BaseBranch: "master",
+			ConfigYaml: configYaml.DeepCopy(),
+			CodeHost:   codeHost,
+		}
+		newArtifact, err := config.NewArtifact("test-org/test-artifact", []string{"v1.0.0"}, "", nil, nil)
+		assert.NoError(t, err)
+
+		err = entry.runWithArtifacts(t.Context(), opts, UpdateArtifacts{Artifacts: []*config.Artifact{newArtifact}}, &EntryResult{})
+		assert.NoError(t, err)
+		assert.Empty(t, codeHost.PullRequests)
+	})
+
+	t.Run("should stop when EntryTimeout is exceeded", func(t *testing.T) {
+		mux := http.NewServeMux()
+		mux.HandleFunc("GET /repos/test-owner/test-repo/releases", func(w http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+		})
+		timeoutEntry := entry
+		timeoutEntry.GithubRelease = &GithubRelease{
+			Owner:        "test-owner",
+			Repository:   "test-repo",
+			Artifacts:    []AutoupdateArtifactRef{{SourceArtifact: "test-org/test-artifact"}},
+			githubClient: newTestGithubClient(t, mux),
+		}
+		assert.NoError(t, timeoutEntry.Validate())
+		opts := AutoUpdateOptions{
+			BaseBranch:   "master",
+			ConfigYaml:   newConfigYaml(t),
+			CodeHost:     NewFakeCodeHost(),
+			EntryTimeout: 100 * time.Millisecond,
+		}
+
+		result, err := timeoutEntry.Run(t.Context(), opts)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.ErrorContains(t, err, "timed out after 100ms")
+		assert.Equal(t, OutcomeError, result.Outcome)
+	})
+
+	t.Run("should return error for invalid reviewers before getting updates", func(t *testing.T) {
+		codeHost := NewFakeCodeHost()
+		codeHost.InvalidReviewers = []string{"other-org/team"}
+		reviewerEntry := entry
+		reviewerEntry.Reviewers = []string{"user", "other-org/team"}
+		reviewerEntry.GithubRelease = &GithubRelease{
+			Owner:        "test-owner",
+			Repository:   "test-repo",
+			Artifacts:    []AutoupdateArtifactRef{{SourceArtifact: "test-org/test-artifact"}},
+			githubClient: newTestGithubClient(t, http.NewServeMux()),
+		}
+		opts := AutoUpdateOptions{
+			BaseBranch: "master",
+			ConfigYaml: newConfigYaml(t),
+			CodeHost:   codeHost,
+		}
+
+		result, err := reviewerEntry.Run(t.Context(), opts)
+		assert.EqualError(t, err, `invalid reviewers for test-entry: invalid reviewer "other-org/team"`)
+		assert.Equal(t, OutcomeError, result.Outcome)
+		assert.Empty(t, codeHost.PullRequests)
+	})
+
+	t.Run("should not create a pull request in dry run mode", func(t *testing.T) {
+		configYaml := newConfigYaml(t)
+		codeHost := NewFakeCodeHost()
+		opts := AutoUpdateOptions{
+			BaseBranch: "master",
+			ConfigYaml: configYaml.DeepCopy(),
+			DryRun:     true,
+			CodeHost:   codeHost,
+		}
+		newArtifact, err := config.NewArtifact("test-org/test-artifact", []string{"v1.1.0"}, "", nil, nil)
+		assert.NoError(t, err)
+
+		err = entry.runWithArtifacts(t.Context(), opts, UpdateArtifacts{Artifacts: []*config.Artifact{newArtifact}}, &EntryResult{})
+		assert.NoError(t, err)
+		assert.Empty(t, codeHost.PullRequests)
+	})
+}
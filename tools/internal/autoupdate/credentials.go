@@ -0,0 +1,128 @@
+package autoupdate
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/rancher/artifact-mirror/internal/config"
+	"github.com/rancher/artifact-mirror/internal/reference"
+
+	"oras.land/oras-go/v2/registry/remote/auth"
+	"oras.land/oras-go/v2/registry/remote/credentials"
+)
+
+// DockerHubHost is the host of the Docker Hub registry API. References to
+// Docker Hub are parsed with a registry of docker.io, which does not serve
+// the registry API itself.
+const DockerHubHost = "registry-1.docker.io"
+
+// RegistryCredentials finds the credentials that are used to query
+// registries. For each registry host, the first of the following is used:
+//
+//   - The REGISTRY_<HOST>_USERNAME and REGISTRY_<HOST>_PASSWORD environment
+//     variables, where <HOST> is the host in upper case with every character
+//     other than letters and digits replaced by "_". For example,
+//     REGISTRY_DP_APPS_RANCHER_IO_USERNAME.
+//   - The Username and Password of the first element of Repositories whose
+//     Registry is the host. As in regsync.yaml, they may use
+//     {{ env "NAME" }} to refer to environment variables.
+//   - The credentials in the docker config.json, including those from
+//     credential helpers.
+//
+// Registries without credentials are accessed anonymously. A nil
+// RegistryCredentials has no credentials.
+type RegistryCredentials struct {
+	Repositories []config.Repository
+	dockerStore  credentials.Store
+}
+
+// NewRegistryCredentials returns a RegistryCredentials that uses
+// repositories and the docker config.json found at $DOCKER_CONFIG or
+// ~/.docker.
+func NewRegistryCredentials(repositories []config.Repository) (*RegistryCredentials, error) {
+	dockerStore, err := credentials.NewStoreFromDocker(credentials.StoreOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load docker config: %w", err)
+	}
+	return &RegistryCredentials{
+		Repositories: repositories,
+		dockerStore:  dockerStore,
+	}, nil
+}
+
+// Credential returns the credential for hostport. It can be used as the
+// Credential of an auth.Client.
+func (rc *RegistryCredentials) Credential(ctx context.Context, hostport string) (auth.Credential, error) {
+	if rc == nil {
+		return auth.EmptyCredential, nil
+	}
+	registry := hostport
+	if registry == DockerHubHost {
+		registry = reference.DockerHubRegistry
+	}
+
+	envPrefix := "REGISTRY_" + strings.Map(func(r rune) rune {
+		if r > unicode.MaxASCII || !(unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return '_'
+		}
+		return unicode.ToUpper(r)
+	}, registry) + "_"
+	if username := os.Getenv(envPrefix + "USERNAME"); username != "" {
+		return auth.Credential{
+			Username: username,
+			Password: os.Getenv(envPrefix + "PASSWORD"),
+		}, nil
+	}
+
+	for _, repository := range rc.Repositories {
+		if repository.Registry != registry {
+			continue
+		}
+		username, err := expandRegsyncTemplate(repository.Username)
+		if err != nil {
+			return auth.EmptyCredential, fmt.Errorf("failed to expand username of repository %s: %w", repository.BaseUrl, err)
+		}
+		password, err := expandRegsyncTemplate(repository.Password)
+		if err != nil {
+			return auth.EmptyCredential, fmt.Errorf("failed to expand password of repository %s: %w", repository.BaseUrl, err)
+		}
+		if username != "" {
+			return auth.Credential{Username: username, Password: password}, nil
+		}
+		break
+	}
+
+	if rc.dockerStore != nil {
+		return credentials.Credential(rc.dockerStore)(ctx, hostport)
+	}
+	return auth.EmptyCredential, nil
+}
+
+// expandRegsyncTemplate expands the {{ env "NAME" }} templates that are
+// used for credentials in regsync.yaml.
+func expandRegsyncTemplate(value string) (string, error) {
+	tmpl, err := template.New("").Option("missingkey=error").Funcs(template.FuncMap{"env": os.Getenv}).Parse(value)
+	if err != nil {
+		return "", err
+	}
+	builder := &strings.Builder{}
+	if err := tmpl.Execute(builder, nil); err != nil {
+		return "", err
+	}
+	return builder.String(), nil
+}
+
+// NewAuthClient returns a client for oras that authenticates with
+// credentials. Its requests are retried in the same way as those of the
+// update strategies.
+func NewAuthClient(credentials *RegistryCredentials) *auth.Client {
+	return &auth.Client{
+		Client:     registryHTTPClient,
+		Cache:      auth.NewCache(),
+		Credential: credentials.Credential,
+	}
+}
@@ -0,0 +1,113 @@
+package autoupdate
+
+import (
+	"context"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rancher/artifact-mirror/internal/config"
+	"github.com/stretchr/testify/assert"
+	"oras.land/oras-go/v2/registry/remote/auth"
+)
+
+func TestRegistryCredentials(t *testing.T) {
+	dockerConfigDir := t.TempDir()
+	dockerAuth := func(username, password string) string {
+		return base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
+	}
+	dockerConfig := `{"auths": {
+		"docker-config.example.com": {"auth": "` + dockerAuth("docker-user", "docker-pass") + `"},
+		"https://index.docker.io/v1/": {"auth": "` + dockerAuth("hub-user", "hub-pass") + `"},
+		"repository.example.com": {"auth": "` + dockerAuth("docker-user", "docker-pass") + `"}
+	}}`
+	if err := os.WriteFile(filepath.Join(dockerConfigDir, "config.json"), []byte(dockerConfig), 0o600); err != nil {
+		t.Fatalf("failed to write docker config: %s", err)
+	}
+	t.Setenv("DOCKER_CONFIG", dockerConfigDir)
+	t.Setenv("REGISTRY_ENV_EXAMPLE_COM_5000_USERNAME", "env-user")
+	t.Setenv("REGISTRY_ENV_EXAMPLE_COM_5000_PASSWORD", "env-pass")
+	t.Setenv("TEST_REPOSITORY_PASSWORD", "repository-pass")
+
+	registryCredentials, err := NewRegistryCredentials([]config.Repository{
+		{
+			BaseUrl:  "repository.example.com/org",
+			Registry: "repository.example.com",
+			Username: "repository-user",
+			Password: `{{ env "TEST_REPOSITORY_PASSWORD" }}`,
+		},
+		{
+			BaseUrl:  "env.example.com:5000/org",
+			Registry: "env.example.com:5000",
+			Username: "repository-user",
+			Password: "repository-pass",
+		},
+		{
+			BaseUrl:  "empty.example.com/org",
+			Registry: "empty.example.com",
+			Username: `{{ env "TEST_UNSET_USERNAME" }}`,
+		},
+	})
+	assert.NoError(t, err)
+
+	type testCase struct {
+		Message            string
+		Host               string
+		ExpectedCredential auth.Credential
+	}
+	testCases := []testCase{
+		{
+			Message:            "should prefer environment variables",
+			Host:               "env.example.com:5000",
+			ExpectedCredential: auth.Credential{Username: "env-user", Password: "env-pass"},
+		},
+		{
+			Message:            "should expand templates in repository credentials",
+			Host:               "repository.example.com",
+			ExpectedCredential: auth.Credential{Username: "repository-user", Password: "repository-pass"},
+		},
+		{
+			Message:            "should use docker config",
+			Host:               "docker-config.example.com",
+			ExpectedCredential: auth.Credential{Username: "docker-user", Password: "docker-pass"},
+		},
+		{
+			Message:            "should use docker config for docker hub",
+			Host:               "registry-1.docker.io",
+			ExpectedCredential: auth.Credential{Username: "hub-user", Password: "hub-pass"},
+		},
+		{
+			Message:            "should return empty credential for repository with empty username",
+			Host:               "empty.example.com",
+			ExpectedCredential: auth.EmptyCredential,
+		},
+		{
+			Message:            "should return empty credential for unknown registry",
+			Host:               "unknown.example.com",
+			ExpectedCredential: auth.EmptyCredential,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.Message, func(t *testing.T) {
+			credential, err := registryCredentials.Credential(context.Background(), testCase.Host)
+			assert.NoError(t, err)
+			assert.Equal(t, testCase.ExpectedCredential, credential)
+		})
+	}
+
+	t.Run("should return empty credential for nil RegistryCredentials", func(t *testing.T) {
+		var nilCredentials *RegistryCredentials
+		credential, err := nilCredentials.Credential(context.Background(), "env.example.com:5000")
+		assert.NoError(t, err)
+		assert.Equal(t, auth.EmptyCredential, credential)
+	})
+
+	t.Run("should return error for invalid template", func(t *testing.T) {
+		invalidCredentials := &RegistryCredentials{
+			Repositories: []config.Repository{{BaseUrl: "invalid.example.com/org", Registry: "invalid.example.com", Username: "{{ env"}},
+		}
+		_, err := invalidCredentials.Credential(context.Background(), "invalid.example.com")
+		assert.ErrorContains(t, err, "failed to expand username of repository invalid.example.com/org")
+	})
+}
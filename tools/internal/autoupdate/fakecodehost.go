@@ -0,0 +1,119 @@
+package autoupdate
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// FakeCodeHost is an in-memory CodeHost. It is meant for tests and for
+// exercising autoupdate without access to a real code host.
+type FakeCodeHost struct {
+	// URLPrefix is prepended to the number of a pull request to form its URL.
+	URLPrefix    string
+	PullRequests []PullRequest
+	// Reviewers and Labels record what was requested for each pull request,
+	// keyed by pull request number.
+	Reviewers map[int][]string
+	Labels    map[int][]string
+	// InvalidReviewers are rejected by ValidateReviewers.
+	InvalidReviewers []string
+	// Closed holds the numbers of pull requests that have been merged or
+	// closed.
+	Closed map[int]bool
+	mutex  sync.Mutex
+}
+
+func NewFakeCodeHost() *FakeCodeHost {
+	return &FakeCodeHost{
+		URLPrefix: "https://codehost.example/pulls/",
+		Reviewers: map[int][]string{},
+		Labels:    map[int][]string{},
+		Closed:    map[int]bool{},
+	}
+}
+
+func (f *FakeCodeHost) ListPullRequests(_ context.Context, headBranch, baseBranch string) ([]PullRequest, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	pullRequests := make([]PullRequest, 0)
+	for _, pullRequest := range f.PullRequests {
+		if pullRequest.HeadBranch == headBranch && pullRequest.BaseBranch == baseBranch {
+			pullRequests = append(pullRequests, pullRequest)
+		}
+	}
+	return pullRequests, nil
+}
+
+func (f *FakeCodeHost) ListOpenPullRequests(_ context.Context, headBranchPrefix, baseBranch string) ([]PullRequest, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	pullRequests := make([]PullRequest, 0)
+	for _, pullRequest := range f.PullRequests {
+		if strings.HasPrefix(pullRequest.HeadBranch, headBranchPrefix) && pullRequest.BaseBranch == baseBranch && !f.Closed[pullRequest.Number] {
+			pullRequests = append(pullRequests, pullRequest)
+		}
+	}
+	return pullRequests, nil
+}
+
+func (f *FakeCodeHost) CreatePullRequest(_ context.Context, newPullRequest NewPullRequest) (PullRequest, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	number := len(f.PullRequests) + 1
+	pullRequest := PullRequest{
+		Number:     number,
+		URL:        fmt.Sprintf("%s%d", f.URLPrefix, number),
+		HeadBranch: newPullRequest.HeadBranch,
+		BaseBranch: newPullRequest.BaseBranch,
+		Title:      newPullRequest.Title,
+		Body:       newPullRequest.Body,
+	}
+	f.PullRequests = append(f.PullRequests, pullRequest)
+	return pullRequest, nil
+}
+
+// ValidateReviewers accepts all reviewers, unless InvalidReviewers is set.
+func (f *FakeCodeHost) ValidateReviewers(reviewers []string) error {
+	for _, reviewer := range reviewers {
+		if slices.Contains(f.InvalidReviewers, reviewer) {
+			return fmt.Errorf("invalid reviewer %q", reviewer)
+		}
+	}
+	return nil
+}
+
+func (f *FakeCodeHost) RequestReviewers(_ context.Context, number int, reviewers []string) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if err := f.checkExists(number); err != nil {
+		return err
+	}
+	f.Reviewers[number] = append(f.Reviewers[number], reviewers...)
+	return nil
+}
+
+func (f *FakeCodeHost) AddLabels(_ context.Context, number int, labels []string) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if err := f.checkExists(number); err != nil {
+		return err
+	}
+	for _, label := range labels {
+		if !slices.Contains(f.Labels[number], label) {
+			f.Labels[number] = append(f.Labels[number], label)
+		}
+	}
+	return nil
+}
+
+func (f *FakeCodeHost) checkExists(number int) error {
+	for _, pullRequest := range f.PullRequests {
+		if pullRequest.Number == number {
+			return nil
+		}
+	}
+	return fmt.Errorf("pull request %d not found", number)
+}
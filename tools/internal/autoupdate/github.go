@@ -0,0 +1,152 @@
+package autoupdate
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v80/github"
+)
+
+const githubRequestTimeout = 10 * time.Second
+
+// GitHub is a CodeHost backed by the GitHub API.
+type GitHub struct {
+	Client *github.Client
+	Owner  string
+	Repo   string
+}
+
+func NewGitHub(client *github.Client, owner, repo string) *GitHub {
+	return &GitHub{
+		Client: client,
+		Owner:  owner,
+		Repo:   repo,
+	}
+}
+
+func (gh *GitHub) ListPullRequests(ctx context.Context, headBranch, baseBranch string) ([]PullRequest, error) {
+	// When filtering pull requests by head branch, the github API
+	// requires that the head branch is in the format <owner>:<branch>.
+	// In the case of branches pushed using GITHUB_TOKEN in rancher/artifact-mirror,
+	// owner is "rancher". When running in a personal repo, setting GITHUB_TOKEN
+	// to a PAT makes owner the same as the user's github username.
+	requestContext, cancel := context.WithTimeout(ctx, githubRequestTimeout)
+	defer cancel()
+	ghPullRequests, _, err := gh.Client.PullRequests.List(requestContext, gh.Owner, gh.Repo, &github.PullRequestListOptions{
+		Head:  gh.Owner + ":" + headBranch,
+		Base:  baseBranch,
+		State: "all",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pull requests: %w", err)
+	}
+	pullRequests := make([]PullRequest, 0, len(ghPullRequests))
+	for _, ghPullRequest := range ghPullRequests {
+		pullRequests = append(pullRequests, convertGitHubPullRequest(ghPullRequest))
+	}
+	return pullRequests, nil
+}
+
+// ListOpenPullRequests lists the open pull requests into baseBranch. The
+// GitHub API cannot filter by a prefix of the head branch, so that is done
+// here.
+func (gh *GitHub) ListOpenPullRequests(ctx context.Context, headBranchPrefix, baseBranch string) ([]PullRequest, error) {
+	opts := &github.PullRequestListOptions{
+		Base:        baseBranch,
+		State:       "open",
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+	pullRequests := make([]PullRequest, 0)
+	for {
+		requestContext, cancel := context.WithTimeout(ctx, githubRequestTimeout)
+		ghPullRequests, resp, err := gh.Client.PullRequests.List(requestContext, gh.Owner, gh.Repo, opts)
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list pull requests: %w", err)
+		}
+		for _, ghPullRequest := range ghPullRequests {
+			if strings.HasPrefix(ghPullRequest.GetHead().GetRef(), headBranchPrefix) {
+				pullRequests = append(pullRequests, convertGitHubPullRequest(ghPullRequest))
+			}
+		}
+		if resp.NextPage == 0 {
+			return pullRequests, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+func (gh *GitHub) CreatePullRequest(ctx context.Context, newPullRequest NewPullRequest) (PullRequest, error) {
+	maintainerCanModify := true
+	ghNewPullRequest := &github.NewPullRequest{
+		Base:                &newPullRequest.BaseBranch,
+		Head:                &newPullRequest.HeadBranch,
+		Title:               &newPullRequest.Title,
+		Body:                &newPullRequest.Body,
+		MaintainerCanModify: &maintainerCanModify,
+	}
+	requestContext, cancel := context.WithTimeout(ctx, githubRequestTimeout)
+	defer cancel()
+	ghPullRequest, _, err := gh.Client.PullRequests.Create(requestContext, gh.Owner, gh.Repo, ghNewPullRequest)
+	if err != nil {
+		return PullRequest{}, fmt.Errorf("failed to create pull request: %w", err)
+	}
+	return convertGitHubPullRequest(ghPullRequest), nil
+}
+
+// ValidateReviewers checks that every team in reviewers belongs to the
+// repository owner, since the GitHub API only accepts those.
+func (gh *GitHub) ValidateReviewers(reviewers []string) error {
+	_, teams := splitReviewers(reviewers)
+	for _, team := range teams {
+		if org, _, _ := strings.Cut(team, "/"); org != gh.Owner {
+			return fmt.Errorf("team %q does not belong to %s", team, gh.Owner)
+		}
+	}
+	return nil
+}
+
+func (gh *GitHub) RequestReviewers(ctx context.Context, number int, reviewers []string) error {
+	if err := gh.ValidateReviewers(reviewers); err != nil {
+		return err
+	}
+	users, teams := splitReviewers(reviewers)
+	// The GitHub API expects teams without the org prefix.
+	teamSlugs := make([]string, 0, len(teams))
+	for _, team := range teams {
+		_, slug, _ := strings.Cut(team, "/")
+		teamSlugs = append(teamSlugs, slug)
+	}
+	requestContext, cancel := context.WithTimeout(ctx, githubRequestTimeout)
+	defer cancel()
+	_, _, err := gh.Client.PullRequests.RequestReviewers(requestContext, gh.Owner, gh.Repo, number, github.ReviewersRequest{
+		Reviewers:     users,
+		TeamReviewers: teamSlugs,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to request reviewers: %w", err)
+	}
+	return nil
+}
+
+func (gh *GitHub) AddLabels(ctx context.Context, number int, labels []string) error {
+	requestContext, cancel := context.WithTimeout(ctx, githubRequestTimeout)
+	defer cancel()
+	if _, _, err := gh.Client.Issues.AddLabelsToIssue(requestContext, gh.Owner, gh.Repo, number, labels); err != nil {
+		return fmt.Errorf("failed to add labels: %w", err)
+	}
+	return nil
+}
+
+func convertGitHubPullRequest(ghPullRequest *github.PullRequest) PullRequest {
+	return PullRequest{
+		Number:     ghPullRequest.GetNumber(),
+		URL:        ghPullRequest.GetHTMLURL(),
+		HeadBranch: ghPullRequest.GetHead().GetRef(),
+		BaseBranch: ghPullRequest.GetBase().GetRef(),
+		Title:      ghPullRequest.GetTitle(),
+		Body:       ghPullRequest.GetBody(),
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"errors"
 	"fmt"
 	"regexp"
+	"slices"
+	"time"
 
 	"github.com/Masterminds/semver/v3"
 	"github.com/google/go-github/v80/github"
@@ -16,9 +18,12 @@ import (
 // It returns the configured Artifacts with this tag.
 // It assumes that the artifacts have the same tags as the github releases.
 type GithubRelease struct {
-	Owner                     string
-	Repository                string
-	Artifacts                 []AutoupdateArtifactRef
+	Owner      string
+	Repository string
+	Artifacts  []AutoupdateArtifactRef
+	// IncludePrereleases causes releases that are marked as prereleases
+	// to be considered. Draft releases are never considered.
+	IncludePrereleases        bool                `json:",omitempty"`
 	LatestOnly                bool                `json:",omitempty"`
 	VersionConstraint         string              `json:",omitempty"`
 	compiledVersionConstraint *semver.Constraints `json:"-"`
@@ -27,28 +32,63 @@ type GithubRelease struct {
 	// used as the version.
 	VersionRegex         string         `json:",omitempty"`
 	compiledVersionRegex *regexp.Regexp `json:"-"`
+	// githubClient is used instead of the default client if set.
+	githubClient *github.Client `json:"-"`
 }
 
-func (gr *GithubRelease) GetUpdateArtifacts() ([]*config.Artifact, error) {
-	client := github.NewClient(nil)
+// versionedRelease is a github release along with the version that was
+// taken from its tag.
+type versionedRelease struct {
+	release *github.RepositoryRelease
+	version string
+}
 
-	var tags []string
-	if gr.LatestOnly {
-		latestTag, err := gr.getVersionFromLatestRelease(client)
-		if err != nil {
-			return nil, fmt.Errorf("failed to get tag from latest github release: %w", err)
-		}
-		tags = append(tags, latestTag)
-	} else {
-		ghTags, err := gr.getVersionsFromAllReleases(client)
-		if err != nil {
-			return nil, fmt.Errorf("failed to get tags from github releases: %w", err)
+// selectReleases returns the releases whose versions are kept by versions,
+// the Versions of the entry, so that only those are downloaded or cloned.
+// MinimumVersion is not applied, since it depends on the tags of each
+// artifact rather than on the release. If versions is nil, all releases are
+// returned.
+func selectReleases(releases []versionedRelease, versions *VersionPolicy) []versionedRelease {
+	if versions == nil {
+		return releases
+	}
+	allVersions := make([]string, 0, len(releases))
+	for _, release := range releases {
+		allVersions = append(allVersions, release.version)
+	}
+	selectedVersions := map[string]struct{}{}
+	for _, version := range versions.SelectTags(allVersions, nil) {
+		selectedVersions[version] = struct{}{}
+	}
+	return slices.DeleteFunc(releases, func(release versionedRelease) bool {
+		_, ok := selectedVersions[release.version]
+		return !ok
+	})
+}
+
+func (gr *GithubRelease) GetUpdateArtifacts(ctx context.Context) (UpdateArtifacts, error) {
+	releases, err := gr.getReleases(ctx)
+	if err != nil {
+		return UpdateArtifacts{}, err
+	}
+
+	tags := make([]string, 0, len(releases))
+	publishTimes := map[string]time.Time{}
+	for _, release := range releases {
+		tags = append(tags, release.version)
+		for _, artifactRef := range gr.Artifacts {
+			addPublishTime(publishTimes, artifactRef.SourceArtifact, release.version, release.release.GetPublishedAt().Time)
 		}
-		tags = append(tags, ghTags...)
 	}
 
-	artifacts := make([]*config.Artifact, 0, len(gr.Artifacts))
-	for _, sourceArtifact := range gr.Artifacts {
+	artifacts, err := newArtifactsWithTags(gr.Artifacts, tags)
+	return UpdateArtifacts{Artifacts: artifacts, PublishTimes: publishTimes}, err
+}
+
+// newArtifactsWithTags returns an Artifact with tags for each of refs.
+func newArtifactsWithTags(refs []AutoupdateArtifactRef, tags []string) ([]*config.Artifact, error) {
+	artifacts := make([]*config.Artifact, 0, len(refs))
+	for _, sourceArtifact := range refs {
 		artifact, err := config.NewArtifact(sourceArtifact.SourceArtifact, tags, sourceArtifact.TargetArtifactName, nil, nil)
 		if err != nil {
 			return nil, fmt.Errorf("failed to construct artifact from source artifact %q and tags %v: %w", sourceArtifact, tags, err)
@@ -58,17 +98,47 @@ func (gr *GithubRelease) GetUpdateArtifacts() ([]*config.Artifact, error) {
 	return artifacts, nil
 }
 
-func (gr *GithubRelease) getVersionsFromAllReleases(client *github.Client) ([]string, error) {
+// getReleases returns the releases selected by gr, along with their
+// versions.
+func (gr *GithubRelease) getReleases(ctx context.Context) ([]versionedRelease, error) {
+	client := gr.githubClient
+	if client == nil {
+		client = newGithubClient()
+	}
+
+	if gr.LatestOnly && gr.IncludePrereleases {
+		// The latest release endpoint never returns prereleases, so we
+		// take the most recent release from the list instead.
+		release, err := gr.getMostRecentRelease(ctx, client)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get tag from most recent github release: %w", err)
+		}
+		return release, nil
+	} else if gr.LatestOnly {
+		release, err := gr.getLatestRelease(ctx, client)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get tag from latest github release: %w", err)
+		}
+		return release, nil
+	}
+	releases, err := gr.getAllReleases(ctx, client)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tags from github releases: %w", err)
+	}
+	return releases, nil
+}
+
+func (gr *GithubRelease) getAllReleases(ctx context.Context, client *github.Client) ([]versionedRelease, error) {
 	opt := &github.ListOptions{}
-	var versions []string
+	var versionedReleases []versionedRelease
 	for {
-		releases, resp, err := client.Repositories.ListReleases(context.Background(), gr.Owner, gr.Repository, opt)
+		releases, resp, err := client.Repositories.ListReleases(ctx, gr.Owner, gr.Repository, opt)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get releases: %w", err)
 		}
 
 		for _, release := range releases {
-			if release.GetPrerelease() || release.GetDraft() {
+			if gr.skipRelease(release) {
 				continue
 			}
 			tag := release.GetTagName()
@@ -79,7 +149,7 @@ func (gr *GithubRelease) getVersionsFromAllReleases(client *github.Client) ([]st
 			if version == "" {
 				continue
 			}
-			versions = append(versions, version)
+			versionedReleases = append(versionedReleases, versionedRelease{release: release, version: version})
 		}
 
 		if resp.NextPage == 0 {
@@ -88,26 +158,76 @@ func (gr *GithubRelease) getVersionsFromAllReleases(client *github.Client) ([]st
 		opt.Page = resp.NextPage
 	}
 
-	return versions, nil
+	return versionedReleases, nil
 }
 
-func (gr *GithubRelease) getVersionFromLatestRelease(client *github.Client) (string, error) {
-	release, _, err := client.Repositories.GetLatestRelease(context.Background(), gr.Owner, gr.Repository)
+// getMostRecentRelease returns the most recently created release that is
+// not skipped, if there is one.
+func (gr *GithubRelease) getMostRecentRelease(ctx context.Context, client *github.Client) ([]versionedRelease, error) {
+	opt := &github.ListOptions{}
+	for {
+		releases, resp, err := client.Repositories.ListReleases(ctx, gr.Owner, gr.Repository, opt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get releases: %w", err)
+		}
+
+		for _, release := range releases {
+			if gr.skipRelease(release) {
+				continue
+			}
+			version, err := gr.processTagToVersion(release.GetTagName())
+			if err != nil {
+				return nil, fmt.Errorf("failed to process tag into version: %w", err)
+			}
+			if version != "" {
+				return []versionedRelease{{release: release, version: version}}, nil
+			}
+		}
+
+		if resp.NextPage == 0 {
+			return nil, nil
+		}
+		opt.Page = resp.NextPage
+	}
+}
+
+func (gr *GithubRelease) skipRelease(release *github.RepositoryRelease) bool {
+	if release.GetDraft() {
+		return true
+	}
+	return release.GetPrerelease() && !gr.IncludePrereleases
+}
+
+func (gr *GithubRelease) getLatestRelease(ctx context.Context, client *github.Client) ([]versionedRelease, error) {
+	release, _, err := client.Repositories.GetLatestRelease(ctx, gr.Owner, gr.Repository)
 	if err != nil {
-		return "", fmt.Errorf("failed to get latest release: %w", err)
+		return nil, fmt.Errorf("failed to get latest release: %w", err)
 	}
 	tag := release.GetTagName()
 	version, err := gr.processTagToVersion(tag)
 	if err != nil {
-		return "", fmt.Errorf("failed to process tag into version: %w", err)
+		return nil, fmt.Errorf("failed to process tag into version: %w", err)
 	}
-	return version, nil
+	if version == "" {
+		return nil, nil
+	}
+	return []versionedRelease{{release: release, version: version}}, nil
 }
 
 func (gr *GithubRelease) processTagToVersion(tag string) (string, error) {
+	return processTagToVersion(tag, gr.compiledVersionRegex, gr.compiledVersionConstraint)
+}
+
+// processTagToVersion returns the version contained in tag. If
+// versionRegex is not nil, tags that do not match it produce an empty
+// version, and if it contains a match group, the contents of the match
+// group are used as the version. If versionConstraint is not nil, versions
+// that do not satisfy it are also returned as empty, and versions that are
+// not valid semantic versions produce an error.
+func processTagToVersion(tag string, versionRegex *regexp.Regexp, versionConstraint *semver.Constraints) (string, error) {
 	version := tag
-	if gr.VersionRegex != "" {
-		matches := gr.compiledVersionRegex.FindStringSubmatch(tag)
+	if versionRegex != nil {
+		matches := versionRegex.FindStringSubmatch(tag)
 		switch len(matches) {
 		case 0:
 			return "", nil
@@ -117,12 +237,12 @@ func (gr *GithubRelease) processTagToVersion(tag string) (string, error) {
 			version = matches[1]
 		}
 	}
-	if gr.VersionConstraint != "" {
+	if versionConstraint != nil {
 		version, err := semver.NewVersion(version)
 		if err != nil {
 			return "", fmt.Errorf("error parsing release version: %w", err)
 		}
-		if !gr.compiledVersionConstraint.Check(version) {
+		if !versionConstraint.Check(version) {
 			return "", nil
 		}
 	}
@@ -149,6 +269,10 @@ func (gr *GithubRelease) Validate() error {
 		if err != nil {
 			return fmt.Errorf("invalid VersionConstraint: %w", err)
 		}
+		// Constraints only match prereleases if they contain a prerelease
+		// themselves, which would drop the prereleases that
+		// IncludePrereleases asks for.
+		compiledVersionConstraint.IncludePrerelease = gr.IncludePrereleases
 		gr.compiledVersionConstraint = compiledVersionConstraint
 	}
 	if gr.VersionRegex != "" {
@@ -1,13 +1,31 @@
 package autoupdate
 
 import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"regexp"
 	"testing"
 
 	"github.com/Masterminds/semver/v3"
+	"github.com/google/go-github/v80/github"
 	"github.com/stretchr/testify/assert"
 )
 
+// newTestGithubClient returns a github client that sends its requests to
+// handler.
+func newTestGithubClient(t *testing.T, handler http.Handler) *github.Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	client := github.NewClient(nil)
+	baseURL, err := url.Parse(server.URL + "/")
+	assert.NoError(t, err)
+	client.BaseURL = baseURL
+	return client
+}
+
 func TestGithubRelease(t *testing.T) {
 	t.Run("Validate", func(t *testing.T) {
 		type testCase struct {
@@ -229,4 +247,84 @@ func TestGithubRelease(t *testing.T) {
 			})
 		}
 	})
+
+	t.Run("GetUpdateArtifacts", func(t *testing.T) {
+		releases := []*github.RepositoryRelease{
+			{TagName: github.Ptr("v1.3.0-rc1"), Prerelease: github.Ptr(true)},
+			{TagName: github.Ptr("v1.3.0-draft"), Draft: github.Ptr(true)},
+			{TagName: github.Ptr("v1.2.0")},
+			{TagName: github.Ptr("v1.1.0")},
+		}
+		mux := http.NewServeMux()
+		mux.HandleFunc("GET /repos/test-owner/test-repo/releases", func(w http.ResponseWriter, r *http.Request) {
+			assert.NoError(t, json.NewEncoder(w).Encode(releases))
+		})
+		mux.HandleFunc("GET /repos/test-owner/test-repo/releases/latest", func(w http.ResponseWriter, r *http.Request) {
+			assert.NoError(t, json.NewEncoder(w).Encode(releases[2]))
+		})
+		client := newTestGithubClient(t, mux)
+
+		type testCase struct {
+			Message       string
+			GithubRelease *GithubRelease
+			ExpectedTags  []string
+		}
+		testCases := []testCase{
+			{
+				Message: "should skip prereleases and drafts by default",
+				GithubRelease: &GithubRelease{
+					VersionConstraint: ">=1.0.0-0",
+				},
+				ExpectedTags: []string{"v1.2.0", "v1.1.0"},
+			},
+			{
+				Message: "should include prereleases but not drafts when IncludePrereleases=true",
+				GithubRelease: &GithubRelease{
+					IncludePrereleases: true,
+					VersionConstraint:  ">=1.0.0-0",
+				},
+				ExpectedTags: []string{"v1.3.0-rc1", "v1.2.0", "v1.1.0"},
+			},
+			{
+				Message: "should include prereleases with a VersionConstraint without prerelease when IncludePrereleases=true",
+				GithubRelease: &GithubRelease{
+					IncludePrereleases: true,
+					VersionConstraint:  ">=1.2.0",
+				},
+				ExpectedTags: []string{"v1.3.0-rc1", "v1.2.0"},
+			},
+			{
+				Message: "should return latest release when LatestOnly=true",
+				GithubRelease: &GithubRelease{
+					LatestOnly: true,
+				},
+				ExpectedTags: []string{"v1.2.0"},
+			},
+			{
+				Message: "should return most recent prerelease when LatestOnly=true and IncludePrereleases=true",
+				GithubRelease: &GithubRelease{
+					LatestOnly:         true,
+					IncludePrereleases: true,
+				},
+				ExpectedTags: []string{"v1.3.0-rc1"},
+			},
+		}
+		for _, testCase := range testCases {
+			t.Run(testCase.Message, func(t *testing.T) {
+				githubRelease := testCase.GithubRelease
+				githubRelease.Owner = "test-owner"
+				githubRelease.Repository = "test-repo"
+				githubRelease.Artifacts = []AutoupdateArtifactRef{{SourceArtifact: "rancher/rancher"}}
+				githubRelease.githubClient = client
+				assert.NoError(t, githubRelease.Validate())
+				updateArtifacts, err := githubRelease.GetUpdateArtifacts(t.Context())
+				assert.NoError(t, err)
+				artifacts := updateArtifacts.Artifacts
+				if assert.Len(t, artifacts, 1) {
+					assert.Equal(t, "rancher/rancher", artifacts[0].SourceArtifact)
+					assert.Equal(t, testCase.ExpectedTags, artifacts[0].Tags)
+				}
+			})
+		}
+	})
 }
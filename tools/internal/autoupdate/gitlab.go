@@ -0,0 +1,202 @@
+package autoupdate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// GitLab is a CodeHost backed by the GitLab REST API. Merge requests are
+// treated as pull requests.
+type GitLab struct {
+	// BaseURL is the URL of the GitLab API, for example https://gitlab.com/api/v4.
+	BaseURL string
+	// Project is the path of the project, for example rancher/artifact-mirror.
+	Project string
+	Token   string
+	Client  *http.Client
+}
+
+func NewGitLab(baseURL, project, token string) *GitLab {
+	return &GitLab{
+		BaseURL: strings.TrimSuffix(baseURL, "/"),
+		Project: project,
+		Token:   token,
+		Client:  &http.Client{Timeout: githubRequestTimeout},
+	}
+}
+
+type gitLabMergeRequest struct {
+	IID          int    `json:"iid"`
+	WebURL       string `json:"web_url"`
+	SourceBranch string `json:"source_branch"`
+	TargetBranch string `json:"target_branch"`
+	Title        string `json:"title"`
+	Description  string `json:"description"`
+}
+
+type gitLabUser struct {
+	ID int `json:"id"`
+}
+
+func (gl *GitLab) ListPullRequests(ctx context.Context, headBranch, baseBranch string) ([]PullRequest, error) {
+	params := url.Values{}
+	params.Add("source_branch", headBranch)
+	params.Add("target_branch", baseBranch)
+	params.Add("state", "all")
+	var mergeRequests []gitLabMergeRequest
+	if err := gl.do(ctx, http.MethodGet, gl.projectPath("merge_requests")+"?"+params.Encode(), nil, &mergeRequests); err != nil {
+		return nil, fmt.Errorf("failed to list merge requests: %w", err)
+	}
+	pullRequests := make([]PullRequest, 0, len(mergeRequests))
+	for _, mergeRequest := range mergeRequests {
+		pullRequests = append(pullRequests, mergeRequest.toPullRequest())
+	}
+	return pullRequests, nil
+}
+
+// ListOpenPullRequests lists the open merge requests into baseBranch. The
+// GitLab API cannot filter by a prefix of the source branch, so that is
+// done here.
+func (gl *GitLab) ListOpenPullRequests(ctx context.Context, headBranchPrefix, baseBranch string) ([]PullRequest, error) {
+	params := url.Values{}
+	params.Add("target_branch", baseBranch)
+	params.Add("state", "opened")
+	params.Add("per_page", "100")
+	pullRequests := make([]PullRequest, 0)
+	for page := 1; ; page++ {
+		params.Set("page", strconv.Itoa(page))
+		var mergeRequests []gitLabMergeRequest
+		if err := gl.do(ctx, http.MethodGet, gl.projectPath("merge_requests")+"?"+params.Encode(), nil, &mergeRequests); err != nil {
+			return nil, fmt.Errorf("failed to list merge requests: %w", err)
+		}
+		for _, mergeRequest := range mergeRequests {
+			if strings.HasPrefix(mergeRequest.SourceBranch, headBranchPrefix) {
+				pullRequests = append(pullRequests, mergeRequest.toPullRequest())
+			}
+		}
+		if len(mergeRequests) < 100 {
+			return pullRequests, nil
+		}
+	}
+}
+
+func (gl *GitLab) CreatePullRequest(ctx context.Context, newPullRequest NewPullRequest) (PullRequest, error) {
+	payload := map[string]any{
+		"source_branch":        newPullRequest.HeadBranch,
+		"target_branch":        newPullRequest.BaseBranch,
+		"title":                newPullRequest.Title,
+		"description":          newPullRequest.Body,
+		"allow_collaboration":  true,
+		"remove_source_branch": true,
+	}
+	var mergeRequest gitLabMergeRequest
+	if err := gl.do(ctx, http.MethodPost, gl.projectPath("merge_requests"), payload, &mergeRequest); err != nil {
+		return PullRequest{}, fmt.Errorf("failed to create merge request: %w", err)
+	}
+	return mergeRequest.toPullRequest(), nil
+}
+
+// ValidateReviewers rejects teams, since GitLab has no concept of team
+// reviewers.
+func (gl *GitLab) ValidateReviewers(reviewers []string) error {
+	if _, teams := splitReviewers(reviewers); len(teams) > 0 {
+		return fmt.Errorf("GitLab does not support team reviewers: %s", strings.Join(teams, ", "))
+	}
+	return nil
+}
+
+// RequestReviewers sets the reviewers of a merge request.
+func (gl *GitLab) RequestReviewers(ctx context.Context, number int, reviewers []string) error {
+	if err := gl.ValidateReviewers(reviewers); err != nil {
+		return err
+	}
+	users, _ := splitReviewers(reviewers)
+	reviewerIDs := make([]int, 0, len(users))
+	for _, username := range users {
+		var found []gitLabUser
+		if err := gl.do(ctx, http.MethodGet, "/users?username="+url.QueryEscape(username), nil, &found); err != nil {
+			return fmt.Errorf("failed to look up user %s: %w", username, err)
+		}
+		if len(found) != 1 {
+			return fmt.Errorf("found %d users with username %s", len(found), username)
+		}
+		reviewerIDs = append(reviewerIDs, found[0].ID)
+	}
+	payload := map[string]any{
+		"reviewer_ids": reviewerIDs,
+	}
+	if err := gl.do(ctx, http.MethodPut, gl.projectPath("merge_requests", strconv.Itoa(number)), payload, nil); err != nil {
+		return fmt.Errorf("failed to set reviewers: %w", err)
+	}
+	return nil
+}
+
+func (gl *GitLab) AddLabels(ctx context.Context, number int, labels []string) error {
+	payload := map[string]any{
+		"add_labels": strings.Join(labels, ","),
+	}
+	if err := gl.do(ctx, http.MethodPut, gl.projectPath("merge_requests", strconv.Itoa(number)), payload, nil); err != nil {
+		return fmt.Errorf("failed to add labels: %w", err)
+	}
+	return nil
+}
+
+func (gl *GitLab) projectPath(parts ...string) string {
+	return "/projects/" + url.PathEscape(gl.Project) + "/" + strings.Join(parts, "/")
+}
+
+func (gl *GitLab) do(ctx context.Context, method, path string, payload, result any) error {
+	var body io.Reader
+	if payload != nil {
+		encoded, err := json.Marshal(payload)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request body: %w", err)
+		}
+		body = bytes.NewReader(encoded)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, gl.BaseURL+path, body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("PRIVATE-TOKEN", gl.Token)
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := gl.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response body: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("request to %s failed with status %s and body %s", req.URL.String(), resp.Status, string(respBody))
+	}
+	if result == nil {
+		return nil
+	}
+	if err := json.Unmarshal(respBody, result); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+func (mr gitLabMergeRequest) toPullRequest() PullRequest {
+	return PullRequest{
+		Number:     mr.IID,
+		URL:        mr.WebURL,
+		HeadBranch: mr.SourceBranch,
+		BaseBranch: mr.TargetBranch,
+		Title:      mr.Title,
+		Body:       mr.Description,
+	}
+}
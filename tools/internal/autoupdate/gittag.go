@@ -0,0 +1,105 @@
+package autoupdate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/google/go-github/v80/github"
+)
+
+// GitTag retrieves the tags of a github repository that match VersionRegex
+// and VersionConstraint. It returns the configured Artifacts with these
+// tags. It is useful for projects that tag versions without creating
+// github releases for them.
+type GitTag struct {
+	Owner                     string
+	Repository                string
+	Artifacts                 []AutoupdateArtifactRef
+	VersionConstraint         string              `json:",omitempty"`
+	compiledVersionConstraint *semver.Constraints `json:"-"`
+	// Only tags matching VersionRegex will be considered. If VersionRegex
+	// contains a match group, the contents of the match group will be
+	// used as the version.
+	VersionRegex         string         `json:",omitempty"`
+	compiledVersionRegex *regexp.Regexp `json:"-"`
+	// githubClient is used instead of the default client if set.
+	githubClient *github.Client `json:"-"`
+}
+
+func (gt *GitTag) GetUpdateArtifacts(ctx context.Context) (UpdateArtifacts, error) {
+	client := gt.githubClient
+	if client == nil {
+		client = newGithubClient()
+	}
+
+	tags, err := gt.getVersionsFromTags(ctx, client)
+	if err != nil {
+		return UpdateArtifacts{}, fmt.Errorf("failed to get tags from git tags: %w", err)
+	}
+
+	artifacts, err := newArtifactsWithTags(gt.Artifacts, tags)
+	return UpdateArtifacts{Artifacts: artifacts}, err
+}
+
+func (gt *GitTag) getVersionsFromTags(ctx context.Context, client *github.Client) ([]string, error) {
+	versions := make([]string, 0)
+	opt := &github.ListOptions{}
+	for {
+		repoTags, resp, err := client.Repositories.ListTags(ctx, gt.Owner, gt.Repository, opt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get tags: %w", err)
+		}
+
+		for _, repoTag := range repoTags {
+			version, err := processTagToVersion(repoTag.GetName(), gt.compiledVersionRegex, gt.compiledVersionConstraint)
+			if err != nil {
+				// Unlike releases, repositories often have tags that are
+				// not versions, like "latest" or "nightly", so tags whose
+				// version cannot be parsed are skipped rather than
+				// stopping the update.
+				continue
+			}
+			if version != "" {
+				versions = append(versions, version)
+			}
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+	return versions, nil
+}
+
+func (gt *GitTag) Validate() error {
+	if gt.Owner == "" {
+		return errors.New("must specify Owner")
+	}
+	if gt.Repository == "" {
+		return errors.New("must specify Repository")
+	}
+	if gt.Artifacts == nil {
+		return errors.New("must specify Artifacts")
+	} else if len(gt.Artifacts) == 0 {
+		return errors.New("must specify at least one element for Artifacts")
+	}
+	if gt.VersionConstraint != "" {
+		compiledVersionConstraint, err := semver.NewConstraint(gt.VersionConstraint)
+		if err != nil {
+			return fmt.Errorf("invalid VersionConstraint: %w", err)
+		}
+		gt.compiledVersionConstraint = compiledVersionConstraint
+	}
+	if gt.VersionRegex != "" {
+		compiledVersionRegex, err := regexp.Compile(gt.VersionRegex)
+		if err != nil {
+			return fmt.Errorf("invalid VersionRegex: %w", err)
+		}
+		gt.compiledVersionRegex = compiledVersionRegex
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package autoupdate
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-github/v80/github"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGitTag(t *testing.T) {
+	t.Run("Validate", func(t *testing.T) {
+		type testCase struct {
+			Message       string
+			GitTag        *GitTag
+			ExpectedError string
+		}
+		testCases := []testCase{
+			{
+				Message: "should return nil for a valid GitTag",
+				GitTag: &GitTag{
+					Owner:             "test-owner",
+					Repository:        "test-repo",
+					Artifacts:         []AutoupdateArtifactRef{{SourceArtifact: "rancher/rancher"}},
+					VersionConstraint: ">3.5.10",
+					VersionRegex:      "^v(.*)$",
+				},
+				ExpectedError: "",
+			},
+			{
+				Message: "should return error for empty Owner",
+				GitTag: &GitTag{
+					Repository: "test-repo",
+					Artifacts:  []AutoupdateArtifactRef{{SourceArtifact: "rancher/rancher"}},
+				},
+				ExpectedError: "must specify Owner",
+			},
+			{
+				Message: "should return error for empty Repository",
+				GitTag: &GitTag{
+					Owner:     "test-owner",
+					Artifacts: []AutoupdateArtifactRef{{SourceArtifact: "rancher/rancher"}},
+				},
+				ExpectedError: "must specify Repository",
+			},
+			{
+				Message: "should return error for empty Artifacts",
+				GitTag: &GitTag{
+					Owner:      "test-owner",
+					Repository: "test-repo",
+					Artifacts:  []AutoupdateArtifactRef{},
+				},
+				ExpectedError: "must specify at least one element for Artifacts",
+			},
+			{
+				Message: "should return error for invalid version constraint",
+				GitTag: &GitTag{
+					Owner:             "test-owner",
+					Repository:        "test-repo",
+					Artifacts:         []AutoupdateArtifactRef{{SourceArtifact: "rancher/rancher"}},
+					VersionConstraint: "InvalidVersionConstraint",
+				},
+				ExpectedError: "invalid VersionConstraint: improper constraint: InvalidVersionConstraint",
+			},
+			{
+				Message: "should return error for invalid version regex",
+				GitTag: &GitTag{
+					Owner:        "test-owner",
+					Repository:   "test-repo",
+					Artifacts:    []AutoupdateArtifactRef{{SourceArtifact: "rancher/rancher"}},
+					VersionRegex: "v[asdf[",
+				},
+				ExpectedError: "invalid VersionRegex: error parsing regexp: missing closing ]: `[asdf[`",
+			},
+		}
+		for _, testCase := range testCases {
+			t.Run(testCase.Message, func(t *testing.T) {
+				err := testCase.GitTag.Validate()
+				if testCase.ExpectedError == "" {
+					assert.Nil(t, err)
+				} else {
+					assert.EqualError(t, err, testCase.ExpectedError)
+				}
+			})
+		}
+	})
+
+	t.Run("GetUpdateArtifacts should page through tags, apply regex and constraint and skip non-semver tags", func(t *testing.T) {
+		pages := [][]*github.RepositoryTag{
+			{{Name: github.Ptr("v1.3.0")}, {Name: github.Ptr("chart-v1.3.0")}},
+			{{Name: github.Ptr("v1.2.0")}, {Name: github.Ptr("vnext")}, {Name: github.Ptr("v0.9.0")}},
+		}
+		mux := http.NewServeMux()
+		mux.HandleFunc("GET /repos/test-owner/test-repo/tags", func(w http.ResponseWriter, r *http.Request) {
+			page := 0
+			if r.URL.Query().Get("page") == "2" {
+				page = 1
+			} else {
+				w.Header().Set("Link", fmt.Sprintf(`<http://%s%s?page=2>; rel="next"`, r.Host, r.URL.Path))
+			}
+			assert.NoError(t, json.NewEncoder(w).Encode(pages[page]))
+		})
+
+		gitTag := &GitTag{
+			Owner:             "test-owner",
+			Repository:        "test-repo",
+			Artifacts:         []AutoupdateArtifactRef{{SourceArtifact: "rancher/rancher", TargetArtifactName: "rancher"}},
+			VersionConstraint: ">=1.0.0",
+			VersionRegex:      "^v.*$",
+			githubClient:      newTestGithubClient(t, mux),
+		}
+		assert.NoError(t, gitTag.Validate())
+		updateArtifacts, err := gitTag.GetUpdateArtifacts(t.Context())
+		assert.NoError(t, err)
+		artifacts := updateArtifacts.Artifacts
+		if assert.Len(t, artifacts, 1) {
+			assert.Equal(t, "rancher/rancher", artifacts[0].SourceArtifact)
+			assert.Equal(t, "rancher", artifacts[0].TargetArtifactName())
+			assert.Equal(t, []string{"v1.3.0", "v1.2.0"}, artifacts[0].Tags)
+		}
+	})
+}
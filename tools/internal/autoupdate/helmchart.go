@@ -0,0 +1,172 @@
+package autoupdate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	"oras.land/oras-go/v2/registry/remote"
+	"sigs.k8s.io/yaml"
+)
+
+const ociScheme = "oci://"
+
+// HelmChart retrieves the versions of a helm chart that match VersionConstraint,
+// and returns the configured Artifacts with these versions as tags. It is
+// used to mirror the charts themselves, as opposed to HelmLatest, which
+// finds the images that a chart uses.
+type HelmChart struct {
+	// Repository is either the URL of a classic helm chart repository that
+	// serves an index.yaml, or an oci:// URL under which the chart is stored.
+	Repository string
+	// Chart is the name of the chart in Repository.
+	Chart                     string
+	Artifacts                 []AutoupdateArtifactRef
+	VersionConstraint         string              `json:",omitempty"`
+	compiledVersionConstraint *semver.Constraints `json:"-"`
+	// plainHTTP causes OCI repositories to be accessed over HTTP.
+	plainHTTP bool `json:"-"`
+	// credentials are used to authenticate to OCI repositories.
+	credentials *RegistryCredentials `json:"-"`
+}
+
+// helmRepositoryIndex is the part of a helm repository's index.yaml that
+// we need.
+type helmRepositoryIndex struct {
+	Entries map[string][]struct {
+		Version string    `json:"version"`
+		Created time.Time `json:"created"`
+	} `json:"entries"`
+}
+
+func (hc *HelmChart) GetUpdateArtifacts(ctx context.Context) (UpdateArtifacts, error) {
+	var versions []string
+	var creationTimes map[string]time.Time
+	var err error
+	if strings.HasPrefix(hc.Repository, ociScheme) {
+		versions, err = hc.getVersionsFromOCIRepository(ctx)
+	} else {
+		versions, creationTimes, err = hc.getVersionsFromIndex(ctx)
+	}
+	if err != nil {
+		return UpdateArtifacts{}, fmt.Errorf("failed to get versions of chart %s: %w", hc.Chart, err)
+	}
+
+	tags := make([]string, 0, len(versions))
+	publishTimes := map[string]time.Time{}
+	for _, version := range versions {
+		parsedVersion, err := semver.NewVersion(version)
+		if err != nil {
+			continue
+		}
+		if hc.compiledVersionConstraint != nil && !hc.compiledVersionConstraint.Check(parsedVersion) {
+			continue
+		}
+		// OCI tags may not contain "+", so helm replaces it with "_"
+		// when pushing charts to OCI registries.
+		tag := strings.ReplaceAll(version, "+", "_")
+		if !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+		for _, artifactRef := range hc.Artifacts {
+			addPublishTime(publishTimes, artifactRef.SourceArtifact, tag, creationTimes[version])
+		}
+	}
+
+	artifacts, err := newArtifactsWithTags(hc.Artifacts, tags)
+	return UpdateArtifacts{Artifacts: artifacts, PublishTimes: publishTimes}, err
+}
+
+// getVersionsFromIndex returns the versions of the chart in the index of
+// the repository, along with their creation times.
+func (hc *HelmChart) getVersionsFromIndex(ctx context.Context) ([]string, map[string]time.Time, error) {
+	indexURL := strings.TrimSuffix(hc.Repository, "/") + "/index.yaml"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, indexURL, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := doRequestWithRetries(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch index: %w", err)
+	}
+	defer resp.Body.Close()
+	contents, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read index: %w", err)
+	}
+
+	index := &helmRepositoryIndex{}
+	if err := yaml.Unmarshal(contents, index); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse index: %w", err)
+	}
+	entries, ok := index.Entries[hc.Chart]
+	if !ok {
+		return nil, nil, errors.New("chart not found in index")
+	}
+
+	versions := make([]string, 0, len(entries))
+	creationTimes := make(map[string]time.Time, len(entries))
+	for _, entry := range entries {
+		versions = append(versions, entry.Version)
+		creationTimes[entry.Version] = entry.Created
+	}
+	return versions, creationTimes, nil
+}
+
+// ociReference returns the reference of the chart in an OCI Repository,
+// without a tag.
+func (hc *HelmChart) ociReference() string {
+	return strings.TrimSuffix(strings.TrimPrefix(hc.Repository, ociScheme), "/") + "/" + hc.Chart
+}
+
+func (hc *HelmChart) getVersionsFromOCIRepository(ctx context.Context) ([]string, error) {
+	repo, err := remote.NewRepository(hc.ociReference())
+	if err != nil {
+		return nil, fmt.Errorf("failed to instantiate repository: %w", err)
+	}
+	repo.PlainHTTP = hc.plainHTTP
+	repo.Client = NewAuthClient(hc.credentials)
+
+	versions := make([]string, 0)
+	err = repo.Tags(ctx, "", func(tags []string) error {
+		for _, tag := range tags {
+			versions = append(versions, strings.ReplaceAll(tag, "_", "+"))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags: %w", err)
+	}
+	return versions, nil
+}
+
+func (hc *HelmChart) Validate() error {
+	if hc.Repository == "" {
+		return errors.New("must specify Repository")
+	}
+	if !strings.HasPrefix(hc.Repository, ociScheme) && !strings.HasPrefix(hc.Repository, "https://") && !strings.HasPrefix(hc.Repository, "http://") {
+		return errors.New("Repository must start with oci://, https:// or http://")
+	}
+	if hc.Chart == "" {
+		return errors.New("must specify Chart")
+	}
+	if hc.Artifacts == nil {
+		return errors.New("must specify Artifacts")
+	} else if len(hc.Artifacts) == 0 {
+		return errors.New("must specify at least one element for Artifacts")
+	}
+	if hc.VersionConstraint != "" {
+		compiledVersionConstraint, err := semver.NewConstraint(hc.VersionConstraint)
+		if err != nil {
+			return fmt.Errorf("invalid VersionConstraint: %w", err)
+		}
+		hc.compiledVersionConstraint = compiledVersionConstraint
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package autoupdate
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHelmChart(t *testing.T) {
+	t.Run("Validate", func(t *testing.T) {
+		type testCase struct {
+			Message       string
+			HelmChart     *HelmChart
+			ExpectedError string
+		}
+		testCases := []testCase{
+			{
+				Message: "should return nil for a valid HelmChart with an index.yaml repository",
+				HelmChart: &HelmChart{
+					Repository:        "https://charts.rancher.io",
+					Chart:             "rancher",
+					Artifacts:         []AutoupdateArtifactRef{{SourceArtifact: "charts.rancher.io/rancher"}},
+					VersionConstraint: ">=2.10.0",
+				},
+				ExpectedError: "",
+			},
+			{
+				Message: "should return nil for a valid HelmChart with an OCI repository",
+				HelmChart: &HelmChart{
+					Repository: "oci://dp.apps.rancher.io/charts",
+					Chart:      "redis",
+					Artifacts:  []AutoupdateArtifactRef{{SourceArtifact: "dp.apps.rancher.io/charts/redis"}},
+				},
+				ExpectedError: "",
+			},
+			{
+				Message: "should return error for empty Repository",
+				HelmChart: &HelmChart{
+					Chart:     "redis",
+					Artifacts: []AutoupdateArtifactRef{{SourceArtifact: "dp.apps.rancher.io/charts/redis"}},
+				},
+				ExpectedError: "must specify Repository",
+			},
+			{
+				Message: "should return error for Repository with unknown scheme",
+				HelmChart: &HelmChart{
+					Repository: "dp.apps.rancher.io/charts",
+					Chart:      "redis",
+					Artifacts:  []AutoupdateArtifactRef{{SourceArtifact: "dp.apps.rancher.io/charts/redis"}},
+				},
+				ExpectedError: "Repository must start with oci://, https:// or http://",
+			},
+			{
+				Message: "should return error for empty Chart",
+				HelmChart: &HelmChart{
+					Repository: "oci://dp.apps.rancher.io/charts",
+					Artifacts:  []AutoupdateArtifactRef{{SourceArtifact: "dp.apps.rancher.io/charts/redis"}},
+				},
+				ExpectedError: "must specify Chart",
+			},
+			{
+				Message: "should return error for empty Artifacts",
+				HelmChart: &HelmChart{
+					Repository: "oci://dp.apps.rancher.io/charts",
+					Chart:      "redis",
+					Artifacts:  []AutoupdateArtifactRef{},
+				},
+				ExpectedError: "must specify at least one element for Artifacts",
+			},
+			{
+				Message: "should return error for invalid version constraint",
+				HelmChart: &HelmChart{
+					Repository:        "oci://dp.apps.rancher.io/charts",
+					Chart:             "redis",
+					Artifacts:         []AutoupdateArtifactRef{{SourceArtifact: "dp.apps.rancher.io/charts/redis"}},
+					VersionConstraint: "InvalidVersionConstraint",
+				},
+				ExpectedError: "invalid VersionConstraint: improper constraint: InvalidVersionConstraint",
+			},
+		}
+		for _, testCase := range testCases {
+			t.Run(testCase.Message, func(t *testing.T) {
+				err := testCase.HelmChart.Validate()
+				if testCase.ExpectedError == "" {
+					assert.Nil(t, err)
+				} else {
+					assert.EqualError(t, err, testCase.ExpectedError)
+				}
+			})
+		}
+	})
+
+	t.Run("GetUpdateArtifacts should read versions from index.yaml", func(t *testing.T) {
+		mux := http.NewServeMux()
+		mux.HandleFunc("GET /index.yaml", func(w http.ResponseWriter, r *http.Request) {
+			_, err := w.Write([]byte(`apiVersion: v1
+entries:
+  rancher:
+  - version: 2.11.1
+    created: "2025-05-01T10:00:00Z"
+  - version: 2.10.3+up1
+    created: "2025-03-01T10:00:00Z"
+  - version: 2.9.0
+  - version: 2.12.0-rc1
+  other:
+  - version: 3.0.0
+`))
+			assert.NoError(t, err)
+		})
+		server := httptest.NewServer(mux)
+		t.Cleanup(server.Close)
+
+		helmChart := &HelmChart{
+			Repository:        server.URL + "/",
+			Chart:             "rancher",
+			Artifacts:         []AutoupdateArtifactRef{{SourceArtifact: "registry.rancher.com/charts/rancher"}},
+			VersionConstraint: ">=2.10.0",
+		}
+		assert.NoError(t, helmChart.Validate())
+		updateArtifacts, err := helmChart.GetUpdateArtifacts(t.Context())
+		assert.NoError(t, err)
+		artifacts := updateArtifacts.Artifacts
+		if assert.Len(t, artifacts, 1) {
+			assert.Equal(t, "registry.rancher.com/charts/rancher", artifacts[0].SourceArtifact)
+			assert.Equal(t, []string{"2.11.1", "2.10.3_up1"}, artifacts[0].Tags)
+		}
+
+		assert.Equal(t, map[string]time.Time{
+			"registry.rancher.com/charts/rancher:2.11.1":     time.Date(2025, 5, 1, 10, 0, 0, 0, time.UTC),
+			"registry.rancher.com/charts/rancher:2.10.3_up1": time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC),
+		}, updateArtifacts.PublishTimes)
+	})
+
+	t.Run("GetUpdateArtifacts should return error for chart missing from index.yaml", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, err := w.Write([]byte("entries: {}\n"))
+			assert.NoError(t, err)
+		}))
+		t.Cleanup(server.Close)
+
+		helmChart := &HelmChart{
+			Repository: server.URL,
+			Chart:      "rancher",
+			Artifacts:  []AutoupdateArtifactRef{{SourceArtifact: "registry.rancher.com/charts/rancher"}},
+		}
+		assert.NoError(t, helmChart.Validate())
+		_, err := helmChart.GetUpdateArtifacts(t.Context())
+		assert.EqualError(t, err, "failed to get versions of chart rancher: chart not found in index")
+	})
+
+	t.Run("GetUpdateArtifacts should read versions from OCI repository tags", func(t *testing.T) {
+		mux := http.NewServeMux()
+		mux.HandleFunc("GET /v2/charts/redis/tags/list", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			assert.NoError(t, json.NewEncoder(w).Encode(map[string]any{
+				"name": "charts/redis",
+				"tags": []string{"20.1.0", "19.6.4_up2", "latest", "18.0.0"},
+			}))
+		})
+		server := httptest.NewServer(mux)
+		t.Cleanup(server.Close)
+
+		helmChart := &HelmChart{
+			Repository:        "oci://" + strings.TrimPrefix(server.URL, "http://") + "/charts",
+			Chart:             "redis",
+			Artifacts:         []AutoupdateArtifactRef{{SourceArtifact: "dp.apps.rancher.io/charts/redis"}},
+			VersionConstraint: ">=19.0.0",
+			plainHTTP:         true,
+		}
+		assert.NoError(t, helmChart.Validate())
+		updateArtifacts, err := helmChart.GetUpdateArtifacts(t.Context())
+		assert.NoError(t, err)
+		artifacts := updateArtifacts.Artifacts
+		if assert.Len(t, artifacts, 1) {
+			assert.Equal(t, []string{"20.1.0", "19.6.4_up2"}, artifacts[0].Tags)
+		}
+	})
+}
@@ -1,175 +1,166 @@
 package autoupdate
 
 import (
-	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
-	"os/exec"
-	"slices"
+	"os"
+	"path/filepath"
 	"strings"
 
-	"github.com/rancher/artifact-mirror/internal/config"
-	"gopkg.in/yaml.v3"
+	"github.com/Masterminds/semver/v3"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/cli"
+	"helm.sh/helm/v3/pkg/registry"
+	"helm.sh/helm/v3/pkg/strvals"
 )
 
-const helmRepoName = "artifact-mirror-tools-temp"
-
 // An Environment is a set of configuration we would like to apply to a
 // chart when templating it out and searching the result for images.
 type Environment []string
 
-// ToHelmTemplateArgs converts the environment to helm template --set arguments
-func (e Environment) ToHelmTemplateArgs() []string {
-	args := make([]string, 0, len(e)*2)
+// Values converts the environment to chart values, in the same way as
+// helm template --set arguments.
+func (e Environment) Values() (map[string]any, error) {
+	values := map[string]any{}
 	for _, value := range e {
-		args = append(args, "--set", value)
+		if err := strvals.ParseInto(value, values); err != nil {
+			return nil, fmt.Errorf("failed to parse %q: %w", value, err)
+		}
 	}
-	return args
+	return values, nil
 }
 
 // HelmLatest retrieves image references by templating the latest version of the
-// configured helm chart, and recursively finding all fields with an "image" key.
-// This is not a perfectly reliable way of finding images that a chart uses, and
-// does not attempt to be. However, it is probably good enough for simple charts.
+// configured helm chart, and finding images in the result with Extractors. By
+// default, it recursively finds all fields with an "image" key. This is not a
+// perfectly reliable way of finding images that a chart uses, and does not
+// attempt to be. However, it is probably good enough for simple charts.
 type HelmLatest struct {
 	// Artifacts tells the autoupdate code which entry in config.yaml to add the
 	// update images to.
 	Artifacts []AutoupdateArtifactRef `json:",omitempty"`
-	// HelmRepo is the URL of the Helm chart repository.
+	// HelmRepo is the URL of the Helm chart repository. It may be an oci://
+	// URL, in which case charts are expected at <HelmRepo>/<chart name>.
 	HelmRepo string
 	// Charts is a map of chart names to the environments under which we want to
 	// search them for images.
 	Charts map[string]map[string]Environment
+	// ChartVersions maps chart names to the version of the chart to template.
+	// The version may be a semver constraint, in which case the latest
+	// matching version is used. Charts that are not present use the latest
+	// version.
+	ChartVersions map[string]string `json:",omitempty"`
+	// Extractors find images in the templated charts. The images found by
+	// all extractors are combined. If empty, a single ImageKey extractor
+	// is used.
+	Extractors []ImageExtractor `json:",omitempty"`
 	// ImageDenylist is a list of images to exclude from the result.
 	ImageDenylist []string `json:",omitempty"`
 }
 
 // GetUpdateArtifacts templates the helm chart and extracts all image references
-func (hl *HelmLatest) GetUpdateArtifacts() ([]*config.Artifact, error) {
-	if _, err := exec.LookPath("helm"); err != nil {
-		return nil, fmt.Errorf("helm command not found: %w", err)
+func (hl *HelmLatest) GetUpdateArtifacts(ctx context.Context) (UpdateArtifacts, error) {
+	// Use a separate helm home for each run, so that the user's helm
+	// configuration is not changed and concurrent runs do not conflict.
+	helmHome, err := os.MkdirTemp("", "artifact-mirror-helm-")
+	if err != nil {
+		return UpdateArtifacts{}, fmt.Errorf("failed to create temporary helm home: %w", err)
 	}
-
-	artifactMap := make(map[string][]string)
-
-	addRepoCmd := exec.Command("helm", "repo", "add", helmRepoName, hl.HelmRepo)
-	if err := addRepoCmd.Run(); err != nil {
-		return nil, fmt.Errorf("failed to add helm repository: %w", err)
+	defer os.RemoveAll(helmHome)
+	settings := cli.New()
+	settings.RepositoryConfig = filepath.Join(helmHome, "repositories.yaml")
+	settings.RepositoryCache = filepath.Join(helmHome, "repository")
+	settings.RegistryConfig = filepath.Join(helmHome, "registry", "config.json")
+
+	registryClient, err := registry.NewClient(
+		registry.ClientOptWriter(io.Discard),
+		registry.ClientOptCredentialsFile(settings.RegistryConfig),
+	)
+	if err != nil {
+		return UpdateArtifacts{}, fmt.Errorf("failed to create registry client: %w", err)
 	}
-	defer exec.Command("helm", "repo", "remove", helmRepoName).Run()
 
-	updateCmd := exec.Command("helm", "repo", "update")
-	if err := updateCmd.Run(); err != nil {
-		return nil, fmt.Errorf("failed to update helm repositories: %w", err)
-	}
+	artifactMap := make(map[string][]string)
 
 	for chartName, environmentMap := range hl.Charts {
-		for environmentName, environment := range environmentMap {
-			args := []string{
-				"template", chartName + "-" + environmentName,
-				helmRepoName + "/" + chartName,
-			}
-			args = append(args, environment.ToHelmTemplateArgs()...)
+		// Locating a chart does not take a context, so we check for
+		// cancellation before each chart.
+		if err := ctx.Err(); err != nil {
+			return UpdateArtifacts{}, err
+		}
+		chart, err := hl.loadChart(settings, registryClient, chartName)
+		if err != nil {
+			return UpdateArtifacts{}, fmt.Errorf("failed to load chart %s: %w", chartName, err)
+		}
 
-			templateCmd := exec.Command("helm", args...)
-			templateOutput := &bytes.Buffer{}
-			templateCmd.Stdout = templateOutput
-			if err := templateCmd.Run(); err != nil {
-				return nil, fmt.Errorf("failed to template chart %s for env %s: %w", chartName, environmentName, err)
+		for environmentName, environment := range environmentMap {
+			manifests, err := hl.templateChart(ctx, registryClient, chart, chartName+"-"+environmentName, environment)
+			if err != nil {
+				return UpdateArtifacts{}, fmt.Errorf("failed to template chart %s for env %s: %w", chartName, environmentName, err)
 			}
 
-			decoder := yaml.NewDecoder(templateOutput)
-			for {
-				var templateData any
-				if err := decoder.Decode(&templateData); errors.Is(err, io.EOF) {
-					break
-				} else if err != nil {
-					return nil, fmt.Errorf("failed to parse template command output as yaml: %w", err)
-				}
-				if err := hl.extractImagesFromYaml(templateData, artifactMap); err != nil {
-					return nil, fmt.Errorf("failed to extract images from chart %s env %s: %w", chartName, environmentName, err)
-				}
+			if err := extractImages(hl.Extractors, manifests, artifactMap); err != nil {
+				return UpdateArtifacts{}, fmt.Errorf("failed to extract images from chart %s env %s: %w", chartName, environmentName, err)
 			}
 		}
 	}
 
-	// Convert the map to a slice of config.Artifact objects
-	images := make([]*config.Artifact, 0, len(artifactMap))
-	for sourceImage, tags := range artifactMap {
-		var foundTargetImageName *string
-		for _, autoupdateImageRef := range hl.Artifacts {
-			if sourceImage == autoupdateImageRef.SourceArtifact {
-				foundTargetImageName = &autoupdateImageRef.TargetArtifactName
-				break
-			}
-		}
-		if foundTargetImageName == nil {
-			return nil, fmt.Errorf("found image %s but it is not present in Artifacts", sourceImage)
-		}
-		image, err := config.NewArtifact(sourceImage, tags, *foundTargetImageName, nil, nil)
-		if err != nil {
-			return nil, fmt.Errorf("failed to create image: %w", err)
-		}
-		images = append(images, image)
-	}
+	artifacts, err := newArtifactsFromImageMap(artifactMap, hl.Artifacts, hl.ImageDenylist)
+	return UpdateArtifacts{Artifacts: artifacts}, err
+}
 
-	// Filter out denied images
-	filteredImages := make([]*config.Artifact, 0, len(images))
-	for _, image := range images {
-		if !slices.Contains(hl.ImageDenylist, image.SourceArtifact) {
-			filteredImages = append(filteredImages, image)
-		}
+// loadChart downloads the configured version of chartName and loads it.
+func (hl *HelmLatest) loadChart(settings *cli.EnvSettings, registryClient *registry.Client, chartName string) (*chart.Chart, error) {
+	install := action.NewInstall(&action.Configuration{})
+	install.SetRegistryClient(registryClient)
+	install.Version = hl.ChartVersions[chartName]
+	chartRef := chartName
+	if registry.IsOCI(hl.HelmRepo) {
+		chartRef = strings.TrimSuffix(hl.HelmRepo, "/") + "/" + chartName
+	} else {
+		install.RepoURL = hl.HelmRepo
 	}
 
-	return filteredImages, nil
+	chartPath, err := install.LocateChart(chartRef, settings)
+	if err != nil {
+		return nil, fmt.Errorf("failed to locate chart: %w", err)
+	}
+	return loader.Load(chartPath)
 }
 
-func (hl *HelmLatest) extractImagesFromYaml(data any, imageMap map[string][]string) error {
-	switch v := data.(type) {
-	case map[string]any:
-		for key, value := range v {
-			if key == "image" {
-				stringValue, ok := value.(string)
-				if ok {
-					repository, tag, err := hl.parseImageRef(stringValue)
-					if err != nil {
-						return fmt.Errorf("failed to parse %q as image ref: %w", stringValue, err)
-					}
-					existingTags, present := imageMap[repository]
-					if !present {
-						imageMap[repository] = []string{tag}
-					} else if !slices.Contains(existingTags, tag) {
-						imageMap[repository] = append(imageMap[repository], tag)
-					}
-				}
-			} else {
-				if err := hl.extractImagesFromYaml(value, imageMap); err != nil {
-					return err
-				}
-			}
-		}
-	case []any:
-		for _, item := range v {
-			if err := hl.extractImagesFromYaml(item, imageMap); err != nil {
-				return err
-			}
-		}
+// templateChart renders chart in the same way as helm template, and
+// returns the resulting manifests, including those of hooks.
+func (hl *HelmLatest) templateChart(ctx context.Context, registryClient *registry.Client, chart *chart.Chart, releaseName string, environment Environment) (string, error) {
+	values, err := environment.Values()
+	if err != nil {
+		return "", fmt.Errorf("failed to parse values: %w", err)
 	}
 
-	return nil
-}
+	install := action.NewInstall(&action.Configuration{
+		RegistryClient: registryClient,
+		Log:            func(string, ...any) {},
+	})
+	install.DryRun = true
+	install.DryRunOption = "client"
+	install.ClientOnly = true
+	install.Replace = true
+	install.ReleaseName = releaseName
+	install.Namespace = "default"
+	release, err := install.RunWithContext(ctx, chart, values)
+	if err != nil {
+		return "", err
+	}
 
-func (hl *HelmLatest) parseImageRef(rawString string) (string, string, error) {
-	withoutDigest := strings.Split(rawString, "@")[0]
-	parts := strings.Split(withoutDigest, ":")
-	if len(parts) != 2 {
-		return "", "", fmt.Errorf(`failed to split %q into two parts on ":"`, withoutDigest)
+	manifests := &strings.Builder{}
+	manifests.WriteString(release.Manifest)
+	for _, hook := range release.Hooks {
+		fmt.Fprintf(manifests, "\n---\n%s\n", hook.Manifest)
 	}
-	repository := parts[0]
-	tag := parts[1]
-	repositoryWithoutDocker := strings.TrimPrefix(repository, "docker.io/")
-	return repositoryWithoutDocker, tag, nil
+	return manifests.String(), nil
 }
 
 func (hl *HelmLatest) Validate() error {
@@ -187,5 +178,20 @@ func (hl *HelmLatest) Validate() error {
 		}
 	}
 
+	for i := range hl.Extractors {
+		if err := hl.Extractors[i].Validate(); err != nil {
+			return fmt.Errorf("extractor %d failed validation: %w", i, err)
+		}
+	}
+
+	for chartName, version := range hl.ChartVersions {
+		if _, ok := hl.Charts[chartName]; !ok {
+			return fmt.Errorf("ChartVersions contains chart %q that is not in Charts", chartName)
+		}
+		if _, err := semver.NewConstraint(version); err != nil {
+			return fmt.Errorf("invalid version %q for chart %q: %w", version, chartName, err)
+		}
+	}
+
 	return nil
 }
@@ -1,11 +1,84 @@
 package autoupdate
 
 import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/repo"
 )
 
+// newTestChartRepository serves a classic chart repository that contains
+// a "test-chart" chart for each of versions. Each version uses the image
+// test-org/test-image with the chart version as its tag, and a hook that
+// uses test-org/test-hook.
+func newTestChartRepository(t *testing.T, versions ...string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for _, version := range versions {
+		testChart := &chart.Chart{
+			Metadata: &chart.Metadata{
+				APIVersion: chart.APIVersionV2,
+				Name:       "test-chart",
+				Version:    version,
+			},
+			Raw: []*chart.File{
+				{
+					Name: chartutil.ValuesfileName,
+					Data: []byte("image:\n  tag: " + version + "\n"),
+				},
+			},
+			Templates: []*chart.File{
+				{
+					Name: "templates/deployment.yaml",
+					Data: []byte(`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: {{ .Release.Name }}
+spec:
+  template:
+    spec:
+      containers:
+      - name: main
+        image: test-org/test-image:{{ .Values.image.tag }}
+`),
+				},
+				{
+					Name: "templates/hook.yaml",
+					Data: []byte(`apiVersion: batch/v1
+kind: Job
+metadata:
+  name: {{ .Release.Name }}-hook
+  annotations:
+    helm.sh/hook: pre-install
+spec:
+  template:
+    spec:
+      containers:
+      - name: hook
+        image: docker.io/test-org/test-hook:v1.0.0
+`),
+				},
+			},
+		}
+		_, err := chartutil.Save(testChart, dir)
+		assert.NoError(t, err)
+	}
+
+	server := httptest.NewServer(http.FileServer(http.Dir(dir)))
+	t.Cleanup(server.Close)
+	index, err := repo.IndexDirectory(dir, server.URL)
+	assert.NoError(t, err)
+	assert.NoError(t, index.WriteFile(filepath.Join(dir, "index.yaml"), 0o644))
+	return server.URL
+}
+
 func TestHelmLatest(t *testing.T) {
 	t.Run("Validate", func(t *testing.T) {
 		type testCase struct {
@@ -62,6 +135,58 @@ func TestHelmLatest(t *testing.T) {
 				},
 				ExpectedError: `chart "cilium" must have at least one environment`,
 			},
+			{
+				Message: "should return nil for valid ChartVersions",
+				HelmLatest: &HelmLatest{
+					HelmRepo: "oci://registry.example/charts",
+					Charts: map[string]map[string]Environment{
+						"cilium": {
+							"default": {},
+						},
+					},
+					ChartVersions: map[string]string{"cilium": "~1.16"},
+				},
+				ExpectedError: "",
+			},
+			{
+				Message: "should return error for invalid extractor",
+				HelmLatest: &HelmLatest{
+					HelmRepo: "https://helm.cilium.io",
+					Charts: map[string]map[string]Environment{
+						"cilium": {
+							"default": {},
+						},
+					},
+					Extractors: []ImageExtractor{{Type: ImageExtractorPodSpec}, {Type: ImageExtractorKeyPath}},
+				},
+				ExpectedError: "extractor 1 failed validation: must specify Path for KeyPath",
+			},
+			{
+				Message: "should return error for ChartVersions with unknown chart",
+				HelmLatest: &HelmLatest{
+					HelmRepo: "https://helm.cilium.io",
+					Charts: map[string]map[string]Environment{
+						"cilium": {
+							"default": {},
+						},
+					},
+					ChartVersions: map[string]string{"tetragon": "1.0.0"},
+				},
+				ExpectedError: `ChartVersions contains chart "tetragon" that is not in Charts`,
+			},
+			{
+				Message: "should return error for invalid chart version",
+				HelmLatest: &HelmLatest{
+					HelmRepo: "https://helm.cilium.io",
+					Charts: map[string]map[string]Environment{
+						"cilium": {
+							"default": {},
+						},
+					},
+					ChartVersions: map[string]string{"cilium": "invalid"},
+				},
+				ExpectedError: `invalid version "invalid" for chart "cilium": improper constraint: invalid`,
+			},
 		}
 		for _, testCase := range testCases {
 			t.Run(testCase.Message, func(t *testing.T) {
@@ -74,4 +199,72 @@ func TestHelmLatest(t *testing.T) {
 			})
 		}
 	})
+
+	t.Run("GetUpdateArtifacts", func(t *testing.T) {
+		t.Setenv("HELM_CACHE_HOME", t.TempDir())
+		t.Setenv("HELM_CONFIG_HOME", t.TempDir())
+		t.Setenv("HELM_DATA_HOME", t.TempDir())
+		repoURL := newTestChartRepository(t, "1.0.0", "1.1.0", "2.0.0")
+
+		type testCase struct {
+			Message       string
+			ChartVersions map[string]string
+			Environment   Environment
+			ExpectedTag   string
+		}
+		testCases := []testCase{
+			{
+				Message:     "should template the latest chart version",
+				ExpectedTag: "2.0.0",
+			},
+			{
+				Message:       "should template pinned chart version",
+				ChartVersions: map[string]string{"test-chart": "1.0.0"},
+				ExpectedTag:   "1.0.0",
+			},
+			{
+				Message:       "should template latest chart version matching constraint",
+				ChartVersions: map[string]string{"test-chart": "^1.0.0"},
+				ExpectedTag:   "1.1.0",
+			},
+			{
+				Message:     "should apply environment values",
+				Environment: Environment{"image.tag=custom"},
+				ExpectedTag: "custom",
+			},
+		}
+		for _, testCase := range testCases {
+			t.Run(testCase.Message, func(t *testing.T) {
+				helmLatest := &HelmLatest{
+					HelmRepo: repoURL,
+					Charts: map[string]map[string]Environment{
+						"test-chart": {"default": testCase.Environment},
+					},
+					ChartVersions: testCase.ChartVersions,
+					Artifacts: []AutoupdateArtifactRef{
+						{SourceArtifact: "test-org/test-image"},
+						{SourceArtifact: "test-org/test-hook"},
+					},
+				}
+				assert.NoError(t, helmLatest.Validate())
+				updateArtifacts, err := helmLatest.GetUpdateArtifacts(t.Context())
+				assert.NoError(t, err)
+				artifacts := updateArtifacts.Artifacts
+				found := map[string][]string{}
+				for _, artifact := range artifacts {
+					found[artifact.SourceArtifact] = artifact.Tags
+				}
+				assert.Equal(t, map[string][]string{
+					"test-org/test-image": {testCase.ExpectedTag},
+					"test-org/test-hook":  {"v1.0.0"},
+				}, found)
+			})
+		}
+
+		t.Run("should not modify the user's helm configuration", func(t *testing.T) {
+			entries, err := os.ReadDir(os.Getenv("HELM_CONFIG_HOME"))
+			assert.NoError(t, err)
+			assert.Empty(t, entries, fmt.Sprintf("unexpected files in helm config home: %v", entries))
+		})
+	})
 }
@@ -0,0 +1,231 @@
+package autoupdate
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// HTTPCache is an http.RoundTripper that stores successful responses to GET
+// requests on disk. A stored response that is younger than TTL is returned
+// without making a request. An older one is revalidated with If-None-Match
+// or If-Modified-Since, so that unchanged resources are not downloaded
+// again, and do not count against the rate limit of the GitHub API.
+//
+// Requests with an Authorization header are passed through unchanged, so
+// that credentials and the responses to authenticated requests are never
+// written to disk.
+type HTTPCache struct {
+	// Dir is the directory in which responses are stored.
+	Dir string
+	// TTL is how long a stored response is used without revalidating it.
+	TTL time.Duration
+	// Transport makes the requests. Defaults to http.DefaultTransport.
+	Transport http.RoundTripper
+}
+
+// NewHTTPCache returns an HTTPCache that stores responses in dir, which is
+// created if it does not exist. Stored responses that were neither stored
+// nor revalidated within maxAge are deleted, so that the responses to
+// requests that are no longer made do not accumulate. If maxAge is 0, no
+// responses are deleted.
+func NewHTTPCache(dir string, ttl, maxAge time.Duration) (*HTTPCache, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	cache := &HTTPCache{Dir: dir, TTL: ttl}
+	if maxAge > 0 {
+		if err := cache.prune(maxAge); err != nil {
+			return nil, fmt.Errorf("failed to prune cache directory: %w", err)
+		}
+	}
+	return cache, nil
+}
+
+// prune deletes the files in Dir that were last modified more than maxAge
+// ago. This includes temporary files left behind by interrupted runs.
+func (c *HTTPCache) prune(maxAge time.Duration) error {
+	entries, err := os.ReadDir(c.Dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		info, err := entry.Info()
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		if time.Since(info.ModTime()) < maxAge {
+			continue
+		}
+		if err := os.Remove(filepath.Join(c.Dir, entry.Name())); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// UseHTTPCache makes doRequestWithRetries and the GitHub clients of the
+// update strategies use cache. Registry clients that use oras are not
+// affected, since registry tokens must not be reused from the cache.
+func UseHTTPCache(cache *HTTPCache) {
+	if cache.Transport == nil {
+		cache.Transport = httpClient.Transport
+	}
+	httpClient.Transport = cache
+}
+
+func (c *HTTPCache) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !isCacheable(req) {
+		return c.transport().RoundTrip(req)
+	}
+
+	path := c.path(req)
+	cached, storedAt, err := c.load(path, req)
+	if err != nil {
+		fmt.Printf("warning: ignoring cached response for %s: %s\n", req.URL, err)
+		cached = nil
+	}
+	if cached != nil && time.Since(storedAt) < c.TTL {
+		return cached, nil
+	}
+
+	outgoing := req
+	if cached != nil {
+		etag := cached.Header.Get("ETag")
+		lastModified := cached.Header.Get("Last-Modified")
+		if etag != "" || lastModified != "" {
+			outgoing = req.Clone(req.Context())
+			if etag != "" {
+				outgoing.Header.Set("If-None-Match", etag)
+			}
+			if lastModified != "" {
+				outgoing.Header.Set("If-Modified-Since", lastModified)
+			}
+		}
+	}
+
+	resp, err := c.transport().RoundTrip(outgoing)
+	if err != nil {
+		return nil, err
+	}
+	if cached != nil && resp.StatusCode == http.StatusNotModified {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		now := time.Now()
+		if err := os.Chtimes(path, now, now); err != nil {
+			fmt.Printf("warning: failed to refresh cached response for %s: %s\n", req.URL, err)
+		}
+		return cached, nil
+	}
+	if resp.StatusCode != http.StatusOK || strings.Contains(resp.Header.Get("Cache-Control"), "no-store") {
+		return resp, nil
+	}
+	return c.store(path, resp)
+}
+
+// isCacheable returns whether the response to req may be stored.
+func isCacheable(req *http.Request) bool {
+	if req.Method != http.MethodGet {
+		return false
+	}
+	for _, header := range []string{"Authorization", "Range", "If-None-Match", "If-Modified-Since"} {
+		if req.Header.Get(header) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *HTTPCache) transport() http.RoundTripper {
+	if c.Transport == nil {
+		return http.DefaultTransport
+	}
+	return c.Transport
+}
+
+// path returns the path of the file that stores the response to req. The
+// Accept header is part of the key, since some APIs return different
+// representations of the same URL depending on it.
+func (c *HTTPCache) path(req *http.Request) string {
+	hash := sha256.Sum256([]byte(req.URL.String() + "\n" + req.Header.Get("Accept")))
+	return filepath.Join(c.Dir, hex.EncodeToString(hash[:]))
+}
+
+// load returns the response stored at path, and when it was stored. If
+// there is no stored response, it returns a nil response.
+func (c *HTTPCache) load(path string, req *http.Request) (*http.Response, time.Time, error) {
+	contents, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, time.Time{}, nil
+	} else if err != nil {
+		return nil, time.Time{}, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(contents)), req)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to parse response: %w", err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to read body: %w", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return resp, info.ModTime(), nil
+}
+
+// store writes resp to path, and returns a response that can be read in
+// its place.
+func (c *HTTPCache) store(path string, resp *http.Response) (*http.Response, error) {
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read body: %w", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	dump, err := httputil.DumpResponse(resp, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize response: %w", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	// Write to a temporary file first, so that concurrent readers never
+	// see a partially written response.
+	file, err := os.CreateTemp(c.Dir, ".tmp-")
+	if err != nil {
+		fmt.Printf("warning: failed to cache response for %s: %s\n", resp.Request.URL, err)
+		return resp, nil
+	}
+	_, writeErr := file.Write(dump)
+	closeErr := file.Close()
+	if writeErr != nil || closeErr != nil {
+		os.Remove(file.Name())
+		fmt.Printf("warning: failed to cache response for %s: %s\n", resp.Request.URL, errors.Join(writeErr, closeErr))
+		return resp, nil
+	}
+	if err := os.Rename(file.Name(), path); err != nil {
+		os.Remove(file.Name())
+		fmt.Printf("warning: failed to cache response for %s: %s\n", resp.Request.URL, err)
+	}
+	return resp, nil
+}
@@ -0,0 +1,147 @@
+package autoupdate
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTTPCache(t *testing.T) {
+	requests := 0
+	conditionalRequests := 0
+	body := "version 1"
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /etag", func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		etag := `"` + body + `"`
+		if r.Header.Get("If-None-Match") == etag {
+			conditionalRequests++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		_, err := io.WriteString(w, body)
+		assert.NoError(t, err)
+	})
+	mux.HandleFunc("GET /error", func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusNotFound)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	get := func(t *testing.T, cache *HTTPCache, path string, header http.Header) (int, string) {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodGet, server.URL+path, nil)
+		assert.NoError(t, err)
+		for key, values := range header {
+			req.Header[key] = values
+		}
+		resp, err := (&http.Client{Transport: cache}).Do(req)
+		if !assert.NoError(t, err) {
+			return 0, ""
+		}
+		defer resp.Body.Close()
+		contents, err := io.ReadAll(resp.Body)
+		assert.NoError(t, err)
+		return resp.StatusCode, string(contents)
+	}
+	reset := func(t *testing.T) *HTTPCache {
+		t.Helper()
+		requests = 0
+		conditionalRequests = 0
+		body = "version 1"
+		cache, err := NewHTTPCache(t.TempDir(), time.Hour, 0)
+		assert.NoError(t, err)
+		return cache
+	}
+
+	t.Run("should return fresh responses without a request", func(t *testing.T) {
+		cache := reset(t)
+		for range 3 {
+			status, contents := get(t, cache, "/etag", nil)
+			assert.Equal(t, http.StatusOK, status)
+			assert.Equal(t, "version 1", contents)
+		}
+		assert.Equal(t, 1, requests)
+	})
+
+	t.Run("should revalidate stale responses with If-None-Match", func(t *testing.T) {
+		cache := reset(t)
+		cache.TTL = 0
+		get(t, cache, "/etag", nil)
+		status, contents := get(t, cache, "/etag", nil)
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, "version 1", contents)
+		assert.Equal(t, 2, requests)
+		assert.Equal(t, 1, conditionalRequests)
+
+		body = "version 2"
+		_, contents = get(t, cache, "/etag", nil)
+		assert.Equal(t, "version 2", contents)
+		_, contents = get(t, cache, "/etag", nil)
+		assert.Equal(t, "version 2", contents)
+		assert.Equal(t, 2, conditionalRequests)
+	})
+
+	t.Run("should revalidate responses older than TTL", func(t *testing.T) {
+		cache := reset(t)
+		get(t, cache, "/etag", nil)
+		req, err := http.NewRequest(http.MethodGet, server.URL+"/etag", nil)
+		assert.NoError(t, err)
+		past := time.Now().Add(-2 * time.Hour)
+		assert.NoError(t, os.Chtimes(cache.path(req), past, past))
+
+		get(t, cache, "/etag", nil)
+		get(t, cache, "/etag", nil)
+		assert.Equal(t, 2, requests)
+		assert.Equal(t, 1, conditionalRequests)
+	})
+
+	t.Run("should use Accept as part of the key", func(t *testing.T) {
+		cache := reset(t)
+		get(t, cache, "/etag", http.Header{"Accept": {"application/json"}})
+		get(t, cache, "/etag", http.Header{"Accept": {"text/plain"}})
+		assert.Equal(t, 2, requests)
+	})
+
+	t.Run("should not cache authenticated requests", func(t *testing.T) {
+		cache := reset(t)
+		get(t, cache, "/etag", http.Header{"Authorization": {"Bearer token"}})
+		get(t, cache, "/etag", http.Header{"Authorization": {"Bearer token"}})
+		assert.Equal(t, 2, requests)
+		entries, err := os.ReadDir(cache.Dir)
+		assert.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+
+	t.Run("should not cache unsuccessful responses", func(t *testing.T) {
+		cache := reset(t)
+		status, _ := get(t, cache, "/error", nil)
+		assert.Equal(t, http.StatusNotFound, status)
+		get(t, cache, "/error", nil)
+		assert.Equal(t, 2, requests)
+	})
+
+	t.Run("should delete responses older than maxAge", func(t *testing.T) {
+		cache := reset(t)
+		get(t, cache, "/etag", nil)
+		get(t, cache, "/etag", http.Header{"Accept": {"text/plain"}})
+		req, err := http.NewRequest(http.MethodGet, server.URL+"/etag", nil)
+		assert.NoError(t, err)
+		past := time.Now().Add(-48 * time.Hour)
+		assert.NoError(t, os.Chtimes(cache.path(req), past, past))
+
+		_, err = NewHTTPCache(cache.Dir, time.Hour, 24*time.Hour)
+		assert.NoError(t, err)
+		entries, err := os.ReadDir(cache.Dir)
+		assert.NoError(t, err)
+		assert.Len(t, entries, 1)
+		assert.NoFileExists(t, cache.path(req))
+	})
+}
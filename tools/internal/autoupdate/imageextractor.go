@@ -0,0 +1,375 @@
+package autoupdate
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/rancher/artifact-mirror/internal/config"
+	"github.com/rancher/artifact-mirror/internal/reference"
+	"gopkg.in/yaml.v3"
+	"k8s.io/client-go/util/jsonpath"
+)
+
+// ImageExtractorType determines how an ImageExtractor finds images.
+type ImageExtractorType string
+
+const (
+	// ImageExtractorImageKey finds the values of all fields with an "image"
+	// key, at any depth.
+	ImageExtractorImageKey ImageExtractorType = "ImageKey"
+	// ImageExtractorPodSpec finds the images of the containers, init
+	// containers and ephemeral containers of Kubernetes pod specs, in pods
+	// and in the workload resources that contain pod templates.
+	ImageExtractorPodSpec ImageExtractorType = "PodSpec"
+	// ImageExtractorKeyPath finds the values at Path.
+	ImageExtractorKeyPath ImageExtractorType = "KeyPath"
+	// ImageExtractorJSONPath finds the values that JSONPath selects.
+	ImageExtractorJSONPath ImageExtractorType = "JSONPath"
+	// ImageExtractorRegex finds matches of Regex in the rendered manifests.
+	ImageExtractorRegex ImageExtractorType = "Regex"
+)
+
+// podSpecPaths maps kinds of Kubernetes resources to the paths of the pod
+// specs they contain.
+var podSpecPaths = map[string][]string{
+	"Pod":                   {"spec"},
+	"PodTemplate":           {"template", "spec"},
+	"Deployment":            {"spec", "template", "spec"},
+	"StatefulSet":           {"spec", "template", "spec"},
+	"DaemonSet":             {"spec", "template", "spec"},
+	"ReplicaSet":            {"spec", "template", "spec"},
+	"ReplicationController": {"spec", "template", "spec"},
+	"Job":                   {"spec", "template", "spec"},
+	"CronJob":               {"spec", "jobTemplate", "spec", "template", "spec"},
+}
+
+// ImageExtractor finds image references in Kubernetes manifests, such as
+// those rendered from a helm chart. Found values may be strings, or maps that contain a
+// "repository" key and optionally "registry", "tag" and "digest" keys.
+type ImageExtractor struct {
+	Type ImageExtractorType
+	// Kinds restricts the extractor to resources of the given kinds. It is
+	// not supported for Regex.
+	Kinds []string `json:",omitempty"`
+	// Path is a "."-separated list of keys that is followed from the root
+	// of each resource. A key of "*" matches all keys of a map or all
+	// elements of a list. Only used for KeyPath.
+	Path string `json:",omitempty"`
+	// JSONPath is a JSONPath expression in the format used by kubectl,
+	// e.g. "{.spec.containers[*].image}", that is evaluated against each
+	// resource. Only used for JSONPath.
+	JSONPath         string             `json:",omitempty"`
+	compiledJSONPath *jsonpath.JSONPath `json:"-"`
+	// Regex is matched against the rendered manifests. If it contains a
+	// match group named "image", its contents are used as the image.
+	// Otherwise, if it contains a match group, the contents of the first
+	// match group are used. Only used for Regex.
+	Regex         string         `json:",omitempty"`
+	compiledRegex *regexp.Regexp `json:"-"`
+}
+
+// skipsNonImages returns whether values found by ie that are not image
+// references are skipped, rather than treated as errors. Paths and
+// regexes commonly match values that are not images, such as the
+// values of env vars.
+func (ie *ImageExtractor) skipsNonImages() bool {
+	return ie.Type == ImageExtractorKeyPath || ie.Type == ImageExtractorJSONPath || ie.Type == ImageExtractorRegex
+}
+
+// extractImages returns the image references found in manifests, which
+// are also passed as decoded documents.
+func (ie *ImageExtractor) extractImages(manifests string, documents []any) ([]string, error) {
+	if ie.Type == ImageExtractorRegex {
+		imageGroup := ie.compiledRegex.SubexpIndex("image")
+		if imageGroup == -1 {
+			imageGroup = min(1, ie.compiledRegex.NumSubexp())
+		}
+		images := make([]string, 0)
+		for _, matches := range ie.compiledRegex.FindAllStringSubmatch(manifests, -1) {
+			if isImageRef(matches[imageGroup]) {
+				images = append(images, matches[imageGroup])
+			}
+		}
+		return images, nil
+	}
+
+	values := make([]any, 0)
+	for _, document := range documents {
+		resource, ok := document.(map[string]any)
+		if !ok {
+			continue
+		}
+		kind, _ := resource["kind"].(string)
+		if len(ie.Kinds) > 0 && !slices.Contains(ie.Kinds, kind) {
+			continue
+		}
+		switch ie.Type {
+		case ImageExtractorImageKey:
+			values = append(values, findImageKeyValues(resource)...)
+		case ImageExtractorPodSpec:
+			if path, ok := podSpecPaths[kind]; ok {
+				for _, podSpec := range valuesAtKeyPath(resource, path) {
+					values = append(values, podSpecImages(podSpec)...)
+				}
+			}
+		case ImageExtractorKeyPath:
+			values = append(values, valuesAtKeyPath(resource, strings.Split(ie.Path, "."))...)
+		case ImageExtractorJSONPath:
+			results, err := ie.compiledJSONPath.FindResults(resource)
+			if err != nil {
+				return nil, fmt.Errorf("failed to evaluate JSONPath: %w", err)
+			}
+			for _, result := range results {
+				for _, value := range result {
+					values = append(values, value.Interface())
+				}
+			}
+		default:
+			return nil, fmt.Errorf("unknown image extractor type %q", ie.Type)
+		}
+	}
+
+	images := make([]string, 0, len(values))
+	for _, value := range values {
+		image, ok := imageFromValue(value)
+		if ie.skipsNonImages() {
+			if ok && isImageRef(image) {
+				images = append(images, image)
+			}
+			continue
+		}
+		if !ok {
+			return nil, fmt.Errorf("failed to get image from value %v", value)
+		}
+		images = append(images, image)
+	}
+	return images, nil
+}
+
+func (ie *ImageExtractor) Validate() error {
+	switch ie.Type {
+	case ImageExtractorImageKey, ImageExtractorPodSpec:
+	case ImageExtractorKeyPath:
+		if ie.Path == "" {
+			return errors.New("must specify Path for KeyPath")
+		}
+	case ImageExtractorJSONPath:
+		if ie.JSONPath == "" {
+			return errors.New("must specify JSONPath for JSONPath")
+		}
+		if !strings.HasPrefix(ie.JSONPath, "{") {
+			return errors.New(`JSONPath must be in the format used by kubectl, e.g. "{.spec.image}"`)
+		}
+		compiledJSONPath := jsonpath.New(ie.JSONPath).AllowMissingKeys(true)
+		if err := compiledJSONPath.Parse(ie.JSONPath); err != nil {
+			return fmt.Errorf("invalid JSONPath: %w", err)
+		}
+		ie.compiledJSONPath = compiledJSONPath
+	case ImageExtractorRegex:
+		if ie.Regex == "" {
+			return errors.New("must specify Regex for Regex")
+		}
+		if len(ie.Kinds) > 0 {
+			return errors.New("must not specify Kinds for Regex")
+		}
+		compiledRegex, err := regexp.Compile(ie.Regex)
+		if err != nil {
+			return fmt.Errorf("invalid Regex: %w", err)
+		}
+		ie.compiledRegex = compiledRegex
+	default:
+		return fmt.Errorf("unknown image extractor type %q", ie.Type)
+	}
+	if ie.Type != ImageExtractorKeyPath && ie.Path != "" {
+		return fmt.Errorf("must not specify Path for %s", ie.Type)
+	}
+	if ie.Type != ImageExtractorJSONPath && ie.JSONPath != "" {
+		return fmt.Errorf("must not specify JSONPath for %s", ie.Type)
+	}
+	if ie.Type != ImageExtractorRegex && ie.Regex != "" {
+		return fmt.Errorf("must not specify Regex for %s", ie.Type)
+	}
+	return nil
+}
+
+// findImageKeyValues recursively finds the values of all fields with an
+// "image" key in data. Values that are not images are ignored.
+func findImageKeyValues(data any) []any {
+	values := make([]any, 0)
+	switch v := data.(type) {
+	case map[string]any:
+		for key, value := range v {
+			if key == "image" {
+				if _, ok := imageFromValue(value); ok {
+					values = append(values, value)
+				}
+			} else {
+				values = append(values, findImageKeyValues(value)...)
+			}
+		}
+	case []any:
+		for _, item := range v {
+			values = append(values, findImageKeyValues(item)...)
+		}
+	}
+	return values
+}
+
+// valuesAtKeyPath returns the values found by following path from data.
+func valuesAtKeyPath(data any, path []string) []any {
+	if len(path) == 0 {
+		return []any{data}
+	}
+	key, rest := path[0], path[1:]
+	values := make([]any, 0)
+	switch v := data.(type) {
+	case map[string]any:
+		if key == "*" {
+			for _, value := range v {
+				values = append(values, valuesAtKeyPath(value, rest)...)
+			}
+		} else if value, ok := v[key]; ok {
+			values = append(values, valuesAtKeyPath(value, rest)...)
+		}
+	case []any:
+		if key == "*" {
+			for _, item := range v {
+				values = append(values, valuesAtKeyPath(item, rest)...)
+			}
+		}
+	}
+	return values
+}
+
+// podSpecImages returns the image fields of the containers in podSpec.
+func podSpecImages(podSpec any) []any {
+	images := make([]any, 0)
+	for _, containersKey := range []string{"containers", "initContainers", "ephemeralContainers"} {
+		images = append(images, valuesAtKeyPath(podSpec, []string{containersKey, "*", "image"})...)
+	}
+	return images
+}
+
+// imageFromValue converts value to an image reference, if possible.
+func imageFromValue(value any) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, v != ""
+	case map[string]any:
+		repository, ok := v["repository"].(string)
+		if !ok || repository == "" {
+			return "", false
+		}
+		image := repository
+		if registry, ok := v["registry"].(string); ok && registry != "" {
+			image = registry + "/" + image
+		}
+		if tag, ok := v["tag"].(string); ok && tag != "" {
+			image = image + ":" + tag
+		}
+		if digest, ok := v["digest"].(string); ok && digest != "" {
+			image = image + "@" + digest
+		}
+		return image, true
+	}
+	return "", false
+}
+
+// extractImages runs extractors on manifests, and adds the images they
+// find to imageMap. If extractors is empty, a single ImageKey extractor is
+// used.
+func extractImages(extractors []ImageExtractor, manifests string, imageMap map[string][]string) error {
+	documents := make([]any, 0)
+	decoder := yaml.NewDecoder(strings.NewReader(manifests))
+	for {
+		var document any
+		if err := decoder.Decode(&document); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return fmt.Errorf("failed to parse manifests as yaml: %w", err)
+		}
+		documents = append(documents, document)
+	}
+
+	if len(extractors) == 0 {
+		extractors = []ImageExtractor{{Type: ImageExtractorImageKey}}
+	}
+	for _, extractor := range extractors {
+		images, err := extractor.extractImages(manifests, documents)
+		if err != nil {
+			return fmt.Errorf("%s extractor failed: %w", extractor.Type, err)
+		}
+		for _, image := range images {
+			repository, tag, err := parseImageRef(image)
+			if err != nil {
+				return fmt.Errorf("failed to parse %q as image ref: %w", image, err)
+			}
+			existingTags, present := imageMap[repository]
+			if !present {
+				imageMap[repository] = []string{tag}
+			} else if !slices.Contains(existingTags, tag) {
+				imageMap[repository] = append(imageMap[repository], tag)
+			}
+		}
+	}
+
+	return nil
+}
+
+// isImageRef returns whether value can be parsed by parseImageRef.
+func isImageRef(value string) bool {
+	_, _, err := parseImageRef(value)
+	return err == nil
+}
+
+// parseImageRef returns the repository of rawString in the form used in
+// config.yaml, along with its tag.
+func parseImageRef(rawString string) (string, string, error) {
+	ref, err := reference.Parse(rawString)
+	if err != nil {
+		return "", "", err
+	}
+	if ref.Tag == "" {
+		return "", "", errors.New("image ref has no tag")
+	}
+	return ref.ShortName(), ref.Tag, nil
+}
+
+// newArtifactsFromImageMap converts imageMap, which maps repositories to
+// tags, to Artifacts. refs determines the TargetArtifactName of each
+// Artifact; it is an error for a repository to be missing from refs.
+// Repositories in imageDenylist are left out.
+func newArtifactsFromImageMap(imageMap map[string][]string, refs []AutoupdateArtifactRef, imageDenylist []string) ([]*config.Artifact, error) {
+	images := make([]*config.Artifact, 0, len(imageMap))
+	for sourceImage, tags := range imageMap {
+		var foundTargetImageName *string
+		for _, autoupdateImageRef := range refs {
+			if sourceImage == autoupdateImageRef.SourceArtifact {
+				foundTargetImageName = &autoupdateImageRef.TargetArtifactName
+				break
+			}
+		}
+		if foundTargetImageName == nil {
+			return nil, fmt.Errorf("found image %s but it is not present in Artifacts", sourceImage)
+		}
+		image, err := config.NewArtifact(sourceImage, tags, *foundTargetImageName, nil, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create image: %w", err)
+		}
+		images = append(images, image)
+	}
+
+	// Filter out denied images
+	filteredImages := make([]*config.Artifact, 0, len(images))
+	for _, image := range images {
+		if !slices.Contains(imageDenylist, image.SourceArtifact) {
+			filteredImages = append(filteredImages, image)
+		}
+	}
+
+	return filteredImages, nil
+}
@@ -0,0 +1,284 @@
+package autoupdate
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+const testManifests = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: test
+spec:
+  template:
+    spec:
+      initContainers:
+      - name: init
+        image: test-org/init:v1.0.0
+      containers:
+      - name: main
+        image: test-org/main:v2.0.0
+        args:
+        - --helper-image=test-org/helper:v3.0.0
+        - --helper-image=not an image
+        env:
+        - name: SIDECAR_IMAGE
+          value: test-org/sidecar:v5.0.0
+        - name: LOG_LEVEL
+          value: debug
+---
+apiVersion: batch/v1
+kind: CronJob
+metadata:
+  name: test
+spec:
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          containers:
+          - name: job
+            image: test-org/job:v4.0.0
+---
+apiVersion: monitoring.coreos.com/v1
+kind: Prometheus
+metadata:
+  name: test
+spec:
+  image:
+    registry: quay.io
+    repository: prometheus/prometheus
+    tag: v2.50.0
+  version: v2.50.0
+`
+
+func TestImageExtractor(t *testing.T) {
+	t.Run("Validate", func(t *testing.T) {
+		type testCase struct {
+			Message        string
+			ImageExtractor *ImageExtractor
+			ExpectedError  string
+		}
+		testCases := []testCase{
+			{
+				Message:        "should return nil for a valid ImageKey extractor",
+				ImageExtractor: &ImageExtractor{Type: ImageExtractorImageKey, Kinds: []string{"Deployment"}},
+				ExpectedError:  "",
+			},
+			{
+				Message:        "should return nil for a valid KeyPath extractor",
+				ImageExtractor: &ImageExtractor{Type: ImageExtractorKeyPath, Path: "spec.image"},
+				ExpectedError:  "",
+			},
+			{
+				Message:        "should return nil for a valid JSONPath extractor",
+				ImageExtractor: &ImageExtractor{Type: ImageExtractorJSONPath, JSONPath: "{.spec.image}"},
+				ExpectedError:  "",
+			},
+			{
+				Message:        "should return nil for a valid Regex extractor",
+				ImageExtractor: &ImageExtractor{Type: ImageExtractorRegex, Regex: `--helper-image=(\S+)`},
+				ExpectedError:  "",
+			},
+			{
+				Message:        "should return error for unknown type",
+				ImageExtractor: &ImageExtractor{Type: "asdf"},
+				ExpectedError:  `unknown image extractor type "asdf"`,
+			},
+			{
+				Message:        "should return error for KeyPath without Path",
+				ImageExtractor: &ImageExtractor{Type: ImageExtractorKeyPath},
+				ExpectedError:  "must specify Path for KeyPath",
+			},
+			{
+				Message:        "should return error for Path with other type",
+				ImageExtractor: &ImageExtractor{Type: ImageExtractorPodSpec, Path: "spec.image"},
+				ExpectedError:  "must not specify Path for PodSpec",
+			},
+			{
+				Message:        "should return error for JSONPath without JSONPath",
+				ImageExtractor: &ImageExtractor{Type: ImageExtractorJSONPath},
+				ExpectedError:  "must specify JSONPath for JSONPath",
+			},
+			{
+				Message:        "should return error for JSONPath without braces",
+				ImageExtractor: &ImageExtractor{Type: ImageExtractorJSONPath, JSONPath: ".spec.image"},
+				ExpectedError:  `JSONPath must be in the format used by kubectl, e.g. "{.spec.image}"`,
+			},
+			{
+				Message:        "should return error for invalid JSONPath",
+				ImageExtractor: &ImageExtractor{Type: ImageExtractorJSONPath, JSONPath: "{.spec[}"},
+				ExpectedError:  "invalid JSONPath: unterminated array",
+			},
+			{
+				Message:        "should return error for JSONPath with other type",
+				ImageExtractor: &ImageExtractor{Type: ImageExtractorKeyPath, Path: "spec.image", JSONPath: "{.spec.image}"},
+				ExpectedError:  "must not specify JSONPath for KeyPath",
+			},
+			{
+				Message:        "should return error for Regex without Regex",
+				ImageExtractor: &ImageExtractor{Type: ImageExtractorRegex},
+				ExpectedError:  "must specify Regex for Regex",
+			},
+			{
+				Message:        "should return error for Regex with Kinds",
+				ImageExtractor: &ImageExtractor{Type: ImageExtractorRegex, Regex: "asdf", Kinds: []string{"Pod"}},
+				ExpectedError:  "must not specify Kinds for Regex",
+			},
+			{
+				Message:        "should return error for invalid Regex",
+				ImageExtractor: &ImageExtractor{Type: ImageExtractorRegex, Regex: "v[asdf["},
+				ExpectedError:  "invalid Regex: error parsing regexp: missing closing ]: `[asdf[`",
+			},
+		}
+		for _, testCase := range testCases {
+			t.Run(testCase.Message, func(t *testing.T) {
+				err := testCase.ImageExtractor.Validate()
+				if testCase.ExpectedError == "" {
+					assert.Nil(t, err)
+				} else {
+					assert.EqualError(t, err, testCase.ExpectedError)
+				}
+			})
+		}
+	})
+
+	t.Run("extractImages", func(t *testing.T) {
+		documents := make([]any, 0)
+		decoder := yaml.NewDecoder(strings.NewReader(testManifests))
+		for {
+			var document any
+			if err := decoder.Decode(&document); errors.Is(err, io.EOF) {
+				break
+			}
+			documents = append(documents, document)
+		}
+
+		type testCase struct {
+			Message        string
+			ImageExtractor *ImageExtractor
+			ExpectedImages []string
+			ExpectedError  string
+		}
+		testCases := []testCase{
+			{
+				Message:        "ImageKey should find string and structured image values",
+				ImageExtractor: &ImageExtractor{Type: ImageExtractorImageKey},
+				ExpectedImages: []string{"test-org/init:v1.0.0", "test-org/main:v2.0.0", "test-org/job:v4.0.0", "quay.io/prometheus/prometheus:v2.50.0"},
+			},
+			{
+				Message:        "ImageKey should only consider resources of Kinds",
+				ImageExtractor: &ImageExtractor{Type: ImageExtractorImageKey, Kinds: []string{"Prometheus"}},
+				ExpectedImages: []string{"quay.io/prometheus/prometheus:v2.50.0"},
+			},
+			{
+				Message:        "PodSpec should find images of containers in workloads",
+				ImageExtractor: &ImageExtractor{Type: ImageExtractorPodSpec},
+				ExpectedImages: []string{"test-org/main:v2.0.0", "test-org/init:v1.0.0", "test-org/job:v4.0.0"},
+			},
+			{
+				Message:        "KeyPath should find values at path",
+				ImageExtractor: &ImageExtractor{Type: ImageExtractorKeyPath, Path: "spec.template.spec.*.*.image"},
+				ExpectedImages: []string{"test-org/init:v1.0.0", "test-org/main:v2.0.0"},
+			},
+			{
+				Message:        "KeyPath should skip values that are not images",
+				ImageExtractor: &ImageExtractor{Type: ImageExtractorKeyPath, Path: "spec.template.spec.containers.*.env.*.value"},
+				ExpectedImages: []string{"test-org/sidecar:v5.0.0"},
+			},
+			{
+				Message:        "KeyPath should skip maps that are not images",
+				ImageExtractor: &ImageExtractor{Type: ImageExtractorKeyPath, Path: "metadata"},
+				ExpectedImages: []string{},
+			},
+			{
+				Message:        "JSONPath should find selected values and skip those that are not images",
+				ImageExtractor: &ImageExtractor{Type: ImageExtractorJSONPath, JSONPath: "{..containers[*].env[*].value}"},
+				ExpectedImages: []string{"test-org/sidecar:v5.0.0"},
+			},
+			{
+				Message:        "JSONPath should find structured values",
+				ImageExtractor: &ImageExtractor{Type: ImageExtractorJSONPath, JSONPath: "{.spec.image}", Kinds: []string{"Prometheus"}},
+				ExpectedImages: []string{"quay.io/prometheus/prometheus:v2.50.0"},
+			},
+			{
+				Message:        "Regex should find match groups in manifests and skip matches that are not images",
+				ImageExtractor: &ImageExtractor{Type: ImageExtractorRegex, Regex: `--helper-image=(\S+)`},
+				ExpectedImages: []string{"test-org/helper:v3.0.0"},
+			},
+			{
+				Message:        "Regex should use the first match group",
+				ImageExtractor: &ImageExtractor{Type: ImageExtractorRegex, Regex: `--helper-image=(\S+:v(\d+))`},
+				ExpectedImages: []string{"test-org/helper:v3"},
+			},
+			{
+				Message:        "Regex should use the match group named image",
+				ImageExtractor: &ImageExtractor{Type: ImageExtractorRegex, Regex: `--(helper)-image=(?P<image>\S+)`},
+				ExpectedImages: []string{"test-org/helper:v3.0.0"},
+			},
+		}
+		for _, testCase := range testCases {
+			t.Run(testCase.Message, func(t *testing.T) {
+				assert.NoError(t, testCase.ImageExtractor.Validate())
+				images, err := testCase.ImageExtractor.extractImages(testManifests, documents)
+				if testCase.ExpectedError != "" {
+					assert.EqualError(t, err, testCase.ExpectedError)
+					return
+				}
+				assert.NoError(t, err)
+				assert.ElementsMatch(t, testCase.ExpectedImages, images)
+			})
+		}
+	})
+	t.Run("parseImageRef", func(t *testing.T) {
+		type testCase struct {
+			Message            string
+			Image              string
+			ExpectedRepository string
+			ExpectedTag        string
+			ExpectedError      string
+		}
+		testCases := []testCase{
+			{
+				Message:            "should strip docker.io",
+				Image:              "docker.io/rancher/rancher:v2.12.0",
+				ExpectedRepository: "rancher/rancher",
+				ExpectedTag:        "v2.12.0",
+			},
+			{
+				Message:            "should add library namespace",
+				Image:              "nginx:1.27",
+				ExpectedRepository: "library/nginx",
+				ExpectedTag:        "1.27",
+			},
+			{
+				Message:            "should handle registry with port and digest",
+				Image:              "registry:5000/img:tag@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
+				ExpectedRepository: "registry:5000/img",
+				ExpectedTag:        "tag",
+			},
+			{
+				Message:       "should return error for image without tag",
+				Image:         "quay.io/org/img",
+				ExpectedError: "image ref has no tag",
+			},
+		}
+		for _, testCase := range testCases {
+			t.Run(testCase.Message, func(t *testing.T) {
+				repository, tag, err := parseImageRef(testCase.Image)
+				if testCase.ExpectedError != "" {
+					assert.EqualError(t, err, testCase.ExpectedError)
+					return
+				}
+				assert.NoError(t, err)
+				assert.Equal(t, testCase.ExpectedRepository, repository)
+				assert.Equal(t, testCase.ExpectedTag, tag)
+			})
+		}
+	})
+}
@@ -0,0 +1,257 @@
+package autoupdate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/google/go-github/v80/github"
+	"github.com/rancher/artifact-mirror/internal/git"
+	"sigs.k8s.io/kustomize/api/krusty"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+// Manifest finds images in the Kubernetes manifests of the github releases
+// selected in the same way as GithubRelease. The manifests are either
+// downloaded from URLTemplate, or read from Path in a clone of the git
+// repository at the tag of the release. Images are found with Extractors,
+// in the same way as for HelmLatest, and mapped to config.yaml through
+// Artifacts.
+type Manifest struct {
+	Owner              string
+	Repository         string
+	IncludePrereleases bool   `json:",omitempty"`
+	LatestOnly         bool   `json:",omitempty"`
+	VersionConstraint  string `json:",omitempty"`
+	VersionRegex       string `json:",omitempty"`
+	// URLTemplate is a go template for the URL of the manifests. It may
+	// refer to .Owner, .Repository, .Tag and .Version. If it is not set,
+	// the manifests are read from a clone of GitRepository.
+	URLTemplate         string             `json:",omitempty"`
+	compiledURLTemplate *template.Template `json:"-"`
+	// GitRepository is the URL of the git repository that is cloned. It
+	// defaults to the github repository given by Owner and Repository.
+	GitRepository string `json:",omitempty"`
+	// Path is the file or directory within the git repository that
+	// contains the manifests. The manifests in a directory are read
+	// recursively. It must be set when URLTemplate is not.
+	Path string `json:",omitempty"`
+	// Kustomize builds the kustomization in Path, instead of reading the
+	// manifests in it directly.
+	Kustomize bool `json:",omitempty"`
+	// Extractors find images in the manifests. The images found by all
+	// extractors are combined. If empty, a single ImageKey extractor is
+	// used.
+	Extractors []ImageExtractor `json:",omitempty"`
+	Artifacts  []AutoupdateArtifactRef
+	// ImageDenylist is a list of images to exclude from the result.
+	ImageDenylist []string `json:",omitempty"`
+	// githubClient is used instead of the default client if set.
+	githubClient *github.Client `json:"-"`
+	// versions is the Versions of the entry. Releases whose versions it
+	// drops are neither downloaded nor cloned.
+	versions *VersionPolicy `json:"-"`
+}
+
+// errNoManifests is returned for releases whose manifests are not found at
+// URLTemplate.
+var errNoManifests = errors.New("release has no manifests")
+
+func (m *Manifest) GetUpdateArtifacts(ctx context.Context) (UpdateArtifacts, error) {
+	githubRelease, err := m.newGithubRelease()
+	if err != nil {
+		return UpdateArtifacts{}, err
+	}
+	releases, err := githubRelease.getReleases(ctx)
+	if err != nil {
+		return UpdateArtifacts{}, err
+	}
+	releases = selectReleases(releases, m.versions)
+
+	imageMap := map[string][]string{}
+	publishTimes := map[string]time.Time{}
+	skippedReleases := make([]SkippedRelease, 0)
+	for _, release := range releases {
+		var manifests string
+		if m.compiledURLTemplate != nil {
+			manifests, err = m.downloadManifests(ctx, release)
+		} else {
+			manifests, err = m.readManifestsFromGit(ctx, release)
+		}
+		if errors.Is(err, errNoManifests) {
+			// Manifests are often uploaded some time after the release is
+			// published, so the release is tried again in a later run.
+			skippedReleases = append(skippedReleases, SkippedRelease{
+				Tag:    release.release.GetTagName(),
+				Reason: err.Error(),
+			})
+			continue
+		} else if err != nil {
+			return UpdateArtifacts{}, fmt.Errorf("failed to get manifests for release %s: %w", release.release.GetTagName(), err)
+		}
+		releaseImageMap := map[string][]string{}
+		if err := extractImages(m.Extractors, manifests, releaseImageMap); err != nil {
+			return UpdateArtifacts{}, fmt.Errorf("failed to extract images from manifests for release %s: %w", release.release.GetTagName(), err)
+		}
+		for repository, tags := range releaseImageMap {
+			for _, tag := range tags {
+				if !slices.Contains(imageMap[repository], tag) {
+					imageMap[repository] = append(imageMap[repository], tag)
+				}
+				addPublishTime(publishTimes, repository, tag, release.release.GetPublishedAt().Time)
+			}
+		}
+	}
+
+	artifacts, err := newArtifactsFromImageMap(imageMap, m.Artifacts, m.ImageDenylist)
+	return UpdateArtifacts{Artifacts: artifacts, PublishTimes: publishTimes, SkippedReleases: skippedReleases}, err
+}
+
+// newGithubRelease returns the GithubRelease that selects the releases of
+// m.
+func (m *Manifest) newGithubRelease() (*GithubRelease, error) {
+	githubRelease := &GithubRelease{
+		Owner:              m.Owner,
+		Repository:         m.Repository,
+		Artifacts:          m.Artifacts,
+		IncludePrereleases: m.IncludePrereleases,
+		LatestOnly:         m.LatestOnly,
+		VersionConstraint:  m.VersionConstraint,
+		VersionRegex:       m.VersionRegex,
+		githubClient:       m.githubClient,
+	}
+	if err := githubRelease.Validate(); err != nil {
+		return nil, err
+	}
+	return githubRelease, nil
+}
+
+func (m *Manifest) downloadManifests(ctx context.Context, release versionedRelease) (string, error) {
+	builder := &strings.Builder{}
+	data := releaseTemplateData{
+		Owner:      m.Owner,
+		Repository: m.Repository,
+		Tag:        release.release.GetTagName(),
+		Version:    release.version,
+	}
+	if err := m.compiledURLTemplate.Execute(builder, data); err != nil {
+		return "", fmt.Errorf("failed to execute URLTemplate: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, builder.String(), nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := doRequestWithRetries(req)
+	if isNotFound(err) {
+		return "", fmt.Errorf("%w at %s", errNoManifests, req.URL)
+	} else if err != nil {
+		return "", fmt.Errorf("failed to download manifests: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read manifests: %w", err)
+	}
+	return string(body), nil
+}
+
+// readManifestsFromGit clones GitRepository at the tag of release, and
+// returns the manifests at Path.
+func (m *Manifest) readManifestsFromGit(ctx context.Context, release versionedRelease) (string, error) {
+	cloneDir, err := os.MkdirTemp("", "manifest-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(cloneDir)
+
+	gitRepository := m.GitRepository
+	if gitRepository == "" {
+		gitRepository = fmt.Sprintf("https://github.com/%s/%s.git", m.Owner, m.Repository)
+	}
+	if err := git.ShallowClone(ctx, gitRepository, release.release.GetTagName(), cloneDir); err != nil {
+		return "", err
+	}
+
+	manifestPath := filepath.Join(cloneDir, m.Path)
+	if m.Kustomize {
+		resources, err := krusty.MakeKustomizer(krusty.MakeDefaultOptions()).Run(filesys.MakeFsOnDisk(), manifestPath)
+		if err != nil {
+			return "", fmt.Errorf("failed to build kustomization: %w", err)
+		}
+		manifests, err := resources.AsYaml()
+		if err != nil {
+			return "", fmt.Errorf("failed to convert kustomization to yaml: %w", err)
+		}
+		return string(manifests), nil
+	}
+	return readManifestFiles(manifestPath)
+}
+
+// readManifestFiles returns the contents of the yaml files at manifestPath,
+// which may be a file or a directory, as a single multi-document string.
+func readManifestFiles(manifestPath string) (string, error) {
+	documents := make([]string, 0)
+	err := filepath.WalkDir(manifestPath, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if entry.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if path != manifestPath && filepath.Ext(path) != ".yaml" && filepath.Ext(path) != ".yml" {
+			return nil
+		}
+		contents, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		documents = append(documents, string(contents))
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(documents, "\n---\n"), nil
+}
+
+func (m *Manifest) Validate() error {
+	if _, err := m.newGithubRelease(); err != nil {
+		return err
+	}
+	if m.URLTemplate != "" {
+		if m.GitRepository != "" || m.Path != "" || m.Kustomize {
+			return errors.New("must not specify GitRepository, Path or Kustomize with URLTemplate")
+		}
+		compiledURLTemplate, err := template.New("URLTemplate").Option("missingkey=error").Parse(m.URLTemplate)
+		if err != nil {
+			return fmt.Errorf("invalid URLTemplate: %w", err)
+		}
+		m.compiledURLTemplate = compiledURLTemplate
+	} else if m.Path == "" {
+		// Reading the whole repository would pick up docs, examples and
+		// test fixtures along with the manifests.
+		return errors.New("must specify Path when URLTemplate is not set")
+	}
+	if m.Path != "" && !filepath.IsLocal(m.Path) {
+		return fmt.Errorf("Path %q must be a relative path within the repository", m.Path)
+	}
+	for i := range m.Extractors {
+		if err := m.Extractors[i].Validate(); err != nil {
+			return fmt.Errorf("extractor %d failed validation: %w", i, err)
+		}
+	}
+	return nil
+}
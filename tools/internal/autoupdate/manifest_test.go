@@ -0,0 +1,347 @@
+package autoupdate
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-github/v80/github"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestManifestGitRepository creates a git repository with a tag for each
+// of tags. At each tag, deploy/deployment.yaml uses the image
+// test-org/test-image with that tag, and deploy/kustomization.yaml sets
+// the tag of test-org/other-image to the tag. docs/example.yaml uses the
+// image test-org/example-image.
+func newTestManifestGitRepository(t *testing.T, tags ...string) string {
+	t.Helper()
+	t.Setenv("GIT_AUTHOR_NAME", "test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+
+	repoDir := t.TempDir()
+	runGit := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = repoDir
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v failed: %s: %s", args, err, output)
+		}
+	}
+	writeFile := func(name, contents string) {
+		t.Helper()
+		path := filepath.Join(repoDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("failed to create directory for %s: %s", name, err)
+		}
+		if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+			t.Fatalf("failed to write %s: %s", name, err)
+		}
+	}
+
+	runGit("init", "--initial-branch", "main")
+	writeFile("docs/example.yaml", "image: test-org/example-image:v0.0.1\n")
+	for _, tag := range tags {
+		writeFile("deploy/deployment.yaml", `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: test
+spec:
+  template:
+    spec:
+      containers:
+      - name: main
+        image: test-org/test-image:`+tag+`
+      - name: other
+        image: test-org/other-image:latest
+`)
+		writeFile("deploy/kustomization.yaml", `resources:
+- deployment.yaml
+images:
+- name: test-org/other-image
+  newTag: `+tag+`
+`)
+		runGit("add", "--all")
+		runGit("commit", "--message", "release "+tag)
+		runGit("tag", tag)
+	}
+	return repoDir
+}
+
+func TestManifest(t *testing.T) {
+	t.Run("Validate", func(t *testing.T) {
+		type testCase struct {
+			Message       string
+			Manifest      *Manifest
+			ExpectedError string
+		}
+		testCases := []testCase{
+			{
+				Message: "should return nil for a valid Manifest using URLTemplate",
+				Manifest: &Manifest{
+					Owner:       "test-owner",
+					Repository:  "test-repo",
+					Artifacts:   []AutoupdateArtifactRef{{SourceArtifact: "rancher/rancher"}},
+					URLTemplate: "https://example.com/{{ .Tag }}/manifests.yaml",
+				},
+				ExpectedError: "",
+			},
+			{
+				Message: "should return nil for a valid Manifest using git",
+				Manifest: &Manifest{
+					Owner:      "test-owner",
+					Repository: "test-repo",
+					Artifacts:  []AutoupdateArtifactRef{{SourceArtifact: "rancher/rancher"}},
+					Path:       "config/default",
+					Kustomize:  true,
+					Extractors: []ImageExtractor{{Type: ImageExtractorPodSpec}},
+				},
+				ExpectedError: "",
+			},
+			{
+				Message: "should return error from release selection",
+				Manifest: &Manifest{
+					Owner:     "test-owner",
+					Artifacts: []AutoupdateArtifactRef{{SourceArtifact: "rancher/rancher"}},
+				},
+				ExpectedError: "must specify Repository",
+			},
+			{
+				Message: "should return error for URLTemplate with Path",
+				Manifest: &Manifest{
+					Owner:       "test-owner",
+					Repository:  "test-repo",
+					Artifacts:   []AutoupdateArtifactRef{{SourceArtifact: "rancher/rancher"}},
+					URLTemplate: "https://example.com/{{ .Tag }}/manifests.yaml",
+					Path:        "deploy",
+				},
+				ExpectedError: "must not specify GitRepository, Path or Kustomize with URLTemplate",
+			},
+			{
+				Message: "should return error for invalid URLTemplate",
+				Manifest: &Manifest{
+					Owner:       "test-owner",
+					Repository:  "test-repo",
+					Artifacts:   []AutoupdateArtifactRef{{SourceArtifact: "rancher/rancher"}},
+					URLTemplate: "https://example.com/{{ .Tag",
+				},
+				ExpectedError: "invalid URLTemplate: template: URLTemplate:1: unclosed action",
+			},
+			{
+				Message: "should return error for Path outside of the repository",
+				Manifest: &Manifest{
+					Owner:      "test-owner",
+					Repository: "test-repo",
+					Artifacts:  []AutoupdateArtifactRef{{SourceArtifact: "rancher/rancher"}},
+					Path:       "../deploy",
+				},
+				ExpectedError: `Path "../deploy" must be a relative path within the repository`,
+			},
+			{
+				Message: "should return error for git without Path",
+				Manifest: &Manifest{
+					Owner:      "test-owner",
+					Repository: "test-repo",
+					Artifacts:  []AutoupdateArtifactRef{{SourceArtifact: "rancher/rancher"}},
+				},
+				ExpectedError: "must specify Path when URLTemplate is not set",
+			},
+			{
+				Message: "should return error for invalid extractor",
+				Manifest: &Manifest{
+					Owner:      "test-owner",
+					Repository: "test-repo",
+					Artifacts:  []AutoupdateArtifactRef{{SourceArtifact: "rancher/rancher"}},
+					Path:       "deploy",
+					Extractors: []ImageExtractor{{Type: ImageExtractorKeyPath}},
+				},
+				ExpectedError: "extractor 0 failed validation: must specify Path for KeyPath",
+			},
+		}
+		for _, testCase := range testCases {
+			t.Run(testCase.Message, func(t *testing.T) {
+				err := testCase.Manifest.Validate()
+				if testCase.ExpectedError == "" {
+					assert.Nil(t, err)
+				} else {
+					assert.EqualError(t, err, testCase.ExpectedError)
+				}
+			})
+		}
+	})
+
+	t.Run("GetUpdateArtifacts", func(t *testing.T) {
+		gitRepository := newTestManifestGitRepository(t, "v1.0.0", "v1.1.0")
+		mux := http.NewServeMux()
+		server := httptest.NewServer(mux)
+		t.Cleanup(server.Close)
+		releases := []*github.RepositoryRelease{
+			{TagName: github.Ptr("v1.1.0")},
+			{TagName: github.Ptr("v1.0.0")},
+		}
+		mux.HandleFunc("GET /repos/test-owner/test-repo/releases", func(w http.ResponseWriter, r *http.Request) {
+			assert.NoError(t, json.NewEncoder(w).Encode(releases))
+		})
+		mux.HandleFunc("GET /repos/test-owner/test-repo/releases/latest", func(w http.ResponseWriter, r *http.Request) {
+			assert.NoError(t, json.NewEncoder(w).Encode(releases[0]))
+		})
+		mux.HandleFunc("GET /download/{tag}/manifests.yaml", func(w http.ResponseWriter, r *http.Request) {
+			_, err := w.Write([]byte(`apiVersion: v1
+kind: Pod
+metadata:
+  name: test
+spec:
+  containers:
+  - name: main
+    image: test-org/test-image:` + r.PathValue("tag") + `
+`))
+			assert.NoError(t, err)
+		})
+
+		type testCase struct {
+			Message       string
+			Manifest      *Manifest
+			ExpectedTags  map[string][]string
+			ExpectedError string
+		}
+		testCases := []testCase{
+			{
+				Message: "should find images in manifests downloaded from URLTemplate",
+				Manifest: &Manifest{
+					URLTemplate: server.URL + "/download/{{ .Tag }}/manifests.yaml",
+				},
+				ExpectedTags: map[string][]string{
+					"test-org/test-image": {"v1.1.0", "v1.0.0"},
+				},
+			},
+			{
+				Message: "should only download releases kept by Versions",
+				Manifest: &Manifest{
+					URLTemplate: server.URL + "/download/{{ .Tag }}/manifests.yaml",
+					versions:    &VersionPolicy{KeepLatest: 1},
+				},
+				ExpectedTags: map[string][]string{
+					"test-org/test-image": {"v1.1.0"},
+				},
+			},
+			{
+				Message: "should find images in manifests in git repository",
+				Manifest: &Manifest{
+					GitRepository: gitRepository,
+					Path:          "deploy/deployment.yaml",
+					LatestOnly:    true,
+				},
+				ExpectedTags: map[string][]string{
+					"test-org/test-image":  {"v1.1.0"},
+					"test-org/other-image": {"latest"},
+				},
+			},
+			{
+				Message: "should build kustomization in git repository",
+				Manifest: &Manifest{
+					GitRepository: gitRepository,
+					Path:          "deploy",
+					Kustomize:     true,
+					Extractors:    []ImageExtractor{{Type: ImageExtractorPodSpec}},
+				},
+				ExpectedTags: map[string][]string{
+					"test-org/test-image":  {"v1.1.0", "v1.0.0"},
+					"test-org/other-image": {"v1.1.0", "v1.0.0"},
+				},
+			},
+			{
+				Message: "should only clone releases kept by Versions",
+				Manifest: &Manifest{
+					GitRepository: gitRepository,
+					Path:          "deploy/deployment.yaml",
+					versions:      &VersionPolicy{KeepLatest: 1},
+				},
+				ExpectedTags: map[string][]string{
+					"test-org/test-image":  {"v1.1.0"},
+					"test-org/other-image": {"latest"},
+				},
+			},
+			{
+				Message: "should leave out images in ImageDenylist",
+				Manifest: &Manifest{
+					GitRepository: gitRepository,
+					Path:          "deploy",
+					ImageDenylist: []string{"test-org/other-image"},
+					LatestOnly:    true,
+				},
+				ExpectedTags: map[string][]string{
+					"test-org/test-image": {"v1.1.0"},
+				},
+			},
+			{
+				Message: "should return error for image that is not in Artifacts",
+				Manifest: &Manifest{
+					GitRepository: gitRepository,
+					Path:          "docs",
+					LatestOnly:    true,
+				},
+				ExpectedError: "found image test-org/example-image but it is not present in Artifacts",
+			},
+		}
+		for _, testCase := range testCases {
+			t.Run(testCase.Message, func(t *testing.T) {
+				manifest := testCase.Manifest
+				manifest.Owner = "test-owner"
+				manifest.Repository = "test-repo"
+				manifest.Artifacts = []AutoupdateArtifactRef{
+					{SourceArtifact: "test-org/test-image"},
+					{SourceArtifact: "test-org/other-image"},
+				}
+				manifest.githubClient = newTestGithubClient(t, mux)
+				assert.NoError(t, manifest.Validate())
+				updateArtifacts, err := manifest.GetUpdateArtifacts(t.Context())
+				if testCase.ExpectedError != "" {
+					assert.EqualError(t, err, testCase.ExpectedError)
+					return
+				}
+				assert.NoError(t, err)
+				found := map[string][]string{}
+				for _, artifact := range updateArtifacts.Artifacts {
+					found[artifact.SourceArtifact] = artifact.Tags
+				}
+				assert.Equal(t, testCase.ExpectedTags, found)
+			})
+		}
+
+		t.Run("should skip releases whose manifests are not found at URLTemplate", func(t *testing.T) {
+			mux := http.NewServeMux()
+			server := httptest.NewServer(mux)
+			t.Cleanup(server.Close)
+			mux.HandleFunc("GET /repos/test-owner/test-repo/releases", func(w http.ResponseWriter, r *http.Request) {
+				assert.NoError(t, json.NewEncoder(w).Encode(releases))
+			})
+			mux.HandleFunc("GET /download/v1.0.0/manifests.yaml", func(w http.ResponseWriter, r *http.Request) {
+				_, err := w.Write([]byte("image: test-org/test-image:v1.0.0\n"))
+				assert.NoError(t, err)
+			})
+			manifest := &Manifest{
+				Owner:        "test-owner",
+				Repository:   "test-repo",
+				URLTemplate:  server.URL + "/download/{{ .Tag }}/manifests.yaml",
+				Artifacts:    []AutoupdateArtifactRef{{SourceArtifact: "test-org/test-image"}},
+				githubClient: newTestGithubClient(t, mux),
+			}
+			assert.NoError(t, manifest.Validate())
+			updateArtifacts, err := manifest.GetUpdateArtifacts(t.Context())
+			assert.NoError(t, err)
+			assert.Len(t, updateArtifacts.Artifacts, 1)
+			assert.Equal(t, []string{"v1.0.0"}, updateArtifacts.Artifacts[0].Tags)
+			assert.Equal(t, []SkippedRelease{{
+				Tag:    "v1.1.0",
+				Reason: "release has no manifests at " + server.URL + "/download/v1.1.0/manifests.yaml",
+			}}, updateArtifacts.SkippedReleases)
+		})
+	})
+}
@@ -0,0 +1,127 @@
+package autoupdate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rancher/artifact-mirror/internal/config"
+)
+
+// Duration is a time.Duration that is written in YAML in the form accepted
+// by time.ParseDuration, e.g. "72h".
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("duration must be a string: %w", err)
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	*d = Duration(duration)
+	return nil
+}
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+// checkMinAgeSupport returns an error if the update strategy of entry
+// cannot tell when the tags it finds were published. MinAge would hold
+// back such tags forever.
+func (entry ConfigEntry) checkMinAgeSupport() error {
+	switch {
+	case entry.GithubRelease != nil, entry.Manifest != nil, entry.ReleaseAsset != nil:
+		return nil
+	case entry.HelmChart != nil:
+		if strings.HasPrefix(entry.HelmChart.Repository, ociScheme) {
+			return errors.New("MinAge is not supported for charts in OCI repositories, which do not report push times")
+		}
+		return nil
+	case entry.Registry != nil:
+		artifactRefs := entry.Registry.Artifacts
+		// The tags of the other artifacts are not queried, and take their
+		// publish times from the first artifact.
+		if entry.Registry.TagDiscovery == TagDiscoveryFirstArtifact {
+			artifactRefs = artifactRefs[:1]
+		}
+		for _, artifactRef := range artifactRefs {
+			reportsPushTimes, err := registryReportsPushTimes(artifactRef.SourceArtifact)
+			if err != nil {
+				return err
+			}
+			if !reportsPushTimes {
+				return fmt.Errorf("MinAge is not supported for %s: only Docker Hub and Quay report push times", artifactRef.SourceArtifact)
+			}
+		}
+		return nil
+	default:
+		return errors.New("MinAge is not supported by this update strategy")
+	}
+}
+
+// deferRecentTags removes the tags that were published less than MinAge
+// before now from artifacts. publishTimes are the publish times returned
+// by GetUpdateArtifacts. The removed tags are returned as DeferredTags,
+// along with the artifacts that still have tags.
+func (entry ConfigEntry) deferRecentTags(artifacts []*config.Artifact, publishTimes map[string]time.Time, now time.Time) ([]*config.Artifact, []SkippedTag, error) {
+	if err := entry.checkMinAgeSupport(); err != nil {
+		return nil, nil, err
+	}
+
+	minAge := time.Duration(*entry.MinAge)
+	oldEnoughArtifacts := make([]*config.Artifact, 0, len(artifacts))
+	deferredTags := make([]SkippedTag, 0)
+	for _, artifact := range artifacts {
+		oldEnoughTags := make([]string, 0, len(artifact.Tags))
+		for _, tag := range artifact.Tags {
+			publishTime, ok := publishTimes[artifact.SourceArtifact+":"+tag]
+			if !ok {
+				deferredTags = append(deferredTags, SkippedTag{
+					SourceArtifact: artifact.SourceArtifact,
+					Tag:            tag,
+					Reason:         "publish time is unknown",
+				})
+				continue
+			}
+			if age := now.Sub(publishTime); age < minAge {
+				deferredTags = append(deferredTags, SkippedTag{
+					SourceArtifact: artifact.SourceArtifact,
+					Tag:            tag,
+					Reason:         fmt.Sprintf("published %s ago, which is less than MinAge of %s", age.Round(time.Minute), entry.MinAge),
+				})
+				continue
+			}
+			oldEnoughTags = append(oldEnoughTags, tag)
+		}
+		if len(oldEnoughTags) == 0 {
+			continue
+		}
+		oldEnoughArtifact := artifact.DeepCopy()
+		oldEnoughArtifact.Tags = oldEnoughTags
+		oldEnoughArtifacts = append(oldEnoughArtifacts, oldEnoughArtifact)
+	}
+	return oldEnoughArtifacts, deferredTags, nil
+}
+
+// addPublishTime records that tag of sourceArtifact was published at
+// publishTime in publishTimes. If the tag was already recorded, the
+// earlier time is kept.
+func addPublishTime(publishTimes map[string]time.Time, sourceArtifact, tag string, publishTime time.Time) {
+	if publishTime.IsZero() {
+		return
+	}
+	key := sourceArtifact + ":" + tag
+	if existing, ok := publishTimes[key]; !ok || publishTime.Before(existing) {
+		publishTimes[key] = publishTime
+	}
+}
@@ -0,0 +1,76 @@
+package autoupdate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rancher/artifact-mirror/internal/config"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/yaml"
+)
+
+func TestDuration(t *testing.T) {
+	t.Run("should unmarshal duration strings", func(t *testing.T) {
+		var entry struct{ MinAge Duration }
+		assert.NoError(t, yaml.Unmarshal([]byte("MinAge: 72h30m\n"), &entry))
+		assert.Equal(t, Duration(72*time.Hour+30*time.Minute), entry.MinAge)
+	})
+
+	t.Run("should marshal to duration strings", func(t *testing.T) {
+		contents, err := yaml.Marshal(struct{ MinAge Duration }{MinAge: Duration(72 * time.Hour)})
+		assert.NoError(t, err)
+		assert.Equal(t, "MinAge: 72h0m0s\n", string(contents))
+	})
+
+	t.Run("should return error for invalid durations", func(t *testing.T) {
+		var entry struct{ MinAge Duration }
+		assert.ErrorContains(t, yaml.Unmarshal([]byte("MinAge: 3d\n"), &entry), `unknown unit "d"`)
+		assert.ErrorContains(t, yaml.Unmarshal([]byte("MinAge: 3\n"), &entry), "duration must be a string")
+	})
+}
+
+func TestDeferRecentTags(t *testing.T) {
+	now := time.Date(2025, 6, 10, 0, 0, 0, 0, time.UTC)
+	minAge := Duration(72 * time.Hour)
+	entry := ConfigEntry{
+		Name:          "test-entry",
+		MinAge:        &minAge,
+		GithubRelease: &GithubRelease{},
+	}
+	publishTimes := map[string]time.Time{
+		"test-org/artifact1:v1.0.0": now.Add(-96 * time.Hour),
+		"test-org/artifact1:v1.1.0": now.Add(-24 * time.Hour),
+		"test-org/artifact2:v1.0.0": now.Add(-72 * time.Hour),
+	}
+	artifact1, err := config.NewArtifact("test-org/artifact1", []string{"v1.0.0", "v1.1.0"}, "", nil, nil)
+	assert.NoError(t, err)
+	artifact2, err := config.NewArtifact("test-org/artifact2", []string{"v1.0.0", "v1.2.0"}, "", nil, nil)
+	assert.NoError(t, err)
+	artifact3, err := config.NewArtifact("test-org/artifact3", []string{"v1.0.0"}, "", nil, nil)
+	assert.NoError(t, err)
+
+	artifacts, deferredTags, err := entry.deferRecentTags([]*config.Artifact{artifact1, artifact2, artifact3}, publishTimes, now)
+	assert.NoError(t, err)
+	assert.Len(t, artifacts, 2)
+	assert.Equal(t, "test-org/artifact1", artifacts[0].SourceArtifact)
+	assert.Equal(t, []string{"v1.0.0"}, artifacts[0].Tags)
+	assert.Equal(t, "test-org/artifact2", artifacts[1].SourceArtifact)
+	assert.Equal(t, []string{"v1.0.0"}, artifacts[1].Tags)
+	assert.Equal(t, []string{"v1.0.0", "v1.1.0"}, artifact1.Tags, "input artifacts should not be modified")
+	assert.Equal(t, []SkippedTag{
+		{SourceArtifact: "test-org/artifact1", Tag: "v1.1.0", Reason: "published 24h0m0s ago, which is less than MinAge of 72h0m0s"},
+		{SourceArtifact: "test-org/artifact2", Tag: "v1.2.0", Reason: "publish time is unknown"},
+		{SourceArtifact: "test-org/artifact3", Tag: "v1.0.0", Reason: "publish time is unknown"},
+	}, deferredTags)
+}
+
+func TestAddPublishTime(t *testing.T) {
+	publishTimes := map[string]time.Time{}
+	earlier := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	later := earlier.Add(time.Hour)
+	addPublishTime(publishTimes, "test-org/artifact", "v1.0.0", later)
+	addPublishTime(publishTimes, "test-org/artifact", "v1.0.0", earlier)
+	addPublishTime(publishTimes, "test-org/artifact", "v1.0.0", later)
+	addPublishTime(publishTimes, "test-org/artifact", "v1.1.0", time.Time{})
+	assert.Equal(t, map[string]time.Time{"test-org/artifact:v1.0.0": earlier}, publishTimes)
+}
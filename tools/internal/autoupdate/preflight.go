@@ -0,0 +1,91 @@
+package autoupdate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/rancher/artifact-mirror/internal/config"
+	"github.com/rancher/artifact-mirror/internal/reference"
+
+	"oras.land/oras-go/v2/errdef"
+	"oras.land/oras-go/v2/registry/remote"
+)
+
+// ErrTagNotFound is returned by a TagResolver for tags that do not exist in
+// the source registry, usually because they have not been pushed yet.
+var ErrTagNotFound = errors.New("tag not found")
+
+// TagResolver checks that a tag of a source artifact can be pulled. It is
+// used to avoid proposing tags that have not been pushed yet. ResolveTag
+// returns an error wrapping ErrTagNotFound if the tag does not exist, and
+// any other error if it could not be checked.
+type TagResolver interface {
+	ResolveTag(ctx context.Context, sourceArtifact, tag string) error
+}
+
+// SkippedTag is a tag that was found by an update strategy, but that was
+// left out of the pull request. It is proposed again on a later run.
+type SkippedTag struct {
+	SourceArtifact string `json:"sourceArtifact"`
+	Tag            string `json:"tag"`
+	Reason         string `json:"reason"`
+}
+
+// OrasTagResolver resolves tags by fetching their manifests from the source
+// registry. Layers are not downloaded.
+type OrasTagResolver struct {
+	// Credentials are used to authenticate to the source registry.
+	Credentials *RegistryCredentials
+}
+
+func (resolver OrasTagResolver) ResolveTag(ctx context.Context, sourceArtifact, tag string) error {
+	ref, err := reference.Parse(sourceArtifact)
+	if err != nil {
+		return fmt.Errorf("failed to parse source artifact: %w", err)
+	}
+	repo, err := remote.NewRepository(ref.Name())
+	if err != nil {
+		return fmt.Errorf("failed to instantiate repository: %w", err)
+	}
+	repo.Client = NewAuthClient(resolver.Credentials)
+	if _, err := repo.Resolve(ctx, tag); err != nil {
+		if errors.Is(err, errdef.ErrNotFound) {
+			return ErrTagNotFound
+		}
+		return fmt.Errorf("failed to resolve %s:%s: %w", sourceArtifact, tag, err)
+	}
+	return nil
+}
+
+// filterResolvableTags returns the parts of artifacts whose tags can be
+// resolved by resolver, along with the tags that were not found. Any other
+// error from resolver is returned, since it says nothing about whether the
+// tag has been pushed.
+func filterResolvableTags(ctx context.Context, resolver TagResolver, artifacts []*config.Artifact) ([]*config.Artifact, []SkippedTag, error) {
+	resolvableArtifacts := make([]*config.Artifact, 0, len(artifacts))
+	skippedTags := make([]SkippedTag, 0)
+	for _, artifact := range artifacts {
+		resolvableTags := make([]string, 0, len(artifact.Tags))
+		for _, tag := range artifact.Tags {
+			if err := resolver.ResolveTag(ctx, artifact.SourceArtifact, tag); errors.Is(err, ErrTagNotFound) {
+				skippedTags = append(skippedTags, SkippedTag{
+					SourceArtifact: artifact.SourceArtifact,
+					Tag:            tag,
+					Reason:         err.Error(),
+				})
+				continue
+			} else if err != nil {
+				return nil, nil, err
+			}
+			resolvableTags = append(resolvableTags, tag)
+		}
+		if len(resolvableTags) == 0 {
+			continue
+		}
+		resolvableArtifact := artifact.DeepCopy()
+		resolvableArtifact.Tags = resolvableTags
+		resolvableArtifacts = append(resolvableArtifacts, resolvableArtifact)
+	}
+	return resolvableArtifacts, skippedTags, nil
+}
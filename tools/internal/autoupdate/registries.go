@@ -1,27 +1,146 @@
 package autoupdate
 
 import (
+	"bytes"
+	"cmp"
+	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"os"
 	"strconv"
+	"time"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/errdef"
+	"oras.land/oras-go/v2/registry/remote"
+	"oras.land/oras-go/v2/registry/remote/auth"
+)
+
+const (
+	dockerHubBaseURL      = "https://registry.hub.docker.com"
+	quayBaseURL           = "https://quay.io"
+	githubRegistryBaseURL = "https://ghcr.io"
 )
 
 type DockerHub struct {
 	Namespace  string
 	Repository string
+	// credentials are used to log in to the Docker Hub API, so that
+	// private repositories can be listed.
+	credentials *RegistryCredentials
+	// baseURL is used instead of dockerHubBaseURL if set.
+	baseURL string
+	// client is used instead of httpClient if set.
+	client *http.Client
 }
 
-func (d DockerHub) getArtifactTags() ([]string, error) {
-	var allTags []string
+func (d DockerHub) getArtifactTags(ctx context.Context) ([]string, error) {
+	tagMetadata, err := d.listTagMetadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tags := make([]string, len(tagMetadata))
+	for i, tag := range tagMetadata {
+		tags[i] = tag.Name
+	}
+	return tags, nil
+}
+
+// selectTagMetadata returns the elements of tagMetadata for tags, keyed by
+// tag.
+func selectTagMetadata(tagMetadata []TagMetadata, tags []string) map[string]TagMetadata {
+	wanted := make(map[string]struct{}, len(tags))
+	for _, tag := range tags {
+		wanted[tag] = struct{}{}
+	}
+	metadata := make(map[string]TagMetadata, len(tags))
+	for _, tag := range tagMetadata {
+		if _, ok := wanted[tag.Name]; ok {
+			metadata[tag.Name] = tag
+		}
+	}
+	return metadata
+}
+
+func (d DockerHub) getTagMetadata(ctx context.Context, tags []string) (map[string]TagMetadata, error) {
+	tagMetadata, err := d.listTagMetadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return selectTagMetadata(tagMetadata, tags), nil
+}
+
+// listTagMetadata returns the metadata of every tag, which the tag list
+// already includes.
+func (d DockerHub) listTagMetadata(ctx context.Context) ([]TagMetadata, error) {
+	token, err := d.login(ctx)
+	if err != nil {
+		return nil, err
+	}
+	dockerHubTags, err := d.fetchAllPages(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	tagMetadata := make([]TagMetadata, len(dockerHubTags))
+	for i, tag := range dockerHubTags {
+		tagMetadata[i] = tag.toTagMetadata()
+	}
+	return tagMetadata, nil
+}
+
+// login returns a token for the Docker Hub API if there are credentials
+// for Docker Hub, and an empty token, with which the API is used
+// anonymously, otherwise. The password may be a personal access token.
+func (d DockerHub) login(ctx context.Context) (string, error) {
+	credential, err := d.credentials.Credential(ctx, DockerHubHost)
+	if err != nil {
+		return "", fmt.Errorf("failed to get credentials: %w", err)
+	}
+	if credential.Username == "" || credential.Password == "" {
+		return "", nil
+	}
+
+	payload, err := json.Marshal(map[string]string{
+		"username": credential.Username,
+		"password": credential.Password,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal login request: %w", err)
+	}
+	reqUrl := cmp.Or(d.baseURL, dockerHubBaseURL) + "/v2/users/login"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, reqUrl, bytes.NewReader(payload))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := doRequestWithClient(d.client, req)
+	if err != nil {
+		return "", fmt.Errorf("failed to log in to Docker Hub: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var data struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return "", fmt.Errorf("failed to parse login response: %w", err)
+	}
+	return data.Token, nil
+}
+
+func (d DockerHub) fetchAllPages(ctx context.Context, token string) ([]DockerHubTag, error) {
+	var allTags []DockerHubTag
 	page := 1
 
 	for {
-		tags, hasNext, err := d.fetchPage(page)
+		tags, hasNext, err := d.fetchPage(ctx, token, page)
 		if err != nil {
 			return nil, err
 		}
@@ -36,17 +155,20 @@ func (d DockerHub) getArtifactTags() ([]string, error) {
 	return allTags, nil
 }
 
-func (d DockerHub) fetchPage(page int) ([]string, bool, error) {
+func (d DockerHub) fetchPage(ctx context.Context, token string, page int) ([]DockerHubTag, bool, error) {
 	params := url.Values{}
 	params.Add("page", strconv.Itoa(page))
 	params.Add("page_size", "100")
-	reqUrl := fmt.Sprintf("https://registry.hub.docker.com/v2/namespaces/%s/repositories/%s/tags", d.Namespace, d.Repository)
-	req, err := http.NewRequest(http.MethodGet, reqUrl, nil)
+	reqUrl := fmt.Sprintf("%s/v2/namespaces/%s/repositories/%s/tags", cmp.Or(d.baseURL, dockerHubBaseURL), d.Namespace, d.Repository)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqUrl, nil)
 	if err != nil {
 		return nil, false, fmt.Errorf("failed to create request: %w", err)
 	}
 	req.URL.RawQuery = params.Encode()
-	resp, err := doRequestWithRetries(req)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := doRequestWithClient(d.client, req)
 	if err != nil {
 		return nil, false, err
 	}
@@ -60,24 +182,90 @@ func (d DockerHub) fetchPage(page int) ([]string, bool, error) {
 	if err := json.Unmarshal(body, &data); err != nil {
 		return nil, false, err
 	}
-	tags := make([]string, len(data.Results))
-	for i, tag := range data.Results {
-		tags[i] = tag.Name
-	}
-	return tags, data.Next != "", nil
+	return data.Results, data.Next != "", nil
 }
 
 type QuayIO struct {
 	Namespace  string
 	Repository string
+	// credentials are used to list private repositories through the
+	// registry API, since the Quay API does not accept them.
+	credentials *RegistryCredentials
+	// baseURL is used instead of quayBaseURL if set.
+	baseURL string
+	// client is used instead of httpClient if set.
+	client *http.Client
+}
+
+func (q QuayIO) getArtifactTags(ctx context.Context) ([]string, error) {
+	tagMetadata, err := q.listTagMetadata(ctx)
+	if errors.Is(err, errTagMetadataUnavailable) {
+		ociRegistry, err := q.ociRegistry()
+		if err != nil {
+			return nil, err
+		}
+		return ociRegistry.getArtifactTags(ctx)
+	} else if err != nil {
+		return nil, err
+	}
+	tags := make([]string, len(tagMetadata))
+	for i, tag := range tagMetadata {
+		tags[i] = tag.Name
+	}
+	return tags, nil
+}
+
+func (q QuayIO) getTagMetadata(ctx context.Context, tags []string) (map[string]TagMetadata, error) {
+	tagMetadata, err := q.listTagMetadata(ctx)
+	if errors.Is(err, errTagMetadataUnavailable) {
+		ociRegistry, err := q.ociRegistry()
+		if err != nil {
+			return nil, err
+		}
+		return ociRegistry.getTagMetadata(ctx, tags)
+	} else if err != nil {
+		return nil, err
+	}
+	return selectTagMetadata(tagMetadata, tags), nil
+}
+
+// listTagMetadata returns the metadata of every tag, which the tag list
+// already includes. The Quay API only accepts OAuth tokens, not registry
+// credentials. If it denies access to a repository for which there are
+// credentials, errTagMetadataUnavailable is returned, and the tags are
+// listed through the registry API instead, which does not report push
+// times.
+func (q QuayIO) listTagMetadata(ctx context.Context) ([]TagMetadata, error) {
+	quayTags, err := q.fetchAllPages(ctx)
+	if isAccessDenied(err) {
+		ociRegistry, ociErr := q.ociRegistry()
+		if ociErr != nil {
+			return nil, ociErr
+		}
+		credential, credentialErr := q.credentials.Credential(ctx, ociRegistry.Registry)
+		if credentialErr != nil {
+			return nil, fmt.Errorf("failed to get credentials: %w", credentialErr)
+		}
+		if credential != auth.EmptyCredential {
+			return nil, fmt.Errorf("%w: %w", errTagMetadataUnavailable, err)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	tagMetadata := make([]TagMetadata, len(quayTags))
+	for i, tag := range quayTags {
+		tagMetadata[i] = tag.toTagMetadata()
+	}
+	return tagMetadata, nil
 }
 
-func (q QuayIO) getArtifactTags() ([]string, error) {
-	var allTags []string
+func (q QuayIO) fetchAllPages(ctx context.Context) ([]QuayTag, error) {
+	var allTags []QuayTag
 	page := 1
 
 	for {
-		tags, hasNext, err := q.fetchPage(page)
+		tags, hasNext, err := q.fetchPage(ctx, page)
 		if err != nil {
 			return nil, err
 		}
@@ -92,17 +280,17 @@ func (q QuayIO) getArtifactTags() ([]string, error) {
 	return allTags, nil
 }
 
-func (q QuayIO) fetchPage(page int) ([]string, bool, error) {
+func (q QuayIO) fetchPage(ctx context.Context, page int) ([]QuayTag, bool, error) {
 	params := url.Values{}
 	params.Add("page", strconv.Itoa(page))
 	params.Add("page_size", "100")
-	reqUrl := fmt.Sprintf("https://quay.io/api/v1/repository/%s/%s/tag/", q.Namespace, q.Repository)
-	req, err := http.NewRequest(http.MethodGet, reqUrl, nil)
+	reqUrl := fmt.Sprintf("%s/api/v1/repository/%s/%s/tag/", cmp.Or(q.baseURL, quayBaseURL), q.Namespace, q.Repository)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqUrl, nil)
 	if err != nil {
 		return nil, false, fmt.Errorf("failed to create request: %w", err)
 	}
 	req.URL.RawQuery = params.Encode()
-	resp, err := doRequestWithRetries(req)
+	resp, err := doRequestWithClient(q.client, req)
 	if err != nil {
 		return nil, false, err
 	}
@@ -116,78 +304,43 @@ func (q QuayIO) fetchPage(page int) ([]string, bool, error) {
 	if err := json.Unmarshal(body, &data); err != nil {
 		return nil, false, err
 	}
-	tags := make([]string, len(data.Tags))
-	for i, tag := range data.Tags {
-		tags[i] = tag.Name
-	}
-	return tags, data.HasAdditional, nil
+	return data.Tags, data.HasAdditional, nil
 }
 
-type SUSERegistry struct {
+type GitHubRegistry struct {
 	Namespace  string
 	Repository string
+	// credentials are used to authenticate to ghcr.io.
+	credentials *RegistryCredentials
+	// baseURL is used instead of githubRegistryBaseURL if set.
+	baseURL string
+	// client is used instead of httpClient if set.
+	client *http.Client
 }
 
-func (s SUSERegistry) getArtifactTags() ([]string, error) {
-	token, err := s.getSuseAuthToken(s.Namespace, s.Repository)
-	if err != nil {
-		return nil, err
-	}
-	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("https://registry.suse.com/v2/%s/%s/tags/list", s.Namespace, s.Repository), nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-	req.Header.Set("Authorization", "Bearer "+token)
-	resp, err := doRequestWithRetries(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
+// getArtifactTags lists tags through the registry API with the
+// credentials for ghcr.io, if there are any. Otherwise, GITHUB_TOKEN is
+// used, which ghcr.io accepts in place of a registry token. Without either,
+// tags are listed anonymously.
+func (g GitHubRegistry) getArtifactTags(ctx context.Context) ([]string, error) {
+	ociRegistry, err := g.ociRegistry()
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
-	}
-	var data GenericTagsResponse
-	if err := json.Unmarshal(body, &data); err != nil {
 		return nil, err
 	}
-	return data.Tags, nil
-}
-
-func (s SUSERegistry) getSuseAuthToken(namespace, repository string) (string, error) {
-	tokenURL := "https://registry.suse.com/auth?service=SUSE+Linux+Docker+Registry"
-	req, _ := http.NewRequest("GET", tokenURL, nil)
-	params := req.URL.Query()
-	params.Add("scope", fmt.Sprintf("repository:%s/%s:pull", namespace, repository))
-	req.URL.RawQuery = params.Encode()
-
-	resp, err := doRequestWithRetries(req)
+	credential, err := g.credentials.Credential(ctx, ociRegistry.Registry)
 	if err != nil {
-		return "", fmt.Errorf("suse token request failed: %w", err)
+		return nil, fmt.Errorf("failed to get credentials: %w", err)
 	}
-	defer resp.Body.Close()
-
-	body, _ := io.ReadAll(resp.Body)
-	var tokenData SuseTokenResponse
-	if err := json.Unmarshal(body, &tokenData); err != nil {
-		return "", fmt.Errorf("failed to unmarshal suse token: %w", err)
+	githubToken := os.Getenv("GITHUB_TOKEN")
+	if credential != auth.EmptyCredential || githubToken == "" {
+		return ociRegistry.getArtifactTags(ctx)
 	}
-	return tokenData.AccessToken, nil
-}
 
-type GitHubRegistry struct {
-	Namespace  string
-	Repository string
-}
-
-func (g GitHubRegistry) getArtifactTags() ([]string, error) {
-	githubToken := os.Getenv("GITHUB_TOKEN")
 	token := base64.StdEncoding.EncodeToString([]byte(githubToken))
 	var AllTags []string
 	var nextUrl string
 	for {
-		tags, next, err := g.fetchTags(token, nextUrl)
+		tags, next, err := g.fetchTags(ctx, token, nextUrl)
 		if err != nil {
 			return nil, err
 		}
@@ -200,19 +353,34 @@ func (g GitHubRegistry) getArtifactTags() ([]string, error) {
 	return AllTags, nil
 }
 
-func (g GitHubRegistry) fetchTags(token, url string) ([]string, string, error) {
+// getTagMetadata gets tag metadata from the manifests of tags, in the same
+// way as OCIRegistry.
+func (g GitHubRegistry) getTagMetadata(ctx context.Context, tags []string) (map[string]TagMetadata, error) {
+	ociRegistry, err := g.ociRegistry()
+	if err != nil {
+		return nil, err
+	}
+	return ociRegistry.getTagMetadata(ctx, tags)
+}
+
+// ociRegistry returns an OCIRegistry for the repository on ghcr.io.
+func (g GitHubRegistry) ociRegistry() (OCIRegistry, error) {
+	return newOCIRegistryFromBaseURL(cmp.Or(g.baseURL, githubRegistryBaseURL), g.Namespace+"/"+g.Repository, g.credentials, g.client)
+}
+
+func (g GitHubRegistry) fetchTags(ctx context.Context, token, url string) ([]string, string, error) {
 	var registryUrl string
 	if url != "" {
 		registryUrl = url
 	} else {
-		registryUrl = fmt.Sprintf("https://ghcr.io/v2/%s/%s/tags/list", g.Namespace, g.Repository)
+		registryUrl = fmt.Sprintf("%s/v2/%s/%s/tags/list", cmp.Or(g.baseURL, githubRegistryBaseURL), g.Namespace, g.Repository)
 	}
-	req, err := http.NewRequest(http.MethodGet, registryUrl, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, registryUrl, nil)
 	if err != nil {
 		return nil, "", fmt.Errorf("failed to create request: %w", err)
 	}
 	req.Header.Set("Authorization", "Bearer "+token)
-	resp, err := doRequestWithRetries(req)
+	resp, err := doRequestWithClient(g.client, req)
 	if err != nil {
 		return nil, "", err
 	}
@@ -230,88 +398,181 @@ func (g GitHubRegistry) fetchTags(token, url string) ([]string, string, error) {
 	if nextLink == "" {
 		return data.Tags, "", nil
 	}
-	return data.Tags, "https://ghcr.io" + nextLink, nil
+	return data.Tags, cmp.Or(g.baseURL, githubRegistryBaseURL) + nextLink, nil
 }
 
-type GoogleRegistry struct {
-	Namespace  string
-	Repository string
+// ociRegistry returns an OCIRegistry for the repository on quay.io.
+func (q QuayIO) ociRegistry() (OCIRegistry, error) {
+	return newOCIRegistryFromBaseURL(cmp.Or(q.baseURL, quayBaseURL), q.Namespace+"/"+q.Repository, q.credentials, q.client)
 }
 
-func (g GoogleRegistry) getArtifactTags() ([]string, error) {
-	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("https://gcr.io/v2/%s/%s/tags/list", g.Namespace, g.Repository), nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-	resp, err := doRequestWithRetries(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
+// newOCIRegistryFromBaseURL returns an OCIRegistry for repository on the
+// registry at baseURL.
+func newOCIRegistryFromBaseURL(rawBaseURL, repository string, credentials *RegistryCredentials, client *http.Client) (OCIRegistry, error) {
+	baseURL, err := url.Parse(rawBaseURL)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
-	}
-	var data GenericTagsResponse
-	if err := json.Unmarshal(body, &data); err != nil {
-		return nil, err
-	}
-	return data.Tags, nil
+		return OCIRegistry{}, fmt.Errorf("invalid base URL: %w", err)
+	}
+	return OCIRegistry{
+		Registry:    baseURL.Host,
+		Repository:  repository,
+		plainHTTP:   baseURL.Scheme == "http",
+		credentials: credentials,
+		client:      client,
+	}, nil
 }
 
-type K8sRegistry struct {
-	Namespace  string
+// OCIRegistry lists tags through the OCI distribution API. It handles
+// token authentication challenges and paginated tag lists, so it works with
+// any registry that implements the API. It is used for all registries that
+// do not have a dedicated ArtifactRegistry.
+type OCIRegistry struct {
+	Registry   string
 	Repository string
+	// plainHTTP causes the registry to be accessed over HTTP.
+	plainHTTP bool
+	// credentials are used to authenticate to the registry.
+	credentials *RegistryCredentials
+	// client is used instead of registryHTTPClient if set.
+	client *http.Client
 }
 
-func (k K8sRegistry) getArtifactTags() ([]string, error) {
-	var registryUrl string
-	if k.Namespace != "" {
-		registryUrl = fmt.Sprintf("https://registry.k8s.io/v2/%s/%s/tags/list", k.Namespace, k.Repository)
-	} else {
-		registryUrl = fmt.Sprintf("https://registry.k8s.io/v2/%s/tags/list", k.Repository)
-	}
-
-	req, err := http.NewRequest(http.MethodGet, registryUrl, nil)
+func (o OCIRegistry) newRepository() (*remote.Repository, error) {
+	repo, err := remote.NewRepository(o.Registry + "/" + o.Repository)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, fmt.Errorf("failed to instantiate repository: %w", err)
+	}
+	repo.PlainHTTP = o.plainHTTP
+	authClient := NewAuthClient(o.credentials)
+	if o.client != nil {
+		authClient.Client = o.client
 	}
-	resp, err := doRequestWithRetries(req)
+	repo.Client = authClient
+	return repo, nil
+}
+
+func (o OCIRegistry) getArtifactTags(ctx context.Context) ([]string, error) {
+	repo, err := o.newRepository()
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	allTags := make([]string, 0)
+	err = repo.Tags(ctx, "", func(tags []string) error {
+		allTags = append(allTags, tags...)
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
+		return nil, fmt.Errorf("failed to list tags: %w", err)
 	}
-	var data GenericTagsResponse
-	if err := json.Unmarshal(body, &data); err != nil {
+	return allTags, nil
+}
+
+// getTagMetadata fetches the manifest of each of tags. The creation time
+// is taken from the org.opencontainers.image.created annotation of the
+// manifest or, for single manifests, from the image config. The OCI
+// distribution API does not report push times, so Pushed is left empty.
+func (o OCIRegistry) getTagMetadata(ctx context.Context, tags []string) (map[string]TagMetadata, error) {
+	repo, err := o.newRepository()
+	if err != nil {
 		return nil, err
 	}
-	return data.Tags, nil
+
+	metadata := make(map[string]TagMetadata, len(tags))
+	for _, tag := range tags {
+		descriptor, manifestBytes, err := oras.FetchBytes(ctx, repo, tag, oras.DefaultFetchBytesOptions)
+		if errors.Is(err, errdef.ErrNotFound) {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to fetch manifest of %s: %w", tag, err)
+		}
+		var manifest ociManifest
+		if err := json.Unmarshal(manifestBytes, &manifest); err != nil {
+			return nil, fmt.Errorf("failed to parse manifest of %s: %w", tag, err)
+		}
+
+		tagMetadata := TagMetadata{
+			Name:      tag,
+			Digest:    descriptor.Digest.String(),
+			MultiArch: isIndexMediaType(descriptor.MediaType),
+		}
+		if created, ok := manifest.Annotations[ocispec.AnnotationCreated]; ok {
+			if createdTime, err := time.Parse(time.RFC3339, created); err == nil {
+				tagMetadata.Created = createdTime
+			}
+		}
+		if tagMetadata.Created.IsZero() && manifest.Config != nil {
+			configBytes, err := content.FetchAll(ctx, repo, *manifest.Config)
+			if err != nil {
+				return nil, fmt.Errorf("failed to fetch config of %s: %w", tag, err)
+			}
+			var imageConfig struct {
+				Created *time.Time `json:"created"`
+			}
+			if err := json.Unmarshal(configBytes, &imageConfig); err == nil && imageConfig.Created != nil {
+				tagMetadata.Created = *imageConfig.Created
+			}
+		}
+		metadata[tag] = tagMetadata
+	}
+	return metadata, nil
+}
+
+// ociManifest contains the fields that OCI image manifests, OCI image
+// indexes and their docker equivalents have in common.
+type ociManifest struct {
+	Config      *ocispec.Descriptor `json:"config,omitempty"`
+	Annotations map[string]string   `json:"annotations,omitempty"`
 }
 
 // DockerHubResponse matches the structure of the Docker Hub API response
 type DockerHubResponse struct {
-	Next    string `json:"next"`
-	Results []struct {
-		Name string `json:"name"`
-	} `json:"results"`
+	Next    string         `json:"next"`
+	Results []DockerHubTag `json:"results"`
+}
+
+type DockerHubTag struct {
+	Name          string    `json:"name"`
+	Digest        string    `json:"digest"`
+	MediaType     string    `json:"media_type"`
+	TagLastPushed time.Time `json:"tag_last_pushed"`
+}
+
+func (t DockerHubTag) toTagMetadata() TagMetadata {
+	return TagMetadata{
+		Name:      t.Name,
+		Digest:    t.Digest,
+		Created:   t.TagLastPushed,
+		Pushed:    t.TagLastPushed,
+		MultiArch: isIndexMediaType(t.MediaType),
+	}
 }
 
 // QuayResponse matches the structure of the Quay.io API response
 type QuayResponse struct {
-	HasAdditional bool `json:"has_additional"`
-	Tags          []struct {
-		Name string `json:"name"`
-	} `json:"tags"`
+	HasAdditional bool      `json:"has_additional"`
+	Tags          []QuayTag `json:"tags"`
+}
+
+type QuayTag struct {
+	Name           string `json:"name"`
+	ManifestDigest string `json:"manifest_digest"`
+	IsManifestList bool   `json:"is_manifest_list"`
+	// StartTS is the unix time at which the tag was pushed.
+	StartTS int64 `json:"start_ts"`
 }
 
-type SuseTokenResponse struct {
-	AccessToken string `json:"access_token"`
+func (t QuayTag) toTagMetadata() TagMetadata {
+	metadata := TagMetadata{
+		Name:      t.Name,
+		Digest:    t.ManifestDigest,
+		MultiArch: t.IsManifestList,
+	}
+	if t.StartTS != 0 {
+		metadata.Created = time.Unix(t.StartTS, 0)
+		metadata.Pushed = metadata.Created
+	}
+	return metadata
 }
 
 type GenericTagsResponse struct {
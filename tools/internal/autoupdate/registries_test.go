@@ -0,0 +1,403 @@
+package autoupdate
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/rancher/artifact-mirror/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOCIRegistry(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	mux.HandleFunc("GET /token", func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("scope") {
+		case "repository:test-org/test-image:pull":
+			assert.NoError(t, json.NewEncoder(w).Encode(map[string]string{"token": "test-token"}))
+		case "repository:test-org/private-image:pull":
+			if username, password, ok := r.BasicAuth(); !ok || username != "test-user" || password != "test-pass" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			assert.NoError(t, json.NewEncoder(w).Encode(map[string]string{"token": "private-token"}))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	})
+	mux.HandleFunc("GET /v2/test-org/private-image/tags/list", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer private-token" {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="test",scope="repository:test-org/private-image:pull"`, server.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		assert.NoError(t, json.NewEncoder(w).Encode(map[string]any{"name": "test-org/private-image", "tags": []string{"v1.0.0"}}))
+	})
+	mux.HandleFunc("GET /v2/test-org/test-image/tags/list", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-token" {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="test",scope="repository:test-org/test-image:pull"`, server.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		tags := []string{"v1.0.0", "v1.1.0"}
+		if r.URL.Query().Get("last") == "" {
+			w.Header().Set("Link", `</v2/test-org/test-image/tags/list?last=v1.1.0>; rel="next"`)
+		} else {
+			tags = []string{"v1.2.0"}
+		}
+		assert.NoError(t, json.NewEncoder(w).Encode(map[string]any{"name": "test-org/test-image", "tags": tags}))
+	})
+
+	testConfig := []byte(`{"created": "2025-06-07T08:09:10Z"}`)
+	testManifest := []byte(fmt.Sprintf(`{"schemaVersion": 2, "mediaType": %q, "config": {"mediaType": %q, "digest": %q, "size": %d}, "layers": []}`,
+		ocispec.MediaTypeImageManifest, ocispec.MediaTypeImageConfig, digest.FromBytes(testConfig), len(testConfig)))
+	testIndex := []byte(fmt.Sprintf(`{"schemaVersion": 2, "mediaType": %q, "manifests": [], "annotations": {%q: "2025-01-02T03:04:05Z"}}`,
+		ocispec.MediaTypeImageIndex, ocispec.AnnotationCreated))
+	mux.HandleFunc("GET /v2/test-org/metadata-image/manifests/{reference}", func(w http.ResponseWriter, r *http.Request) {
+		var content []byte
+		switch r.PathValue("reference") {
+		case "v1.0.0":
+			content = testIndex
+			w.Header().Set("Content-Type", ocispec.MediaTypeImageIndex)
+		case "v1.1.0":
+			content = testManifest
+			w.Header().Set("Content-Type", ocispec.MediaTypeImageManifest)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Docker-Content-Digest", digest.FromBytes(content).String())
+		_, err := w.Write(content)
+		assert.NoError(t, err)
+	})
+	mux.HandleFunc("GET /v2/test-org/metadata-image/blobs/{digest}", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, digest.FromBytes(testConfig).String(), r.PathValue("digest"))
+		_, err := w.Write(testConfig)
+		assert.NoError(t, err)
+	})
+
+	t.Run("should list all pages of tags with token authentication", func(t *testing.T) {
+		registry := OCIRegistry{
+			Registry:   strings.TrimPrefix(server.URL, "http://"),
+			Repository: "test-org/test-image",
+			plainHTTP:  true,
+		}
+		tags, err := registry.getArtifactTags(t.Context())
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"v1.0.0", "v1.1.0", "v1.2.0"}, tags)
+	})
+
+	t.Run("should authenticate with credentials", func(t *testing.T) {
+		host := strings.TrimPrefix(server.URL, "http://")
+		registry := OCIRegistry{
+			Registry:   host,
+			Repository: "test-org/private-image",
+			plainHTTP:  true,
+			credentials: &RegistryCredentials{
+				Repositories: []config.Repository{{Registry: host, Username: "test-user", Password: "test-pass"}},
+			},
+		}
+		tags, err := registry.getArtifactTags(t.Context())
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"v1.0.0"}, tags)
+	})
+
+	t.Run("should return error for private repository without credentials", func(t *testing.T) {
+		registry := OCIRegistry{
+			Registry:   strings.TrimPrefix(server.URL, "http://"),
+			Repository: "test-org/private-image",
+			plainHTTP:  true,
+		}
+		_, err := registry.getArtifactTags(t.Context())
+		assert.ErrorContains(t, err, "failed to list tags")
+	})
+
+	t.Run("should get tag metadata from manifests", func(t *testing.T) {
+		registry := OCIRegistry{
+			Registry:   strings.TrimPrefix(server.URL, "http://"),
+			Repository: "test-org/metadata-image",
+			plainHTTP:  true,
+		}
+		metadata, err := registry.getTagMetadata(t.Context(), []string{"v1.0.0", "v1.1.0", "v1.2.0"})
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]TagMetadata{
+			"v1.0.0": {
+				Name:      "v1.0.0",
+				Digest:    digest.FromBytes(testIndex).String(),
+				Created:   time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+				MultiArch: true,
+			},
+			"v1.1.0": {
+				Name:    "v1.1.0",
+				Digest:  digest.FromBytes(testManifest).String(),
+				Created: time.Date(2025, 6, 7, 8, 9, 10, 0, time.UTC),
+			},
+		}, metadata)
+	})
+
+	t.Run("should return error for missing repository", func(t *testing.T) {
+		registry := OCIRegistry{
+			Registry:   strings.TrimPrefix(server.URL, "http://"),
+			Repository: "test-org/missing",
+			plainHTTP:  true,
+		}
+		_, err := registry.getArtifactTags(t.Context())
+		assert.ErrorContains(t, err, "failed to list tags")
+	})
+}
+
+func TestDockerHubTagToTagMetadata(t *testing.T) {
+	tag := DockerHubTag{
+		Name:          "v1.0.0",
+		Digest:        "sha256:abcd",
+		MediaType:     dockerManifestListMediaType,
+		TagLastPushed: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+	assert.Equal(t, TagMetadata{
+		Name:      "v1.0.0",
+		Digest:    "sha256:abcd",
+		Created:   time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+		Pushed:    time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+		MultiArch: true,
+	}, tag.toTagMetadata())
+}
+
+func TestQuayTagToTagMetadata(t *testing.T) {
+	tag := QuayTag{
+		Name:           "v1.0.0",
+		ManifestDigest: "sha256:abcd",
+		StartTS:        1735787045,
+	}
+	assert.Equal(t, TagMetadata{
+		Name:    "v1.0.0",
+		Digest:  "sha256:abcd",
+		Created: time.Unix(1735787045, 0),
+		Pushed:  time.Unix(1735787045, 0),
+	}, tag.toTagMetadata())
+}
+
+// The tests below replay responses recorded with -record. They only check
+// the structure of the results and the requests that were made, so that
+// the recordings can be refreshed without changing the tests.
+
+func TestDockerHub(t *testing.T) {
+	t.Run("should list tags of all pages", func(t *testing.T) {
+		client := newReplayClient(t, "dockerhub-tags")
+		log := logRequests(client)
+		dockerHub := DockerHub{Namespace: "rancher", Repository: "fleet", client: client}
+		tags, err := dockerHub.getArtifactTags(t.Context())
+		assert.NoError(t, err)
+		assert.NotEmpty(t, tags)
+		assert.True(t, slices.ContainsFunc(log.URLs(), hasQuery("page", "2")), "should follow pagination")
+	})
+
+	t.Run("should get tag metadata", func(t *testing.T) {
+		dockerHub := DockerHub{Namespace: "rancher", Repository: "fleet", client: newReplayClient(t, "dockerhub-tags")}
+		metadata, err := dockerHub.getTagMetadata(t.Context(), []string{"v0.12.0", "does-not-exist"})
+		assert.NoError(t, err)
+		assertPushedTagMetadata(t, metadata, "v0.12.0")
+		assert.NotContains(t, metadata, "does-not-exist")
+	})
+
+	t.Run("should return error for missing repository", func(t *testing.T) {
+		dockerHub := DockerHub{Namespace: "rancher", Repository: "does-not-exist", client: newReplayClient(t, "dockerhub-missing")}
+		_, err := dockerHub.getArtifactTags(t.Context())
+		assert.ErrorContains(t, err, "failed with status 404 Not Found")
+	})
+
+	t.Run("should log in with credentials to list private repositories", func(t *testing.T) {
+		mux := http.NewServeMux()
+		mux.HandleFunc("POST /v2/users/login", func(w http.ResponseWriter, r *http.Request) {
+			var login map[string]string
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&login))
+			assert.Equal(t, map[string]string{"username": "test-user", "password": "test-pass"}, login)
+			assert.NoError(t, json.NewEncoder(w).Encode(map[string]string{"token": "hub-token"}))
+		})
+		mux.HandleFunc("GET /v2/namespaces/test-org/repositories/private-image/tags", func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "Bearer hub-token" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			assert.NoError(t, json.NewEncoder(w).Encode(map[string]any{"results": []map[string]string{{"name": "v1.0.0"}}}))
+		})
+		server := httptest.NewServer(mux)
+		t.Cleanup(server.Close)
+
+		dockerHub := DockerHub{
+			Namespace:  "test-org",
+			Repository: "private-image",
+			baseURL:    server.URL,
+			credentials: &RegistryCredentials{
+				Repositories: []config.Repository{{Registry: "docker.io", Username: "test-user", Password: "test-pass"}},
+			},
+		}
+		tags, err := dockerHub.getArtifactTags(t.Context())
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"v1.0.0"}, tags)
+	})
+}
+
+func TestQuayIO(t *testing.T) {
+	t.Run("should list tags of all pages", func(t *testing.T) {
+		client := newReplayClient(t, "quay-tags")
+		log := logRequests(client)
+		quay := QuayIO{Namespace: "skopeo", Repository: "stable", client: client}
+		tags, err := quay.getArtifactTags(t.Context())
+		assert.NoError(t, err)
+		assert.NotEmpty(t, tags)
+		assert.True(t, slices.ContainsFunc(log.URLs(), hasQuery("page", "2")), "should follow pagination")
+	})
+
+	t.Run("should get tag metadata", func(t *testing.T) {
+		quay := QuayIO{Namespace: "skopeo", Repository: "stable", client: newReplayClient(t, "quay-tags")}
+		metadata, err := quay.getTagMetadata(t.Context(), []string{"v1.16.1", "does-not-exist"})
+		assert.NoError(t, err)
+		assertPushedTagMetadata(t, metadata, "v1.16.1")
+		assert.NotContains(t, metadata, "does-not-exist")
+	})
+
+	t.Run("should return error for missing repository", func(t *testing.T) {
+		quay := QuayIO{Namespace: "skopeo", Repository: "does-not-exist", client: newReplayClient(t, "quay-missing")}
+		_, err := quay.getArtifactTags(t.Context())
+		assert.ErrorContains(t, err, "failed with status 404 Not Found")
+	})
+
+	t.Run("should list private repositories through the registry API with credentials", func(t *testing.T) {
+		server, credentials := newPrivateRegistry(t)
+		quay := QuayIO{Namespace: "test-org", Repository: "private-image", baseURL: server.URL, credentials: credentials}
+
+		_, err := quay.listTagMetadata(t.Context())
+		assert.ErrorIs(t, err, errTagMetadataUnavailable)
+		tags, err := quay.getArtifactTags(t.Context())
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"v1.0.0"}, tags)
+
+		quay.credentials = nil
+		_, err = quay.getArtifactTags(t.Context())
+		assert.ErrorContains(t, err, "failed with status 401 Unauthorized")
+	})
+}
+
+func TestGitHubRegistry(t *testing.T) {
+	t.Setenv("GITHUB_TOKEN", "test-token")
+
+	t.Run("should list tags of all pages", func(t *testing.T) {
+		client := newReplayClient(t, "ghcr-tags")
+		log := logRequests(client)
+		githubRegistry := GitHubRegistry{Namespace: "rancher", Repository: "fleet", client: client}
+		tags, err := githubRegistry.getArtifactTags(t.Context())
+		assert.NoError(t, err)
+		assert.NotEmpty(t, tags)
+		assert.True(t, slices.ContainsFunc(log.URLs(), hasQuery("last", "")), "should follow pagination")
+	})
+
+	t.Run("should return error if unauthorized", func(t *testing.T) {
+		githubRegistry := GitHubRegistry{Namespace: "rancher", Repository: "private", client: newReplayClient(t, "ghcr-unauthorized")}
+		_, err := githubRegistry.getArtifactTags(t.Context())
+		assert.ErrorContains(t, err, "failed with status 401 Unauthorized")
+	})
+
+	t.Run("should list tags with credentials instead of GITHUB_TOKEN", func(t *testing.T) {
+		server, credentials := newPrivateRegistry(t)
+		githubRegistry := GitHubRegistry{Namespace: "test-org", Repository: "private-image", baseURL: server.URL, credentials: credentials}
+		tags, err := githubRegistry.getArtifactTags(t.Context())
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"v1.0.0"}, tags)
+	})
+}
+
+// newPrivateRegistry returns a registry that serves the tags of
+// test-org/private-image only to test-user with password test-pass, and the
+// credentials for it. Like quay.io, it also denies anonymous access to the
+// repository through the Quay API.
+func newPrivateRegistry(t *testing.T) (*httptest.Server, *RegistryCredentials) {
+	t.Helper()
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	mux.HandleFunc("GET /token", func(w http.ResponseWriter, r *http.Request) {
+		if username, password, ok := r.BasicAuth(); !ok || username != "test-user" || password != "test-pass" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		assert.NoError(t, json.NewEncoder(w).Encode(map[string]string{"token": "private-token"}))
+	})
+	mux.HandleFunc("GET /v2/test-org/private-image/tags/list", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer private-token" {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="test",scope="repository:test-org/private-image:pull"`, server.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		assert.NoError(t, json.NewEncoder(w).Encode(map[string]any{"name": "test-org/private-image", "tags": []string{"v1.0.0"}}))
+	})
+	mux.HandleFunc("GET /api/v1/repository/test-org/private-image/tag/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+	credentials := &RegistryCredentials{
+		Repositories: []config.Repository{{Registry: strings.TrimPrefix(server.URL, "http://"), Username: "test-user", Password: "test-pass"}},
+	}
+	return server, credentials
+}
+
+func TestOCIRegistryReplay(t *testing.T) {
+	t.Run("should exchange a token and list tags of all pages", func(t *testing.T) {
+		client := newReplayClient(t, "oci-tags")
+		log := logRequests(client)
+		registry := OCIRegistry{Registry: "registry.suse.com", Repository: "bci/bci-base", client: client}
+		tags, err := registry.getArtifactTags(t.Context())
+		assert.NoError(t, err)
+		assert.NotEmpty(t, tags)
+
+		urls := log.URLs()
+		isTagList := func(rawURL string) bool { return strings.Contains(rawURL, "/v2/bci/bci-base/tags/list") }
+		tokenIndex := slices.IndexFunc(urls, func(rawURL string) bool { return !strings.Contains(rawURL, "/v2/") })
+		if assert.Positive(t, tokenIndex, "should fetch a token after the tag list is challenged") {
+			assert.True(t, isTagList(urls[0]))
+			assert.True(t, slices.ContainsFunc(urls[tokenIndex+1:], isTagList), "should retry the tag list with the token")
+		}
+		assert.True(t, slices.ContainsFunc(urls, hasQuery("last", "")), "should follow pagination")
+	})
+
+	t.Run("should return error for missing repository", func(t *testing.T) {
+		registry := OCIRegistry{Registry: "registry.k8s.io", Repository: "does-not-exist", client: newReplayClient(t, "oci-missing")}
+		_, err := registry.getArtifactTags(t.Context())
+		assert.ErrorContains(t, err, "failed to list tags")
+	})
+}
+
+// hasQuery returns a function that reports whether a URL has the query
+// parameter key, with the given value unless value is empty.
+func hasQuery(key, value string) func(string) bool {
+	return func(rawURL string) bool {
+		parsedURL, err := url.Parse(rawURL)
+		if err != nil || !parsedURL.Query().Has(key) {
+			return false
+		}
+		return value == "" || parsedURL.Query().Get(key) == value
+	}
+}
+
+// assertPushedTagMetadata asserts that metadata has an entry for tag that
+// has a digest and equal creation and push times, as reported by registries
+// that return push times.
+func assertPushedTagMetadata(t *testing.T, metadata map[string]TagMetadata, tag string) {
+	t.Helper()
+	tagMetadata, ok := metadata[tag]
+	if !assert.True(t, ok, "metadata should contain %s", tag) {
+		return
+	}
+	assert.Equal(t, tag, tagMetadata.Name)
+	assert.True(t, strings.HasPrefix(tagMetadata.Digest, "sha256:"), "digest %q should be a sha256 digest", tagMetadata.Digest)
+	assert.False(t, tagMetadata.Created.IsZero())
+	assert.Equal(t, tagMetadata.Created, tagMetadata.Pushed)
+}
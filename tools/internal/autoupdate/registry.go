@@ -1,42 +1,226 @@
 package autoupdate
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
-	"math"
 	"net/http"
 	"regexp"
-	"sort"
+	"slices"
 	"strings"
 	"time"
 
-	"github.com/Masterminds/semver/v3"
 	"github.com/rancher/artifact-mirror/internal/config"
+	"github.com/rancher/artifact-mirror/internal/reference"
+
+	"github.com/google/go-github/v80/github"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// TagDiscovery determines how the Registry strategy finds the tags of
+// its artifacts.
+type TagDiscovery string
+
+const (
+	// TagDiscoveryFirstArtifact uses the tags of the first artifact for
+	// all artifacts.
+	TagDiscoveryFirstArtifact TagDiscovery = "FirstArtifact"
+	// TagDiscoveryIntersection uses only the tags that are present for
+	// every artifact.
+	TagDiscoveryIntersection TagDiscovery = "Intersection"
+	// TagDiscoveryPerArtifact gives each artifact its own tags.
+	TagDiscoveryPerArtifact TagDiscovery = "PerArtifact"
 )
 
 type Registry struct {
-	Artifacts     []AutoupdateArtifactRef
-	Latest        bool   `json:",omitempty"`
-	VersionFilter string `json:",omitempty"`
+	Artifacts []AutoupdateArtifactRef
+	Latest    bool `json:",omitempty"`
+	// Ordering determines how tags are compared to find the latest tag
+	// when Latest is true. Defaults to Semver.
+	Ordering TagOrdering `json:",omitempty"`
+	// TagDiscovery determines how tags are found for each artifact.
+	// Defaults to Intersection, so that tags that are missing for some of
+	// the artifacts are never proposed.
+	TagDiscovery  TagDiscovery `json:",omitempty"`
+	VersionFilter string       `json:",omitempty"`
+	// registryForArtifact returns the ArtifactRegistry of a source artifact.
+	// If nil, getRegistryInformationFromArtifact is used.
+	registryForArtifact func(string) (ArtifactRegistry, error) `json:"-"`
+	// credentials are used to authenticate to registries.
+	credentials *RegistryCredentials `json:"-"`
 }
 
-var httpClient = &http.Client{Timeout: 10 * time.Second}
+// httpClient is used by doRequestWithRetries and the GitHub clients of the
+// update strategies. UseHTTPCache adds a cache in front of its transport.
+var httpClient = &http.Client{Transport: newRetryTransport()}
+
+// registryHTTPClient is used by oras to access registries.
+var registryHTTPClient = &http.Client{Transport: newRetryTransport()}
+
+// newGithubClient returns an unauthenticated GitHub client that uses
+// httpClient.
+func newGithubClient() *github.Client {
+	return github.NewClient(httpClient)
+}
 
 type ArtifactRegistry interface {
-	getArtifactTags() ([]string, error)
+	getArtifactTags(ctx context.Context) ([]string, error)
+	// getTagMetadata returns the metadata of each of tags that the registry
+	// has. Fields that the registry does not report are left empty.
+	getTagMetadata(ctx context.Context, tags []string) (map[string]TagMetadata, error)
 }
 
-func (r *Registry) GetUpdateArtifacts() ([]*config.Artifact, error) {
-	allTags, err := r.getArtifactTags()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get artifact tags: %w", err)
+// tagMetadataLister is implemented by registries whose tag list includes
+// the metadata of each tag, like Docker Hub and Quay. The Registry strategy
+// uses it to get the names and times of tags from a single pass through
+// the tag list.
+type tagMetadataLister interface {
+	// listTagMetadata returns the metadata of every tag. It returns
+	// errTagMetadataUnavailable if the tags can only be listed by
+	// getArtifactTags, without metadata.
+	listTagMetadata(ctx context.Context) ([]TagMetadata, error)
+}
+
+// errTagMetadataUnavailable is returned by tagMetadataLister when it cannot
+// list tag metadata for an artifact.
+var errTagMetadataUnavailable = errors.New("tag metadata cannot be listed")
+
+// TagMetadata describes a tag of an artifact.
+type TagMetadata struct {
+	Name string
+	// Digest is the digest of the manifest or index that the tag points
+	// to.
+	Digest string
+	// Created is when the tag was created or last pushed. For registries
+	// that do not report push times, it is the build time recorded in the
+	// artifact, which is set by whoever builds it.
+	Created time.Time
+	// Pushed is when the tag was last pushed, as reported by the registry.
+	// It is only known for registries that report it, and unlike Created
+	// it cannot be set by the publisher.
+	Pushed time.Time
+	// MultiArch is whether the tag points to an index of manifests for
+	// several platforms, rather than to a single manifest.
+	MultiArch bool
+}
+
+// dockerManifestListMediaType is the docker equivalent of an OCI image
+// index.
+const dockerManifestListMediaType = "application/vnd.docker.distribution.manifest.list.v2+json"
+
+// isIndexMediaType returns whether mediaType is the media type of an index
+// of manifests.
+func isIndexMediaType(mediaType string) bool {
+	return mediaType == ocispec.MediaTypeImageIndex || mediaType == dockerManifestListMediaType
+}
+
+func (r *Registry) GetUpdateArtifacts(ctx context.Context) (UpdateArtifacts, error) {
+	tagsPerArtifact := make([][]string, len(r.Artifacts))
+	// metadataPerArtifact holds the tag metadata that was listed along
+	// with the tags of each artifact, if its registry lists any.
+	metadataPerArtifact := make([]map[string]TagMetadata, len(r.Artifacts))
+	// creationTimesPerArtifact holds the creation times that were used to
+	// order the tags of each artifact, if any.
+	creationTimesPerArtifact := make([]map[string]time.Time, len(r.Artifacts))
+	switch r.TagDiscovery {
+	case TagDiscoveryFirstArtifact:
+		sourceArtifact := r.Artifacts[0].SourceArtifact
+		allTags, metadata, err := r.getArtifactTags(ctx, sourceArtifact)
+		if err != nil {
+			return UpdateArtifacts{}, fmt.Errorf("failed to get artifact tags: %w", err)
+		}
+		tags, tagCreationTimes, err := r.selectTags(ctx, sourceArtifact, allTags, metadata)
+		if err != nil {
+			return UpdateArtifacts{}, err
+		}
+		// The other artifacts are not queried, so the metadata of the
+		// first artifact stands in for theirs.
+		for i := range r.Artifacts {
+			tagsPerArtifact[i] = tags
+			metadataPerArtifact[i] = metadata
+			creationTimesPerArtifact[i] = tagCreationTimes
+		}
+	case "", TagDiscoveryIntersection:
+		var commonTags []string
+		for i, artifactRef := range r.Artifacts {
+			allTags, metadata, err := r.getArtifactTags(ctx, artifactRef.SourceArtifact)
+			if err != nil {
+				return UpdateArtifacts{}, fmt.Errorf("failed to get artifact tags of %s: %w", artifactRef.SourceArtifact, err)
+			}
+			metadataPerArtifact[i] = metadata
+			if i == 0 {
+				commonTags = slices.Clone(allTags)
+				continue
+			}
+			artifactTags := make(map[string]struct{}, len(allTags))
+			for _, tag := range allTags {
+				artifactTags[tag] = struct{}{}
+			}
+			commonTags = slices.DeleteFunc(commonTags, func(tag string) bool {
+				_, ok := artifactTags[tag]
+				return !ok
+			})
+		}
+		if len(commonTags) == 0 {
+			return UpdateArtifacts{}, errors.New("no tags found that are present for all artifacts")
+		}
+		tags, tagCreationTimes, err := r.selectTags(ctx, r.Artifacts[0].SourceArtifact, commonTags, metadataPerArtifact[0])
+		if err != nil {
+			return UpdateArtifacts{}, err
+		}
+		for i := range r.Artifacts {
+			tagsPerArtifact[i] = tags
+			creationTimesPerArtifact[i] = tagCreationTimes
+		}
+	case TagDiscoveryPerArtifact:
+		for i, artifactRef := range r.Artifacts {
+			allTags, metadata, err := r.getArtifactTags(ctx, artifactRef.SourceArtifact)
+			if err != nil {
+				return UpdateArtifacts{}, fmt.Errorf("failed to get artifact tags of %s: %w", artifactRef.SourceArtifact, err)
+			}
+			metadataPerArtifact[i] = metadata
+			tags, tagCreationTimes, err := r.selectTags(ctx, artifactRef.SourceArtifact, allTags, metadata)
+			if err != nil {
+				return UpdateArtifacts{}, fmt.Errorf("failed to select tags of %s: %w", artifactRef.SourceArtifact, err)
+			}
+			tagsPerArtifact[i] = tags
+			creationTimesPerArtifact[i] = tagCreationTimes
+		}
+	default:
+		return UpdateArtifacts{}, fmt.Errorf("unknown tag discovery %q", r.TagDiscovery)
 	}
 
-	if len(allTags) == 0 {
-		return nil, fmt.Errorf("no artifact tags found")
+	updateArtifacts := UpdateArtifacts{
+		Artifacts:     make([]*config.Artifact, 0, len(r.Artifacts)),
+		PublishTimes:  map[string]time.Time{},
+		CreationTimes: map[string]time.Time{},
+	}
+	for i, sourceArtifact := range r.Artifacts {
+		artifact, err := config.NewArtifact(sourceArtifact.SourceArtifact, tagsPerArtifact[i], sourceArtifact.TargetArtifactName, nil, nil)
+		if err != nil {
+			return UpdateArtifacts{}, err
+		}
+		artifact.SetTargetArtifactName(sourceArtifact.TargetArtifactName)
+		updateArtifacts.Artifacts = append(updateArtifacts.Artifacts, artifact)
+		// Only Docker Hub and Quay report push times. The creation times
+		// that other registries have are build times, which the publisher
+		// controls, so MinAge is not supported for them.
+		for _, tag := range tagsPerArtifact[i] {
+			addPublishTime(updateArtifacts.PublishTimes, sourceArtifact.SourceArtifact, tag, metadataPerArtifact[i][tag].Pushed)
+			if creationTime, ok := creationTimesPerArtifact[i][tag]; ok {
+				updateArtifacts.CreationTimes[sourceArtifact.SourceArtifact+":"+tag] = creationTime
+			}
+		}
 	}
+	return updateArtifacts, nil
+}
 
+// selectTags applies VersionFilter and Latest to allTags, which are the
+// tags of sourceArtifact. metadata is the tag metadata that was listed
+// along with allTags, if any. If the tags were ordered by creation time,
+// the creation times are returned too.
+func (r *Registry) selectTags(ctx context.Context, sourceArtifact string, allTags []string, metadata map[string]TagMetadata) ([]string, map[string]time.Time, error) {
 	var filteredTags []string
 	if r.VersionFilter != "" {
 		versionFilter := regexp.MustCompile(r.VersionFilter)
@@ -49,36 +233,40 @@ func (r *Registry) GetUpdateArtifacts() ([]*config.Artifact, error) {
 		}
 
 		if len(filteredTags) == 0 {
-			return nil, errors.New("no tags found matching version filter")
+			return nil, nil, errors.New("no tags found matching version filter")
 		}
 
 	} else {
 		filteredTags = allTags
 	}
 
+	var creationTimes map[string]time.Time
 	if r.Latest {
-		vs := make([]*semver.Version, len(filteredTags))
-		for i, r := range filteredTags {
-			v, err := semver.NewVersion(r)
+		if r.Ordering == TagOrderingCreationTime && metadata != nil {
+			creationTimes = make(map[string]time.Time, len(filteredTags))
+			for _, tag := range filteredTags {
+				if created := metadata[tag].Created; !created.IsZero() {
+					creationTimes[tag] = created
+				}
+			}
+		} else if r.Ordering == TagOrderingCreationTime {
+			var err error
+			creationTimes, err = r.getTagCreationTimes(ctx, sourceArtifact, filteredTags)
 			if err != nil {
-				return nil, fmt.Errorf("error parsing version: %s", err)
+				return nil, nil, fmt.Errorf("failed to get tag creation times: %w", err)
 			}
-			vs[i] = v
 		}
-		sort.Sort(semver.Collection(vs))
-		filteredTags = []string{vs[len(vs)-1].String()} // Use the latest version
-	}
-
-	artifacts := make([]*config.Artifact, 0, len(r.Artifacts))
-	for _, sourceArtifact := range r.Artifacts {
-		artifact, err := config.NewArtifact(sourceArtifact.SourceArtifact, filteredTags, sourceArtifact.TargetArtifactName, nil, nil)
+		sortedTags, err := r.Ordering.SortTags(filteredTags, creationTimes)
 		if err != nil {
-			return nil, err
+			return nil, nil, fmt.Errorf("failed to sort tags: %w", err)
 		}
-		artifact.SetTargetArtifactName(sourceArtifact.TargetArtifactName)
-		artifacts = append(artifacts, artifact)
+		if len(sortedTags) == 0 {
+			return nil, nil, fmt.Errorf("no tags found that can be ordered by %s", r.orderingName())
+		}
+		filteredTags = sortedTags[:1]
 	}
-	return artifacts, nil
+
+	return filteredTags, creationTimes, nil
 }
 
 func (r *Registry) Validate() error {
@@ -90,130 +278,200 @@ func (r *Registry) Validate() error {
 			return errors.New("invalid version filter regex: " + err.Error())
 		}
 	}
+	if err := r.Ordering.Validate(); err != nil {
+		return err
+	}
+	if r.Ordering != "" && !r.Latest {
+		return errors.New("must not specify Ordering when Latest=false")
+	}
+	switch r.TagDiscovery {
+	case "", TagDiscoveryFirstArtifact, TagDiscoveryIntersection, TagDiscoveryPerArtifact:
+	default:
+		return fmt.Errorf("unknown tag discovery %q", r.TagDiscovery)
+	}
 	return nil
 }
 
-func (r *Registry) getArtifactTags() ([]string, error) {
-	registry, err := r.getRegistryInformationFromArtifact()
+func (r *Registry) orderingName() TagOrdering {
+	if r.Ordering == "" {
+		return TagOrderingSemver
+	}
+	return r.Ordering
+}
+
+func (r *Registry) getRegistry(sourceArtifact string) (ArtifactRegistry, error) {
+	if r.registryForArtifact != nil {
+		return r.registryForArtifact(sourceArtifact)
+	}
+	return getRegistryInformationFromArtifact(sourceArtifact, r.credentials)
+}
+
+// getTagCreationTimes returns the creation time of each of tags, for those
+// tags where the registry of sourceArtifact reports it.
+func (r *Registry) getTagCreationTimes(ctx context.Context, sourceArtifact string, tags []string) (map[string]time.Time, error) {
+	registry, err := r.getRegistry(sourceArtifact)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get registry information from artifact: %w", err)
 	}
-	return registry.getArtifactTags()
-}
-
-func (r *Registry) getRegistryInformationFromArtifact() (ArtifactRegistry, error) {
-	artifact := r.Artifacts[0].SourceArtifact
-	splittedArtifact := strings.Split(artifact, "/")
-	if len(splittedArtifact) < 2 {
-		return nil, fmt.Errorf("invalid artifact format: %s", artifact)
-	}
-	var registry, namespace, repository string
-	// Case 1: Handle default Docker Hub artifacts like "flannel/flannel"
-	if len(splittedArtifact) == 2 && !strings.Contains(splittedArtifact[0], ".") {
-		registry = "dockerhub"
-		namespace = splittedArtifact[0]
-		repository = splittedArtifact[1]
-	} else if len(splittedArtifact) == 2 && strings.Contains(splittedArtifact[0], ".") {
-		// Case 2: Handle artifacts with a registry but no namespace like "k8s.gcr.io/pause"
-		registry = splittedArtifact[0]
-		namespace = ""
-		repository = splittedArtifact[1]
-	} else {
-		// Default Case: Handle standard 3-part artifacts like "quay.io/skopeo/stable"
-		// and handle artifacts with long paths like "gcr.io/cloud-provider-vsphere/csi/release/syncer"
-		registry = splittedArtifact[0]
-		namespace = splittedArtifact[1]
-		repository = strings.Join(splittedArtifact[2:], "/")
+	metadata, err := registry.getTagMetadata(ctx, tags)
+	if err != nil {
+		return nil, err
+	}
+	creationTimes := make(map[string]time.Time, len(metadata))
+	for tag, tagMetadata := range metadata {
+		if !tagMetadata.Created.IsZero() {
+			creationTimes[tag] = tagMetadata.Created
+		}
+	}
+	return creationTimes, nil
+}
+
+// getArtifactTags returns the tags of sourceArtifact. If its registry lists
+// tag metadata along with the tags, the metadata is returned too, so that
+// it does not have to be fetched again.
+func (r *Registry) getArtifactTags(ctx context.Context, sourceArtifact string) ([]string, map[string]TagMetadata, error) {
+	registry, err := r.getRegistry(sourceArtifact)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get registry information from artifact: %w", err)
+	}
+	var tags []string
+	var metadata map[string]TagMetadata
+	if lister, ok := registry.(tagMetadataLister); ok {
+		tagMetadata, err := lister.listTagMetadata(ctx)
+		if err != nil && !errors.Is(err, errTagMetadataUnavailable) {
+			return nil, nil, err
+		} else if err == nil {
+			metadata = make(map[string]TagMetadata, len(tagMetadata))
+			for _, tag := range tagMetadata {
+				tags = append(tags, tag.Name)
+				metadata[tag.Name] = tag
+			}
+		}
+	}
+	if metadata == nil {
+		tags, err = registry.getArtifactTags(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if len(tags) == 0 {
+		return nil, nil, errors.New("no artifact tags found")
+	}
+	return tags, metadata, nil
+}
+
+// getRegistryInformationFromArtifact returns the ArtifactRegistry that
+// lists the tags of artifact, using credentials to authenticate to it.
+func getRegistryInformationFromArtifact(artifact string, credentials *RegistryCredentials) (ArtifactRegistry, error) {
+	ref, err := reference.Parse(artifact)
+	if err != nil {
+		return nil, fmt.Errorf("invalid artifact format: %w", err)
+	}
+	registry := ref.Registry
+	// Artifacts with a registry but no namespace, like "k8s.gcr.io/pause",
+	// have an empty namespace. Artifacts with long paths, like
+	// "gcr.io/cloud-provider-vsphere/csi/release/syncer", have everything
+	// after the namespace in the repository.
+	namespace, repository, found := strings.Cut(ref.Repository, "/")
+	if !found {
+		namespace, repository = "", namespace
 	}
 	switch registry {
-	case "dockerhub":
+	case reference.DockerHubRegistry:
 		return &DockerHub{
-			Namespace:  namespace,
-			Repository: repository,
+			Namespace:   namespace,
+			Repository:  repository,
+			credentials: credentials,
 		}, nil
 	case "quay.io":
 		return &QuayIO{
-			Namespace:  namespace,
-			Repository: repository,
-		}, nil
-	case "registry.k8s.io":
-		return &K8sRegistry{
-			Namespace:  namespace,
-			Repository: repository,
-		}, nil
-	case "registry.suse.com":
-		return &SUSERegistry{
-			Namespace:  namespace,
-			Repository: repository,
+			Namespace:   namespace,
+			Repository:  repository,
+			credentials: credentials,
 		}, nil
 	case "ghcr.io":
 		return &GitHubRegistry{
-			Namespace:  namespace,
-			Repository: repository,
-		}, nil
-	case "gcr.io":
-		return &GoogleRegistry{
-			Namespace:  namespace,
-			Repository: repository,
+			Namespace:   namespace,
+			Repository:  repository,
+			credentials: credentials,
 		}, nil
 	default:
-		return nil, fmt.Errorf("unrecognized registry: %s", registry)
+		return &OCIRegistry{
+			Registry:    registry,
+			Repository:  ref.Repository,
+			credentials: credentials,
+		}, nil
 	}
 }
 
-func doRequestWithRetries(req *http.Request) (*http.Response, error) {
-	maxRetries := 10
-	backoffFactor := time.Second
-	retryableStatuses := map[int]bool{
-		http.StatusInternalServerError: true, // 500
-		http.StatusBadGateway:          true, // 502
-		http.StatusServiceUnavailable:  true, // 503
-		http.StatusGatewayTimeout:      true, // 504
+// registryReportsPushTimes returns whether the registry of artifact reports
+// when its tags were pushed. Only Docker Hub and Quay do; other registries
+// only have the creation times that publishers set when building.
+func registryReportsPushTimes(artifact string) (bool, error) {
+	ref, err := reference.Parse(artifact)
+	if err != nil {
+		return false, fmt.Errorf("invalid artifact format: %w", err)
 	}
+	return ref.Registry == reference.DockerHubRegistry || ref.Registry == "quay.io", nil
+}
 
-	var resp *http.Response
-	var err error
-	var tries int
-
-	for {
-		resp, err = httpClient.Do(req)
-		if err != nil {
-			return nil, fmt.Errorf("request failed: %w", err)
-		}
-
-		tries++
-		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-			return resp, nil
-		}
-
-		if !retryableStatuses[resp.StatusCode] {
-			b, readErr := io.ReadAll(resp.Body)
-			if readErr != nil {
-				resp.Body.Close()
-				return nil, fmt.Errorf("request to %s failed with status %s", req.URL.String(), resp.Status)
-			}
+// doRequestWithRetries makes req with httpClient, which retries server
+// errors and rate limited requests. It returns an error if the final
+// response is not successful.
+func doRequestWithRetries(req *http.Request) (*http.Response, error) {
+	return doRequestWithClient(httpClient, req)
+}
 
-			resp.Body.Close()
-			return nil, fmt.Errorf("request to %s failed with status %s and body %s", req.URL.String(), resp.Status, string(b))
-		}
+// doRequestWithClient is doRequestWithRetries with client instead of
+// httpClient. If client is nil, httpClient is used.
+func doRequestWithClient(client *http.Client, req *http.Request) (*http.Response, error) {
+	if client == nil {
+		client = httpClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
 
-		if resp.Body != nil {
-			_, _ = io.Copy(io.Discard, resp.Body)
-			err := resp.Body.Close()
-			if err != nil {
-				return nil, err
-			}
-		}
+	defer resp.Body.Close()
+	statusErr := &httpStatusError{URL: req.URL.String(), Status: resp.Status, StatusCode: resp.StatusCode}
+	if b, err := io.ReadAll(resp.Body); err == nil {
+		statusErr.Body = string(b)
+	}
+	return nil, statusErr
+}
 
-		if tries == maxRetries {
-			break
-		}
+// httpStatusError is returned by doRequestWithClient when the final
+// response is not successful.
+type httpStatusError struct {
+	URL        string
+	Status     string
+	StatusCode int
+	Body       string
+}
 
-		sleepDuration := backoffFactor * time.Duration(math.Pow(2, float64(tries)))
-		time.Sleep(sleepDuration)
+func (e *httpStatusError) Error() string {
+	if e.Body == "" {
+		return fmt.Sprintf("request to %s failed with status %s", e.URL, e.Status)
 	}
+	return fmt.Sprintf("request to %s failed with status %s and body %s", e.URL, e.Status, e.Body)
+}
+
+// isAccessDenied returns whether err is an httpStatusError for a response
+// that denied access to the requested resource.
+func isAccessDenied(err error) bool {
+	var statusErr *httpStatusError
+	return errors.As(err, &statusErr) && (statusErr.StatusCode == http.StatusUnauthorized || statusErr.StatusCode == http.StatusForbidden)
+}
 
-	return nil, fmt.Errorf("request failed after %d retries", maxRetries)
+// isNotFound returns whether err is an httpStatusError for a response that
+// did not find the requested resource.
+func isNotFound(err error) bool {
+	var statusErr *httpStatusError
+	return errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound
 }
 
 // parseLinkHeader extracts the next URL from the Link header for pagination.
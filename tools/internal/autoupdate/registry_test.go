@@ -1,8 +1,12 @@
 package autoupdate
 
 import (
-	"github.com/stretchr/testify/assert"
+	"context"
+	"slices"
 	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
 )
 
 func TestRegistry(t *testing.T) {
@@ -40,6 +44,40 @@ func TestRegistry(t *testing.T) {
 				},
 				ExpectedError: "invalid version filter regex: error parsing regexp: missing closing ]: `[`",
 			},
+			{
+				Message: "should return nil for a valid Ordering",
+				Registry: &Registry{
+					Artifacts: []AutoupdateArtifactRef{{SourceArtifact: "rancher/rancher"}},
+					Latest:    true,
+					Ordering:  TagOrderingCalendar,
+				},
+				ExpectedError: "",
+			},
+			{
+				Message: "should return error for an unknown Ordering",
+				Registry: &Registry{
+					Artifacts: []AutoupdateArtifactRef{{SourceArtifact: "rancher/rancher"}},
+					Latest:    true,
+					Ordering:  "asdf",
+				},
+				ExpectedError: `unknown tag ordering "asdf"`,
+			},
+			{
+				Message: "should return error if Ordering is specified without Latest",
+				Registry: &Registry{
+					Artifacts: []AutoupdateArtifactRef{{SourceArtifact: "rancher/rancher"}},
+					Ordering:  TagOrderingLexical,
+				},
+				ExpectedError: "must not specify Ordering when Latest=false",
+			},
+			{
+				Message: "should return error for an unknown TagDiscovery",
+				Registry: &Registry{
+					Artifacts:    []AutoupdateArtifactRef{{SourceArtifact: "rancher/rancher"}},
+					TagDiscovery: "asdf",
+				},
+				ExpectedError: `unknown tag discovery "asdf"`,
+			},
 		}
 		for _, testCase := range testCases {
 			t.Run(testCase.Message, func(t *testing.T) {
@@ -53,3 +91,244 @@ func TestRegistry(t *testing.T) {
 		}
 	})
 }
+
+// fakeArtifactRegistry is an ArtifactRegistry that returns fixed tags.
+type fakeArtifactRegistry []string
+
+func (f fakeArtifactRegistry) getArtifactTags(ctx context.Context) ([]string, error) {
+	return f, nil
+}
+
+func (f fakeArtifactRegistry) getTagMetadata(ctx context.Context, tags []string) (map[string]TagMetadata, error) {
+	metadata := make(map[string]TagMetadata, len(tags))
+	for _, tag := range tags {
+		if slices.Contains(f, tag) {
+			metadata[tag] = TagMetadata{Name: tag}
+		}
+	}
+	return metadata, nil
+}
+
+// fakeListingRegistry is an ArtifactRegistry that lists fixed tag metadata
+// along with its tags, like Docker Hub and Quay. It counts how many times
+// it is queried in queries. If unlisted is true, listTagMetadata returns
+// errTagMetadataUnavailable, as Quay does for private repositories.
+type fakeListingRegistry struct {
+	metadata []TagMetadata
+	queries  *int
+	unlisted bool
+}
+
+func (f fakeListingRegistry) getArtifactTags(ctx context.Context) ([]string, error) {
+	*f.queries++
+	tags := make([]string, 0, len(f.metadata))
+	for _, tag := range f.metadata {
+		tags = append(tags, tag.Name)
+	}
+	return tags, nil
+}
+
+func (f fakeListingRegistry) getTagMetadata(ctx context.Context, tags []string) (map[string]TagMetadata, error) {
+	*f.queries++
+	metadata := make(map[string]TagMetadata, len(tags))
+	for _, tag := range f.metadata {
+		if slices.Contains(tags, tag.Name) {
+			metadata[tag.Name] = tag
+		}
+	}
+	return metadata, nil
+}
+
+func (f fakeListingRegistry) listTagMetadata(ctx context.Context) ([]TagMetadata, error) {
+	*f.queries++
+	if f.unlisted {
+		return nil, errTagMetadataUnavailable
+	}
+	return f.metadata, nil
+}
+
+func TestRegistryListedTagMetadata(t *testing.T) {
+	january := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	march := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	newRegistry := func(queries *int) *Registry {
+		return &Registry{
+			Artifacts: []AutoupdateArtifactRef{{SourceArtifact: "test-org/artifact"}},
+			registryForArtifact: func(sourceArtifact string) (ArtifactRegistry, error) {
+				return fakeListingRegistry{
+					metadata: []TagMetadata{
+						{Name: "pushed", Created: january, Pushed: january},
+						{Name: "created", Created: march},
+					},
+					queries: queries,
+				}, nil
+			},
+		}
+	}
+
+	t.Run("should only use push times as publish times", func(t *testing.T) {
+		queries := 0
+		registry := newRegistry(&queries)
+		assert.NoError(t, registry.Validate())
+
+		updateArtifacts, err := registry.GetUpdateArtifacts(t.Context())
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"pushed", "created"}, updateArtifacts.Artifacts[0].Tags)
+		// The creation time is set by the publisher, so it is not used.
+		assert.Equal(t, map[string]time.Time{"test-org/artifact:pushed": january}, updateArtifacts.PublishTimes)
+	})
+
+	t.Run("should list tags without metadata if the registry cannot list it", func(t *testing.T) {
+		queries := 0
+		registry := newRegistry(&queries)
+		registryForArtifact := registry.registryForArtifact
+		registry.registryForArtifact = func(sourceArtifact string) (ArtifactRegistry, error) {
+			artifactRegistry, err := registryForArtifact(sourceArtifact)
+			listingRegistry := artifactRegistry.(fakeListingRegistry)
+			listingRegistry.unlisted = true
+			return listingRegistry, err
+		}
+		assert.NoError(t, registry.Validate())
+
+		updateArtifacts, err := registry.GetUpdateArtifacts(t.Context())
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"pushed", "created"}, updateArtifacts.Artifacts[0].Tags)
+		assert.Empty(t, updateArtifacts.PublishTimes)
+		assert.Equal(t, 2, queries)
+	})
+
+	t.Run("should list tags once when ordering by creation time", func(t *testing.T) {
+		queries := 0
+		registry := newRegistry(&queries)
+		registry.Latest = true
+		registry.Ordering = TagOrderingCreationTime
+		assert.NoError(t, registry.Validate())
+
+		updateArtifacts, err := registry.GetUpdateArtifacts(t.Context())
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"created"}, updateArtifacts.Artifacts[0].Tags)
+		assert.Equal(t, map[string]time.Time{"test-org/artifact:created": march}, updateArtifacts.CreationTimes)
+		assert.Equal(t, 1, queries)
+	})
+}
+
+func TestRegistryGetUpdateArtifacts(t *testing.T) {
+	tagsPerArtifact := map[string][]string{
+		"test-org/artifact1": {"v1.0.0", "v1.1.0", "v1.2.0"},
+		"test-org/artifact2": {"v1.0.0", "v1.1.0"},
+	}
+	newRegistry := func(tagDiscovery TagDiscovery) *Registry {
+		return &Registry{
+			Artifacts: []AutoupdateArtifactRef{
+				{SourceArtifact: "test-org/artifact1"},
+				{SourceArtifact: "test-org/artifact2"},
+			},
+			TagDiscovery: tagDiscovery,
+			registryForArtifact: func(sourceArtifact string) (ArtifactRegistry, error) {
+				return fakeArtifactRegistry(tagsPerArtifact[sourceArtifact]), nil
+			},
+		}
+	}
+
+	type testCase struct {
+		Message      string
+		TagDiscovery TagDiscovery
+		Latest       bool
+		ExpectedTags [][]string
+	}
+	testCases := []testCase{
+		{
+			Message:      "should use only tags present for all artifacts by default",
+			TagDiscovery: "",
+			ExpectedTags: [][]string{{"v1.0.0", "v1.1.0"}, {"v1.0.0", "v1.1.0"}},
+		},
+		{
+			Message:      "should use tags of first artifact for all artifacts with FirstArtifact",
+			TagDiscovery: TagDiscoveryFirstArtifact,
+			ExpectedTags: [][]string{{"v1.0.0", "v1.1.0", "v1.2.0"}, {"v1.0.0", "v1.1.0", "v1.2.0"}},
+		},
+		{
+			Message:      "should use only tags present for all artifacts with Intersection",
+			TagDiscovery: TagDiscoveryIntersection,
+			ExpectedTags: [][]string{{"v1.0.0", "v1.1.0"}, {"v1.0.0", "v1.1.0"}},
+		},
+		{
+			Message:      "should use latest tag present for all artifacts with Intersection and Latest",
+			TagDiscovery: TagDiscoveryIntersection,
+			Latest:       true,
+			ExpectedTags: [][]string{{"v1.1.0"}, {"v1.1.0"}},
+		},
+		{
+			Message:      "should use the tags of each artifact with PerArtifact",
+			TagDiscovery: TagDiscoveryPerArtifact,
+			ExpectedTags: [][]string{{"v1.0.0", "v1.1.0", "v1.2.0"}, {"v1.0.0", "v1.1.0"}},
+		},
+		{
+			Message:      "should use the latest tag of each artifact with PerArtifact and Latest",
+			TagDiscovery: TagDiscoveryPerArtifact,
+			Latest:       true,
+			ExpectedTags: [][]string{{"v1.2.0"}, {"v1.1.0"}},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.Message, func(t *testing.T) {
+			registry := newRegistry(testCase.TagDiscovery)
+			registry.Latest = testCase.Latest
+			assert.NoError(t, registry.Validate())
+
+			updateArtifacts, err := registry.GetUpdateArtifacts(t.Context())
+			assert.NoError(t, err)
+			artifacts := updateArtifacts.Artifacts
+			assert.Len(t, artifacts, len(testCase.ExpectedTags))
+			for i, artifact := range artifacts {
+				assert.Equal(t, testCase.ExpectedTags[i], artifact.Tags)
+			}
+		})
+	}
+
+	t.Run("should return error if no tags are present for all artifacts", func(t *testing.T) {
+		registry := newRegistry(TagDiscoveryIntersection)
+		registry.VersionFilter = "^v1\\.2\\."
+		tagsPerArtifact["test-org/artifact2"] = []string{"v2.0.0"}
+		defer func() { tagsPerArtifact["test-org/artifact2"] = []string{"v1.0.0", "v1.1.0"} }()
+
+		_, err := registry.GetUpdateArtifacts(t.Context())
+		assert.EqualError(t, err, "no tags found that are present for all artifacts")
+	})
+}
+
+func TestGetRegistryInformationFromArtifact(t *testing.T) {
+	type testCase struct {
+		Message          string
+		Artifact         string
+		ExpectedRegistry ArtifactRegistry
+	}
+	testCases := []testCase{
+		{
+			Message:          "should use Docker Hub client for Docker Hub artifacts",
+			Artifact:         "rancher/rancher",
+			ExpectedRegistry: &DockerHub{Namespace: "rancher", Repository: "rancher"},
+		},
+		{
+			Message:          "should use Quay client for quay.io artifacts",
+			Artifact:         "quay.io/skopeo/stable",
+			ExpectedRegistry: &QuayIO{Namespace: "skopeo", Repository: "stable"},
+		},
+		{
+			Message:          "should use OCI client for other registries",
+			Artifact:         "mcr.microsoft.com/oss/kubernetes/pause",
+			ExpectedRegistry: &OCIRegistry{Registry: "mcr.microsoft.com", Repository: "oss/kubernetes/pause"},
+		},
+		{
+			Message:          "should use OCI client for artifacts without namespace",
+			Artifact:         "registry.k8s.io/pause",
+			ExpectedRegistry: &OCIRegistry{Registry: "registry.k8s.io", Repository: "pause"},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.Message, func(t *testing.T) {
+			registry, err := getRegistryInformationFromArtifact(testCase.Artifact, nil)
+			assert.NoError(t, err)
+			assert.Equal(t, testCase.ExpectedRegistry, registry)
+		})
+	}
+}
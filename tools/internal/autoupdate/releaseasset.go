@@ -0,0 +1,258 @@
+package autoupdate
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/google/go-github/v80/github"
+	"github.com/rancher/artifact-mirror/internal/config"
+	"github.com/rancher/artifact-mirror/internal/reference"
+)
+
+// ReleaseAsset retrieves lists of images, such as the images.txt that many
+// projects publish with their releases, for the github releases selected in
+// the same way as GithubRelease. The images are mapped to config.yaml through
+// Artifacts. Images that are not in Artifacts or ImageDenylist are reported
+// as unmapped.
+type ReleaseAsset struct {
+	Owner              string
+	Repository         string
+	IncludePrereleases bool   `json:",omitempty"`
+	LatestOnly         bool   `json:",omitempty"`
+	VersionConstraint  string `json:",omitempty"`
+	VersionRegex       string `json:",omitempty"`
+	// AssetName is the name of the release asset that contains the image
+	// list.
+	AssetName string `json:",omitempty"`
+	// URLTemplate is a go template for the URL of the image list, used
+	// instead of AssetName. It may refer to .Owner, .Repository, .Tag and
+	// .Version.
+	URLTemplate         string             `json:",omitempty"`
+	compiledURLTemplate *template.Template `json:"-"`
+	Artifacts           []AutoupdateArtifactRef
+	// ImageDenylist is a list of images that are neither proposed nor
+	// reported as unmapped.
+	ImageDenylist []string `json:",omitempty"`
+	// githubClient is used instead of the default client if set.
+	githubClient *github.Client `json:"-"`
+	// versions is the Versions of the entry. Releases whose versions it
+	// drops are not downloaded.
+	versions *VersionPolicy `json:"-"`
+}
+
+// errNoAsset is returned for releases that do not have the asset named
+// AssetName, or whose image list is not found at URLTemplate.
+var errNoAsset = errors.New("release has no asset")
+
+// releaseTemplateData is passed to the URLTemplate of ReleaseAsset and
+// Manifest.
+type releaseTemplateData struct {
+	Owner      string
+	Repository string
+	Tag        string
+	Version    string
+}
+
+func (ra *ReleaseAsset) GetUpdateArtifacts(ctx context.Context) (UpdateArtifacts, error) {
+	githubRelease, err := ra.newGithubRelease()
+	if err != nil {
+		return UpdateArtifacts{}, err
+	}
+	releases, err := githubRelease.getReleases(ctx)
+	if err != nil {
+		return UpdateArtifacts{}, err
+	}
+	releases = selectReleases(releases, ra.versions)
+
+	imageMap := map[string][]string{}
+	updateArtifacts := UpdateArtifacts{
+		PublishTimes:   map[string]time.Time{},
+		UnmappedImages: []string{},
+	}
+	for _, release := range releases {
+		images, err := ra.getReleaseImages(ctx, release)
+		if errors.Is(err, errNoAsset) {
+			// Assets are often uploaded some time after the release is
+			// published, so the release is tried again in a later run.
+			updateArtifacts.SkippedReleases = append(updateArtifacts.SkippedReleases, SkippedRelease{
+				Tag:    release.release.GetTagName(),
+				Reason: err.Error(),
+			})
+			continue
+		} else if err != nil {
+			return UpdateArtifacts{}, err
+		}
+		for _, image := range images {
+			if err := ra.mapImage(image, release.release.GetPublishedAt().Time, imageMap, &updateArtifacts); err != nil {
+				return UpdateArtifacts{}, err
+			}
+		}
+	}
+
+	updateArtifacts.Artifacts = make([]*config.Artifact, 0, len(imageMap))
+	for _, artifactRef := range ra.Artifacts {
+		tags, ok := imageMap[artifactRef.SourceArtifact]
+		if !ok {
+			continue
+		}
+		artifact, err := config.NewArtifact(artifactRef.SourceArtifact, tags, artifactRef.TargetArtifactName, nil, nil)
+		if err != nil {
+			return UpdateArtifacts{}, fmt.Errorf("failed to construct artifact from source artifact %q and tags %v: %w", artifactRef.SourceArtifact, tags, err)
+		}
+		updateArtifacts.Artifacts = append(updateArtifacts.Artifacts, artifact)
+	}
+	return updateArtifacts, nil
+}
+
+// newGithubRelease returns the GithubRelease that selects the releases of
+// ra.
+func (ra *ReleaseAsset) newGithubRelease() (*GithubRelease, error) {
+	githubRelease := &GithubRelease{
+		Owner:              ra.Owner,
+		Repository:         ra.Repository,
+		Artifacts:          ra.Artifacts,
+		IncludePrereleases: ra.IncludePrereleases,
+		LatestOnly:         ra.LatestOnly,
+		VersionConstraint:  ra.VersionConstraint,
+		VersionRegex:       ra.VersionRegex,
+		githubClient:       ra.githubClient,
+	}
+	if err := githubRelease.Validate(); err != nil {
+		return nil, err
+	}
+	return githubRelease, nil
+}
+
+// getReleaseImages returns the images in the image list of release. It
+// returns an error wrapping errNoAsset if release has no image list.
+func (ra *ReleaseAsset) getReleaseImages(ctx context.Context, release versionedRelease) ([]string, error) {
+	assetURL, err := ra.getAssetURL(release)
+	if errors.Is(err, errNoAsset) {
+		return nil, err
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get image list URL for release %s: %w", release.release.GetTagName(), err)
+	}
+	images, err := ra.getImages(ctx, assetURL)
+	if isNotFound(err) {
+		return nil, fmt.Errorf("%w at %s", errNoAsset, assetURL)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get image list for release %s: %w", release.release.GetTagName(), err)
+	}
+	return images, nil
+}
+
+func (ra *ReleaseAsset) getAssetURL(release versionedRelease) (string, error) {
+	if ra.compiledURLTemplate != nil {
+		builder := &strings.Builder{}
+		data := releaseTemplateData{
+			Owner:      ra.Owner,
+			Repository: ra.Repository,
+			Tag:        release.release.GetTagName(),
+			Version:    release.version,
+		}
+		if err := ra.compiledURLTemplate.Execute(builder, data); err != nil {
+			return "", fmt.Errorf("failed to execute URLTemplate: %w", err)
+		}
+		return builder.String(), nil
+	}
+	for _, asset := range release.release.Assets {
+		if asset.GetName() == ra.AssetName {
+			return asset.GetBrowserDownloadURL(), nil
+		}
+	}
+	return "", fmt.Errorf("%w named %q", errNoAsset, ra.AssetName)
+}
+
+// getImages downloads the image list at assetURL and returns the images in
+// it. Empty lines and lines starting with "#" are ignored, as is anything
+// after the first whitespace on a line.
+func (ra *ReleaseAsset) getImages(ctx context.Context, assetURL string) ([]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, assetURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := doRequestWithRetries(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download image list: %w", err)
+	}
+	defer resp.Body.Close()
+
+	images := make([]string, 0)
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		images = append(images, fields[0])
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read image list: %w", err)
+	}
+	return images, nil
+}
+
+// mapImage adds the tag of image to imageMap under the SourceArtifact of
+// the matching element of Artifacts, and records its publishTime, which is
+// the publish time of the release that contains image, in
+// updateArtifacts. Images that are not in Artifacts are recorded as
+// unmapped instead.
+func (ra *ReleaseAsset) mapImage(image string, publishTime time.Time, imageMap map[string][]string, updateArtifacts *UpdateArtifacts) error {
+	ref, err := reference.Parse(image)
+	if err != nil {
+		return fmt.Errorf("failed to parse image %q: %w", image, err)
+	}
+	if ref.Tag == "" {
+		return fmt.Errorf("image %q has no tag", image)
+	}
+	for _, deniedImage := range ra.ImageDenylist {
+		if same, err := reference.SameRepository(deniedImage, ref.Name()); err == nil && same {
+			return nil
+		}
+	}
+	for _, artifactRef := range ra.Artifacts {
+		same, err := reference.SameRepository(artifactRef.SourceArtifact, ref.Name())
+		if err != nil {
+			return fmt.Errorf("failed to parse SourceArtifact %q: %w", artifactRef.SourceArtifact, err)
+		}
+		if !same {
+			continue
+		}
+		if !slices.Contains(imageMap[artifactRef.SourceArtifact], ref.Tag) {
+			imageMap[artifactRef.SourceArtifact] = append(imageMap[artifactRef.SourceArtifact], ref.Tag)
+		}
+		addPublishTime(updateArtifacts.PublishTimes, artifactRef.SourceArtifact, ref.Tag, publishTime)
+		return nil
+	}
+	if !slices.Contains(updateArtifacts.UnmappedImages, image) {
+		updateArtifacts.UnmappedImages = append(updateArtifacts.UnmappedImages, image)
+	}
+	return nil
+}
+
+func (ra *ReleaseAsset) Validate() error {
+	if _, err := ra.newGithubRelease(); err != nil {
+		return err
+	}
+	if ra.AssetName == "" && ra.URLTemplate == "" {
+		return errors.New("must specify one of AssetName or URLTemplate")
+	}
+	if ra.AssetName != "" && ra.URLTemplate != "" {
+		return errors.New("must not specify both AssetName and URLTemplate")
+	}
+	if ra.URLTemplate != "" {
+		compiledURLTemplate, err := template.New("URLTemplate").Option("missingkey=error").Parse(ra.URLTemplate)
+		if err != nil {
+			return fmt.Errorf("invalid URLTemplate: %w", err)
+		}
+		ra.compiledURLTemplate = compiledURLTemplate
+	}
+	return nil
+}
@@ -0,0 +1,272 @@
+package autoupdate
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-github/v80/github"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReleaseAsset(t *testing.T) {
+	t.Run("Validate", func(t *testing.T) {
+		type testCase struct {
+			Message       string
+			ReleaseAsset  *ReleaseAsset
+			ExpectedError string
+		}
+		testCases := []testCase{
+			{
+				Message: "should return nil for a valid ReleaseAsset using AssetName",
+				ReleaseAsset: &ReleaseAsset{
+					Owner:      "test-owner",
+					Repository: "test-repo",
+					Artifacts:  []AutoupdateArtifactRef{{SourceArtifact: "rancher/rancher"}},
+					AssetName:  "images.txt",
+				},
+				ExpectedError: "",
+			},
+			{
+				Message: "should return nil for a valid ReleaseAsset using URLTemplate",
+				ReleaseAsset: &ReleaseAsset{
+					Owner:       "test-owner",
+					Repository:  "test-repo",
+					Artifacts:   []AutoupdateArtifactRef{{SourceArtifact: "rancher/rancher"}},
+					URLTemplate: "https://example.com/{{ .Tag }}/images.txt",
+				},
+				ExpectedError: "",
+			},
+			{
+				Message: "should return error from release selection",
+				ReleaseAsset: &ReleaseAsset{
+					Repository: "test-repo",
+					Artifacts:  []AutoupdateArtifactRef{{SourceArtifact: "rancher/rancher"}},
+					AssetName:  "images.txt",
+				},
+				ExpectedError: "must specify Owner",
+			},
+			{
+				Message: "should return error for neither AssetName nor URLTemplate",
+				ReleaseAsset: &ReleaseAsset{
+					Owner:      "test-owner",
+					Repository: "test-repo",
+					Artifacts:  []AutoupdateArtifactRef{{SourceArtifact: "rancher/rancher"}},
+				},
+				ExpectedError: "must specify one of AssetName or URLTemplate",
+			},
+			{
+				Message: "should return error for both AssetName and URLTemplate",
+				ReleaseAsset: &ReleaseAsset{
+					Owner:       "test-owner",
+					Repository:  "test-repo",
+					Artifacts:   []AutoupdateArtifactRef{{SourceArtifact: "rancher/rancher"}},
+					AssetName:   "images.txt",
+					URLTemplate: "https://example.com/{{ .Tag }}/images.txt",
+				},
+				ExpectedError: "must not specify both AssetName and URLTemplate",
+			},
+			{
+				Message: "should return error for invalid URLTemplate",
+				ReleaseAsset: &ReleaseAsset{
+					Owner:       "test-owner",
+					Repository:  "test-repo",
+					Artifacts:   []AutoupdateArtifactRef{{SourceArtifact: "rancher/rancher"}},
+					URLTemplate: "https://example.com/{{ .Tag",
+				},
+				ExpectedError: "invalid URLTemplate: template: URLTemplate:1: unclosed action",
+			},
+		}
+		for _, testCase := range testCases {
+			t.Run(testCase.Message, func(t *testing.T) {
+				err := testCase.ReleaseAsset.Validate()
+				if testCase.ExpectedError == "" {
+					assert.Nil(t, err)
+				} else {
+					assert.EqualError(t, err, testCase.ExpectedError)
+				}
+			})
+		}
+	})
+
+	t.Run("GetUpdateArtifacts", func(t *testing.T) {
+		mux := http.NewServeMux()
+		server := httptest.NewServer(mux)
+		t.Cleanup(server.Close)
+		releases := []*github.RepositoryRelease{
+			{
+				TagName: github.Ptr("v2.12.1"),
+				Assets:  []*github.ReleaseAsset{{Name: github.Ptr("images.txt"), BrowserDownloadURL: github.Ptr(server.URL + "/download/v2.12.1/images.txt")}},
+			},
+			{
+				TagName: github.Ptr("v2.12.0"),
+				Assets:  []*github.ReleaseAsset{{Name: github.Ptr("images.txt"), BrowserDownloadURL: github.Ptr(server.URL + "/download/v2.12.0/images.txt")}},
+			},
+		}
+		mux.HandleFunc("GET /repos/test-owner/test-repo/releases", func(w http.ResponseWriter, r *http.Request) {
+			assert.NoError(t, json.NewEncoder(w).Encode(releases))
+		})
+		mux.HandleFunc("GET /download/{tag}/images.txt", func(w http.ResponseWriter, r *http.Request) {
+			tag := r.PathValue("tag")
+			_, err := w.Write([]byte("# images for " + tag + "\n" +
+				"rancher/rancher:" + tag + "\n" +
+				"docker.io/rancher/shell:v0.5.0\n" +
+				"\n" +
+				"registry.k8s.io/pause:3.10 source=upstream\n" +
+				"rancher/unmapped:" + tag + "\n"))
+			assert.NoError(t, err)
+		})
+
+		type testCase struct {
+			Message                string
+			ReleaseAsset           *ReleaseAsset
+			ExpectedTags           map[string][]string
+			ExpectedUnmappedImages []string
+		}
+		testCases := []testCase{
+			{
+				Message: "should map images from release assets",
+				ReleaseAsset: &ReleaseAsset{
+					AssetName:     "images.txt",
+					ImageDenylist: []string{"registry.k8s.io/pause"},
+				},
+				ExpectedTags: map[string][]string{
+					"rancher/rancher": {"v2.12.1", "v2.12.0"},
+					"rancher/shell":   {"v0.5.0"},
+				},
+				ExpectedUnmappedImages: []string{"rancher/unmapped:v2.12.1", "rancher/unmapped:v2.12.0"},
+			},
+			{
+				Message: "should use URLTemplate and report images missing from Artifacts",
+				ReleaseAsset: &ReleaseAsset{
+					URLTemplate:       server.URL + "/download/{{ .Tag }}/images.txt",
+					VersionConstraint: ">=2.12.1",
+				},
+				ExpectedTags: map[string][]string{
+					"rancher/rancher": {"v2.12.1"},
+					"rancher/shell":   {"v0.5.0"},
+				},
+				ExpectedUnmappedImages: []string{"registry.k8s.io/pause:3.10", "rancher/unmapped:v2.12.1"},
+			},
+		}
+		for _, testCase := range testCases {
+			t.Run(testCase.Message, func(t *testing.T) {
+				releaseAsset := testCase.ReleaseAsset
+				releaseAsset.Owner = "test-owner"
+				releaseAsset.Repository = "test-repo"
+				releaseAsset.Artifacts = []AutoupdateArtifactRef{
+					{SourceArtifact: "rancher/rancher"},
+					{SourceArtifact: "rancher/shell"},
+					{SourceArtifact: "rancher/not-in-list"},
+				}
+				releaseAsset.githubClient = newTestGithubClient(t, mux)
+				assert.NoError(t, releaseAsset.Validate())
+				updateArtifacts, err := releaseAsset.GetUpdateArtifacts(t.Context())
+				assert.NoError(t, err)
+				artifacts := updateArtifacts.Artifacts
+				found := map[string][]string{}
+				for _, artifact := range artifacts {
+					found[artifact.SourceArtifact] = artifact.Tags
+				}
+				assert.Equal(t, testCase.ExpectedTags, found)
+				assert.Equal(t, testCase.ExpectedUnmappedImages, updateArtifacts.UnmappedImages)
+			})
+		}
+
+		t.Run("should skip releases without the asset without needing Validate", func(t *testing.T) {
+			mux := http.NewServeMux()
+			server := httptest.NewServer(mux)
+			t.Cleanup(server.Close)
+			mux.HandleFunc("GET /repos/test-owner/test-repo/releases", func(w http.ResponseWriter, r *http.Request) {
+				assert.NoError(t, json.NewEncoder(w).Encode([]*github.RepositoryRelease{
+					{TagName: github.Ptr("v2.13.0")},
+					{
+						TagName: github.Ptr("v2.12.1"),
+						Assets:  []*github.ReleaseAsset{{Name: github.Ptr("images.txt"), BrowserDownloadURL: github.Ptr(server.URL + "/download/v2.12.1/images.txt")}},
+					},
+				}))
+			})
+			mux.HandleFunc("GET /download/v2.12.1/images.txt", func(w http.ResponseWriter, r *http.Request) {
+				_, err := w.Write([]byte("rancher/rancher:v2.12.1\n"))
+				assert.NoError(t, err)
+			})
+			releaseAsset := &ReleaseAsset{
+				Owner:        "test-owner",
+				Repository:   "test-repo",
+				Artifacts:    []AutoupdateArtifactRef{{SourceArtifact: "rancher/rancher"}},
+				AssetName:    "images.txt",
+				githubClient: newTestGithubClient(t, mux),
+			}
+			updateArtifacts, err := releaseAsset.GetUpdateArtifacts(t.Context())
+			assert.NoError(t, err)
+			assert.Len(t, updateArtifacts.Artifacts, 1)
+			assert.Equal(t, []string{"v2.12.1"}, updateArtifacts.Artifacts[0].Tags)
+			assert.Equal(t, []SkippedRelease{{Tag: "v2.13.0", Reason: `release has no asset named "images.txt"`}}, updateArtifacts.SkippedReleases)
+		})
+
+		t.Run("should skip releases whose image list is not found at URLTemplate", func(t *testing.T) {
+			mux := http.NewServeMux()
+			server := httptest.NewServer(mux)
+			t.Cleanup(server.Close)
+			mux.HandleFunc("GET /repos/test-owner/test-repo/releases", func(w http.ResponseWriter, r *http.Request) {
+				assert.NoError(t, json.NewEncoder(w).Encode([]*github.RepositoryRelease{
+					{TagName: github.Ptr("v2.13.0")},
+					{TagName: github.Ptr("v2.12.1")},
+				}))
+			})
+			mux.HandleFunc("GET /download/v2.12.1/images.txt", func(w http.ResponseWriter, r *http.Request) {
+				_, err := w.Write([]byte("rancher/rancher:v2.12.1\n"))
+				assert.NoError(t, err)
+			})
+			releaseAsset := &ReleaseAsset{
+				Owner:        "test-owner",
+				Repository:   "test-repo",
+				Artifacts:    []AutoupdateArtifactRef{{SourceArtifact: "rancher/rancher"}},
+				URLTemplate:  server.URL + "/download/{{ .Tag }}/images.txt",
+				githubClient: newTestGithubClient(t, mux),
+			}
+			assert.NoError(t, releaseAsset.Validate())
+			updateArtifacts, err := releaseAsset.GetUpdateArtifacts(t.Context())
+			assert.NoError(t, err)
+			assert.Len(t, updateArtifacts.Artifacts, 1)
+			assert.Equal(t, []string{"v2.12.1"}, updateArtifacts.Artifacts[0].Tags)
+			assert.Equal(t, []SkippedRelease{{
+				Tag:    "v2.13.0",
+				Reason: "release has no asset at " + server.URL + "/download/v2.13.0/images.txt",
+			}}, updateArtifacts.SkippedReleases)
+		})
+
+		t.Run("should only download releases kept by Versions", func(t *testing.T) {
+			mux := http.NewServeMux()
+			server := httptest.NewServer(mux)
+			t.Cleanup(server.Close)
+			mux.HandleFunc("GET /repos/test-owner/test-repo/releases", func(w http.ResponseWriter, r *http.Request) {
+				assert.NoError(t, json.NewEncoder(w).Encode([]*github.RepositoryRelease{
+					{TagName: github.Ptr("v2.12.1")},
+					{TagName: github.Ptr("v2.12.0")},
+				}))
+			})
+			downloads := make([]string, 0)
+			mux.HandleFunc("GET /download/{tag}/images.txt", func(w http.ResponseWriter, r *http.Request) {
+				downloads = append(downloads, r.PathValue("tag"))
+				_, err := w.Write([]byte("rancher/rancher:" + r.PathValue("tag") + "\n"))
+				assert.NoError(t, err)
+			})
+			releaseAsset := &ReleaseAsset{
+				Owner:        "test-owner",
+				Repository:   "test-repo",
+				Artifacts:    []AutoupdateArtifactRef{{SourceArtifact: "rancher/rancher"}},
+				URLTemplate:  server.URL + "/download/{{ .Tag }}/images.txt",
+				githubClient: newTestGithubClient(t, mux),
+				versions:     &VersionPolicy{KeepLatest: 1},
+			}
+			assert.NoError(t, releaseAsset.Validate())
+			updateArtifacts, err := releaseAsset.GetUpdateArtifacts(t.Context())
+			assert.NoError(t, err)
+			assert.Len(t, updateArtifacts.Artifacts, 1)
+			assert.Equal(t, []string{"v2.12.1"}, updateArtifacts.Artifacts[0].Tags)
+			assert.Equal(t, []string{"v2.12.1"}, downloads)
+		})
+	})
+}
@@ -0,0 +1,220 @@
+package autoupdate
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var record = flag.Bool("record", false, "make real requests and record them in testdata/replay, instead of replaying the recorded responses")
+
+// recordedHeaders are the response headers that are recorded. Other
+// headers are left out to keep the recordings small and free of cookies.
+var recordedHeaders = []string{
+	"Content-Type",
+	"Docker-Content-Digest",
+	"Link",
+	"Location",
+	"WWW-Authenticate",
+}
+
+// httpInteraction is a request and the response that was recorded for it.
+// Request headers are not recorded, so that credentials never end up in
+// testdata.
+type httpInteraction struct {
+	Method string
+	URL    string
+	Status int
+	Header map[string]string `json:",omitempty"`
+	Body   string            `json:",omitempty"`
+}
+
+// newReplayClient returns an http.Client that replays the interactions in
+// testdata/replay/<name>.json. Each interaction is replayed once, in the
+// order in which requests for the same method and URL were recorded, and
+// the test fails if some are not replayed. With -record, the client makes
+// real requests instead and records them to the file.
+func newReplayClient(t *testing.T, name string) *http.Client {
+	t.Helper()
+	path := filepath.Join("testdata", "replay", name+".json")
+	if *record {
+		transport := &recordingTransport{}
+		t.Cleanup(func() {
+			if err := transport.save(path); err != nil {
+				t.Errorf("failed to save recording: %s", err)
+			}
+		})
+		return &http.Client{Transport: transport}
+	}
+
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read recording: %s", err)
+	}
+	transport := &replayTransport{}
+	if err := json.Unmarshal(contents, &transport.interactions); err != nil {
+		t.Fatalf("failed to parse recording %s: %s", path, err)
+	}
+	transport.replayed = make([]bool, len(transport.interactions))
+	t.Cleanup(func() {
+		for i, interaction := range transport.interactions {
+			if !transport.replayed[i] {
+				t.Errorf("recorded request was not made: %s %s", interaction.Method, interaction.URL)
+			}
+		}
+	})
+	return &http.Client{Transport: transport}
+}
+
+// replayTransport is an http.RoundTripper that responds with recorded
+// interactions instead of making requests.
+type replayTransport struct {
+	lock         sync.Mutex
+	interactions []httpInteraction
+	replayed     []bool
+}
+
+func (rt *replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	rt.lock.Lock()
+	defer rt.lock.Unlock()
+	for i, interaction := range rt.interactions {
+		if rt.replayed[i] || interaction.Method != req.Method || interaction.URL != req.URL.String() {
+			continue
+		}
+		rt.replayed[i] = true
+		header := http.Header{}
+		for key, value := range interaction.Header {
+			header.Set(key, value)
+		}
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", interaction.Status, http.StatusText(interaction.Status)),
+			StatusCode:    interaction.Status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        header,
+			Body:          io.NopCloser(strings.NewReader(interaction.Body)),
+			ContentLength: int64(len(interaction.Body)),
+			Request:       req,
+		}, nil
+	}
+	return nil, fmt.Errorf("no recorded response for %s %s", req.Method, req.URL)
+}
+
+// recordingTransport is an http.RoundTripper that makes requests with
+// http.DefaultTransport and records them.
+type recordingTransport struct {
+	lock         sync.Mutex
+	interactions []httpInteraction
+}
+
+func (rt *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	interaction := httpInteraction{
+		Method: req.Method,
+		URL:    req.URL.String(),
+		Status: resp.StatusCode,
+		Header: map[string]string{},
+		Body:   string(body),
+	}
+	for _, key := range recordedHeaders {
+		if value := resp.Header.Get(key); value != "" {
+			interaction.Header[key] = value
+		}
+	}
+	rt.lock.Lock()
+	rt.interactions = append(rt.interactions, interaction)
+	rt.lock.Unlock()
+	return resp, nil
+}
+
+func (rt *recordingTransport) save(path string) error {
+	contents, err := json.MarshalIndent(rt.interactions, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(contents, '\n'), 0o644)
+}
+
+// requestLog is an http.RoundTripper that records the URLs of the requests
+// made through it, so that tests can check which requests were made
+// without depending on the contents of a recording.
+type requestLog struct {
+	lock      sync.Mutex
+	transport http.RoundTripper
+	urls      []string
+}
+
+// logRequests makes client record the URLs of its requests in the returned
+// requestLog.
+func logRequests(client *http.Client) *requestLog {
+	log := &requestLog{transport: client.Transport}
+	client.Transport = log
+	return log
+}
+
+func (l *requestLog) RoundTrip(req *http.Request) (*http.Response, error) {
+	l.lock.Lock()
+	l.urls = append(l.urls, req.URL.String())
+	l.lock.Unlock()
+	return l.transport.RoundTrip(req)
+}
+
+// URLs returns the URLs of the requests made so far, in order.
+func (l *requestLog) URLs() []string {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return slices.Clone(l.urls)
+}
+
+func TestReplayTransport(t *testing.T) {
+	t.Run("should replay interactions for the same URL in order", func(t *testing.T) {
+		transport := &replayTransport{
+			interactions: []httpInteraction{
+				{Method: http.MethodGet, URL: "https://example.com/a", Status: http.StatusUnauthorized},
+				{Method: http.MethodGet, URL: "https://example.com/b", Status: http.StatusNotFound},
+				{Method: http.MethodGet, URL: "https://example.com/a", Status: http.StatusOK, Body: "body"},
+			},
+			replayed: make([]bool, 3),
+		}
+		client := &http.Client{Transport: transport}
+
+		resp, err := client.Get("https://example.com/a")
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		resp, err = client.Get("https://example.com/a")
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		body, err := io.ReadAll(resp.Body)
+		assert.NoError(t, err)
+		assert.Equal(t, "body", string(body))
+
+		_, err = client.Get("https://example.com/a")
+		assert.ErrorContains(t, err, "no recorded response for GET https://example.com/a")
+		assert.Equal(t, []bool{true, false, true}, transport.replayed)
+	})
+}
@@ -0,0 +1,204 @@
+package autoupdate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// retryTransport is an http.RoundTripper that retries requests that fail
+// with a server error or because of a rate limit. The delay before each
+// retry is taken from the Retry-After header or from the rate limit headers
+// of GitHub and Docker Hub if present, and is an exponential backoff
+// otherwise. A retry is not made if it would have to wait past the deadline
+// of the context of the request, or longer than MaxDelay; the failed
+// response is returned instead.
+type retryTransport struct {
+	// Transport makes the requests. Defaults to http.DefaultTransport.
+	Transport http.RoundTripper
+	// MaxRetries is the maximum number of times a request is retried.
+	MaxRetries int
+	// BaseDelay is the delay before the first retry, if the response does
+	// not specify one. It doubles with every retry.
+	BaseDelay time.Duration
+	// MaxDelay is the longest time that is waited before a single retry.
+	MaxDelay time.Duration
+	// now returns the current time. Defaults to time.Now.
+	now func() time.Time
+	// sleep waits for d, or until ctx is done. Defaults to sleepContext.
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// newRetryTransport returns the retryTransport that is shared by the
+// clients of the update strategies.
+func newRetryTransport() *retryTransport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = 10 * time.Second
+	return &retryTransport{
+		Transport:  transport,
+		MaxRetries: 10,
+		BaseDelay:  time.Second,
+		MaxDelay:   5 * time.Minute,
+	}
+}
+
+func (rt *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	transport := rt.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	now := rt.now
+	if now == nil {
+		now = time.Now
+	}
+	sleep := rt.sleep
+	if sleep == nil {
+		sleep = sleepContext
+	}
+
+	for attempt := 0; ; attempt++ {
+		outgoing := req
+		if attempt > 0 && req.Body != nil {
+			if req.GetBody == nil {
+				return nil, errors.New("cannot retry request with body that cannot be rewound")
+			}
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, fmt.Errorf("failed to rewind request body: %w", err)
+			}
+			outgoing = req.Clone(req.Context())
+			outgoing.Body = body
+		}
+
+		resp, err := transport.RoundTrip(outgoing)
+		if err != nil {
+			return nil, err
+		}
+		reason, delay := retryReason(resp, now())
+		if reason == "" || attempt == rt.MaxRetries {
+			return resp, nil
+		}
+		if delay == 0 {
+			delay = rt.BaseDelay << attempt
+		}
+		if delay > rt.MaxDelay {
+			fmt.Printf("not retrying %s %s: %s, and waiting %s is longer than %s\n", req.Method, req.URL.Redacted(), reason, delay, rt.MaxDelay)
+			return resp, nil
+		}
+		if deadline, ok := req.Context().Deadline(); ok && now().Add(delay).After(deadline) {
+			fmt.Printf("not retrying %s %s: %s, and waiting %s would exceed the deadline\n", req.Method, req.URL.Redacted(), reason, delay)
+			return resp, nil
+		}
+
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		fmt.Printf("retrying %s %s in %s (attempt %d of %d): %s\n", req.Method, req.URL.Redacted(), delay, attempt+1, rt.MaxRetries, reason)
+		if err := sleep(req.Context(), delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// retryReason returns why the request that resp is the response to should
+// be retried, and how long to wait before doing so. If it should not be
+// retried, the reason is empty. If the response does not say how long to
+// wait, the delay is 0.
+func retryReason(resp *http.Response, now time.Time) (string, time.Duration) {
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		if delay, source, ok := rateLimitDelay(resp.Header, now); ok {
+			return fmt.Sprintf("rate limited (status %s, %s)", resp.Status, source), delay
+		}
+		return fmt.Sprintf("rate limited (status %s)", resp.Status), 0
+	case resp.StatusCode == http.StatusForbidden:
+		// GitHub responds to requests that exceed a rate limit with 403
+		// instead of 429.
+		if delay, source, ok := rateLimitDelay(resp.Header, now); ok {
+			return fmt.Sprintf("rate limited (status %s, %s)", resp.Status, source), delay
+		}
+		return "", 0
+	case resp.StatusCode == http.StatusInternalServerError,
+		resp.StatusCode == http.StatusBadGateway,
+		resp.StatusCode == http.StatusServiceUnavailable,
+		resp.StatusCode == http.StatusGatewayTimeout:
+		if delay, ok := parseRetryAfter(resp.Header.Get("Retry-After"), now); ok {
+			return fmt.Sprintf("server error (status %s, Retry-After)", resp.Status), delay
+		}
+		return fmt.Sprintf("server error (status %s)", resp.Status), 0
+	default:
+		return "", 0
+	}
+}
+
+// rateLimitDelay returns how long the rate limit headers in header say to
+// wait, along with the name of the header that says so. It understands
+// Retry-After, and the X-RateLimit-Remaining and X-RateLimit-Reset headers
+// that GitHub and the Docker Hub API send.
+func rateLimitDelay(header http.Header, now time.Time) (time.Duration, string, bool) {
+	if delay, ok := parseRetryAfter(header.Get("Retry-After"), now); ok {
+		return delay, "Retry-After", true
+	}
+	if rateLimitRemaining(header.Get("X-RateLimit-Remaining")) != 0 {
+		return 0, "", false
+	}
+	reset, err := strconv.ParseInt(header.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return 0, "", false
+	}
+	delay := time.Unix(reset, 0).Sub(now)
+	if delay < 0 {
+		delay = 0
+	}
+	// The reset time has a resolution of one second, so we wait one more
+	// second to be sure that the rate limit has been reset.
+	return delay + time.Second, fmt.Sprintf("X-RateLimit-Reset at %s", time.Unix(reset, 0).UTC().Format(time.RFC3339)), true
+}
+
+// rateLimitRemaining parses a rate limit remaining header. Docker Hub adds
+// the window to the count, as in "0;w=21600". If the header is missing or
+// invalid, it returns -1.
+func rateLimitRemaining(value string) int {
+	count, _, _ := strings.Cut(value, ";")
+	remaining, err := strconv.Atoi(strings.TrimSpace(count))
+	if err != nil {
+		return -1
+	}
+	return remaining
+}
+
+// parseRetryAfter parses a Retry-After header, which is either a number of
+// seconds or an HTTP date.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		delay := date.Sub(now)
+		if delay < 0 {
+			delay = 0
+		}
+		return delay, true
+	}
+	return 0, false
+}
+
+// sleepContext waits for d, or until ctx is done, in which case it returns
+// the error of ctx.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
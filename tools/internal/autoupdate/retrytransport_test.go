@@ -0,0 +1,219 @@
+package autoupdate
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryTransport(t *testing.T) {
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	type testCase struct {
+		Message          string
+		Responses        []func(w http.ResponseWriter)
+		Deadline         time.Time
+		ExpectedStatus   int
+		ExpectedRequests int
+		ExpectedDelays   []time.Duration
+	}
+	ok := func(w http.ResponseWriter) {
+		w.WriteHeader(http.StatusOK)
+	}
+	withStatus := func(status int, header map[string]string) func(w http.ResponseWriter) {
+		return func(w http.ResponseWriter) {
+			for key, value := range header {
+				w.Header().Set(key, value)
+			}
+			w.WriteHeader(status)
+		}
+	}
+	testCases := []testCase{
+		{
+			Message:          "should not retry successful requests",
+			Responses:        []func(w http.ResponseWriter){ok},
+			ExpectedStatus:   http.StatusOK,
+			ExpectedRequests: 1,
+			ExpectedDelays:   nil,
+		},
+		{
+			Message:          "should not retry client errors",
+			Responses:        []func(w http.ResponseWriter){withStatus(http.StatusNotFound, nil)},
+			ExpectedStatus:   http.StatusNotFound,
+			ExpectedRequests: 1,
+			ExpectedDelays:   nil,
+		},
+		{
+			Message: "should retry server errors with exponential backoff",
+			Responses: []func(w http.ResponseWriter){
+				withStatus(http.StatusBadGateway, nil),
+				withStatus(http.StatusServiceUnavailable, nil),
+				ok,
+			},
+			ExpectedStatus:   http.StatusOK,
+			ExpectedRequests: 3,
+			ExpectedDelays:   []time.Duration{time.Second, 2 * time.Second},
+		},
+		{
+			Message: "should retry 429 after the number of seconds in Retry-After",
+			Responses: []func(w http.ResponseWriter){
+				withStatus(http.StatusTooManyRequests, map[string]string{"Retry-After": "7"}),
+				ok,
+			},
+			ExpectedStatus:   http.StatusOK,
+			ExpectedRequests: 2,
+			ExpectedDelays:   []time.Duration{7 * time.Second},
+		},
+		{
+			Message: "should retry 429 at the date in Retry-After",
+			Responses: []func(w http.ResponseWriter){
+				withStatus(http.StatusTooManyRequests, map[string]string{"Retry-After": now.Add(time.Minute).Format(http.TimeFormat)}),
+				ok,
+			},
+			ExpectedStatus:   http.StatusOK,
+			ExpectedRequests: 2,
+			ExpectedDelays:   []time.Duration{time.Minute},
+		},
+		{
+			Message: "should retry GitHub rate limit errors after X-RateLimit-Reset",
+			Responses: []func(w http.ResponseWriter){
+				withStatus(http.StatusForbidden, map[string]string{
+					"X-RateLimit-Remaining": "0",
+					"X-RateLimit-Reset":     strconv.FormatInt(now.Add(30*time.Second).Unix(), 10),
+				}),
+				ok,
+			},
+			ExpectedStatus:   http.StatusOK,
+			ExpectedRequests: 2,
+			ExpectedDelays:   []time.Duration{31 * time.Second},
+		},
+		{
+			Message: "should understand Docker Hub rate limit headers",
+			Responses: []func(w http.ResponseWriter){
+				withStatus(http.StatusTooManyRequests, map[string]string{
+					"X-RateLimit-Remaining": "0;w=21600",
+					"X-RateLimit-Reset":     strconv.FormatInt(now.Add(10*time.Second).Unix(), 10),
+				}),
+				ok,
+			},
+			ExpectedStatus:   http.StatusOK,
+			ExpectedRequests: 2,
+			ExpectedDelays:   []time.Duration{11 * time.Second},
+		},
+		{
+			Message: "should not retry 403 without rate limit headers",
+			Responses: []func(w http.ResponseWriter){
+				withStatus(http.StatusForbidden, map[string]string{"X-RateLimit-Remaining": "10"}),
+			},
+			ExpectedStatus:   http.StatusForbidden,
+			ExpectedRequests: 1,
+			ExpectedDelays:   nil,
+		},
+		{
+			Message: "should not retry if the delay is longer than MaxDelay",
+			Responses: []func(w http.ResponseWriter){
+				withStatus(http.StatusTooManyRequests, map[string]string{"Retry-After": "3600"}),
+			},
+			ExpectedStatus:   http.StatusTooManyRequests,
+			ExpectedRequests: 1,
+			ExpectedDelays:   nil,
+		},
+		{
+			Message: "should not retry if the delay would exceed the deadline",
+			Responses: []func(w http.ResponseWriter){
+				withStatus(http.StatusTooManyRequests, map[string]string{"Retry-After": "60"}),
+			},
+			Deadline:         now.Add(30 * time.Second),
+			ExpectedStatus:   http.StatusTooManyRequests,
+			ExpectedRequests: 1,
+			ExpectedDelays:   nil,
+		},
+		{
+			Message: "should return the last response after MaxRetries",
+			Responses: []func(w http.ResponseWriter){
+				withStatus(http.StatusInternalServerError, nil),
+				withStatus(http.StatusInternalServerError, nil),
+				withStatus(http.StatusInternalServerError, nil),
+				withStatus(http.StatusInternalServerError, nil),
+			},
+			ExpectedStatus:   http.StatusInternalServerError,
+			ExpectedRequests: 4,
+			ExpectedDelays:   []time.Duration{time.Second, 2 * time.Second, 4 * time.Second},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.Message, func(t *testing.T) {
+			requests := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				testCase.Responses[requests](w)
+				requests++
+			}))
+			t.Cleanup(server.Close)
+
+			var delays []time.Duration
+			transport := &retryTransport{
+				MaxRetries: 3,
+				BaseDelay:  time.Second,
+				MaxDelay:   5 * time.Minute,
+				now:        func() time.Time { return now },
+				sleep: func(ctx context.Context, d time.Duration) error {
+					delays = append(delays, d)
+					return nil
+				},
+			}
+			var ctx context.Context = t.Context()
+			if !testCase.Deadline.IsZero() {
+				ctx = deadlineOnlyContext{Context: ctx, deadline: testCase.Deadline}
+			}
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+			assert.NoError(t, err)
+
+			resp, err := transport.RoundTrip(req)
+			assert.NoError(t, err)
+			if resp != nil {
+				resp.Body.Close()
+				assert.Equal(t, testCase.ExpectedStatus, resp.StatusCode)
+			}
+			assert.Equal(t, testCase.ExpectedRequests, requests)
+			assert.Equal(t, testCase.ExpectedDelays, delays)
+		})
+	}
+
+	t.Run("should stop waiting when the context is cancelled", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		t.Cleanup(server.Close)
+
+		ctx, cancel := context.WithCancel(t.Context())
+		transport := &retryTransport{
+			MaxRetries: 3,
+			BaseDelay:  time.Hour,
+			MaxDelay:   2 * time.Hour,
+			sleep: func(ctx context.Context, d time.Duration) error {
+				cancel()
+				return sleepContext(ctx, d)
+			},
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+		assert.NoError(t, err)
+		_, err = transport.RoundTrip(req)
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+// deadlineOnlyContext reports a deadline without ever being done, so that
+// a deadline relative to a fake clock can be tested.
+type deadlineOnlyContext struct {
+	context.Context
+	deadline time.Time
+}
+
+func (c deadlineOnlyContext) Deadline() (time.Time, bool) {
+	return c.deadline, true
+}
@@ -0,0 +1,157 @@
+package autoupdate
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/rancher/artifact-mirror/internal/config"
+)
+
+type artifactTag struct {
+	artifact *config.Artifact
+	tag      string
+	// key is tag parsed according to the tag ordering, or nil if the tag
+	// ordering cannot parse it.
+	key any
+}
+
+// splitArtifacts splits artifacts into groups that each contain at most
+// maxTags tags. Tags are ordered newest first according to ordering, so the
+// first group contains the newest versions. Only the first group may have
+// fewer than maxTags tags. Tags that ordering cannot parse
+// come after all others. creationTimes are keyed by "<source artifact>:<tag>"
+// and only used by TagOrderingCreationTime. If maxTags is 0, all artifacts
+// are returned in a single group.
+func splitArtifacts(artifacts []*config.Artifact, maxTags int, ordering TagOrdering, creationTimes map[string]time.Time) ([][]*config.Artifact, error) {
+	if maxTags == 0 {
+		return [][]*config.Artifact{artifacts}, nil
+	}
+
+	_, compare, err := ordering.keyFuncs(nil)
+	if err != nil {
+		return nil, err
+	}
+	artifactTags := make([]artifactTag, 0)
+	for _, artifact := range artifacts {
+		artifactCreationTimes := make(map[string]time.Time, len(artifact.Tags))
+		for _, tag := range artifact.Tags {
+			if creationTime, ok := creationTimes[artifact.SourceArtifact+":"+tag]; ok {
+				artifactCreationTimes[tag] = creationTime
+			}
+		}
+		parse, _, err := ordering.keyFuncs(artifactCreationTimes)
+		if err != nil {
+			return nil, err
+		}
+		for _, tag := range artifact.Tags {
+			key, err := parse(tag)
+			if err != nil {
+				key = nil
+			}
+			artifactTags = append(artifactTags, artifactTag{artifact: artifact, tag: tag, key: key})
+		}
+	}
+	slices.SortStableFunc(artifactTags, func(a, b artifactTag) int {
+		switch {
+		case a.key != nil && b.key != nil:
+			if value := compare(b.key, a.key); value != 0 {
+				return value
+			}
+		case a.key != nil:
+			return -1
+		case b.key != nil:
+			return 1
+		}
+		if value := strings.Compare(b.tag, a.tag); value != 0 {
+			return value
+		}
+		return config.CompareArtifacts(a.artifact, b.artifact)
+	})
+
+	// Chunks are counted from the oldest tag, so that new tags only change
+	// the newest group, and the branches of the other groups stay the same.
+	newestSize := len(artifactTags) % maxTags
+	if newestSize == 0 {
+		newestSize = min(maxTags, len(artifactTags))
+	}
+	groups := [][]*config.Artifact{groupArtifactTags(artifactTags[:newestSize])}
+	for chunk := range slices.Chunk(artifactTags[newestSize:], maxTags) {
+		groups = append(groups, groupArtifactTags(chunk))
+	}
+	return groups, nil
+}
+
+// groupArtifactTags combines artifactTags that refer to the same artifact
+// into a single copy of that artifact.
+func groupArtifactTags(artifactTags []artifactTag) []*config.Artifact {
+	group := make([]*config.Artifact, 0)
+	copies := map[*config.Artifact]*config.Artifact{}
+	for _, artifactTag := range artifactTags {
+		artifactCopy, ok := copies[artifactTag.artifact]
+		if !ok {
+			artifactCopy = artifactTag.artifact.DeepCopy()
+			artifactCopy.Tags = make([]string, 0, 1)
+			copies[artifactTag.artifact] = artifactCopy
+			group = append(group, artifactCopy)
+		}
+		artifactCopy.Tags = append(artifactCopy.Tags, artifactTag.tag)
+	}
+	return group
+}
+
+// tagOrdering returns how the tags found by the update strategy of entry
+// are ordered: by the Ordering of Registry, or as semantic versions, like
+// Versions does, for the other update strategies.
+func (entry ConfigEntry) tagOrdering() TagOrdering {
+	if entry.Registry != nil {
+		return entry.Registry.Ordering
+	}
+	return TagOrderingSemver
+}
+
+// addedTagsHeading precedes the list of tags in the body of the pull
+// requests made by CreateArtifactUpdatePullRequest.
+const addedTagsHeading = "It adds the following artifact tags:"
+
+// removePullRequestTags removes the tags that pullRequests add from
+// artifacts. The tags are read from the pull request bodies. It returns the
+// artifacts that still have tags, and the removed tags as
+// "<source artifact>:<tag>".
+func removePullRequestTags(artifacts []*config.Artifact, pullRequests []PullRequest) ([]*config.Artifact, []string) {
+	pullRequestTags := map[string]struct{}{}
+	for _, pullRequest := range pullRequests {
+		_, list, found := strings.Cut(pullRequest.Body, addedTagsHeading+"\n")
+		if !found {
+			continue
+		}
+		for line := range strings.Lines(list) {
+			fullArtifact, ok := strings.CutPrefix(strings.TrimSpace(line), "- `")
+			if !ok {
+				break
+			}
+			pullRequestTags[strings.TrimSuffix(fullArtifact, "`")] = struct{}{}
+		}
+	}
+
+	remainingArtifacts := make([]*config.Artifact, 0, len(artifacts))
+	removedTags := make([]string, 0)
+	for _, artifact := range artifacts {
+		remainingTags := make([]string, 0, len(artifact.Tags))
+		for _, tag := range artifact.Tags {
+			fullArtifact := artifact.SourceArtifact + ":" + tag
+			if _, ok := pullRequestTags[fullArtifact]; ok {
+				removedTags = append(removedTags, fullArtifact)
+				continue
+			}
+			remainingTags = append(remainingTags, tag)
+		}
+		if len(remainingTags) == 0 {
+			continue
+		}
+		remainingArtifact := artifact.DeepCopy()
+		remainingArtifact.Tags = remainingTags
+		remainingArtifacts = append(remainingArtifacts, remainingArtifact)
+	}
+	return remainingArtifacts, removedTags
+}
@@ -0,0 +1,107 @@
+package autoupdate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rancher/artifact-mirror/internal/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitArtifacts(t *testing.T) {
+	newArtifacts := func(t *testing.T) []*config.Artifact {
+		t.Helper()
+		artifact1, err := config.NewArtifact("test-org/artifact1", []string{"v1.9.0", "v1.10.0", "v1.8.0"}, "", nil, nil)
+		assert.NoError(t, err)
+		artifact2, err := config.NewArtifact("test-org/artifact2", []string{"v1.10.0"}, "", nil, nil)
+		assert.NoError(t, err)
+		return []*config.Artifact{artifact1, artifact2}
+	}
+
+	t.Run("should return a single group when maxTags is 0", func(t *testing.T) {
+		artifacts := newArtifacts(t)
+		groups, err := splitArtifacts(artifacts, 0, TagOrderingSemver, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, [][]*config.Artifact{artifacts}, groups)
+	})
+
+	t.Run("should split into groups with the newest versions first", func(t *testing.T) {
+		groups, err := splitArtifacts(newArtifacts(t), 2, TagOrderingSemver, nil)
+		assert.NoError(t, err)
+		assert.Len(t, groups, 2)
+
+		assert.Len(t, groups[0], 2)
+		assert.Equal(t, "test-org/artifact1", groups[0][0].SourceArtifact)
+		assert.Equal(t, []string{"v1.10.0"}, groups[0][0].Tags)
+		assert.Equal(t, "test-org/artifact2", groups[0][1].SourceArtifact)
+		assert.Equal(t, []string{"v1.10.0"}, groups[0][1].Tags)
+
+		assert.Len(t, groups[1], 1)
+		assert.Equal(t, "test-org/artifact1", groups[1][0].SourceArtifact)
+		assert.Equal(t, []string{"v1.9.0", "v1.8.0"}, groups[1][0].Tags)
+	})
+
+	t.Run("should keep the groups of older versions when newer versions are added", func(t *testing.T) {
+		artifact, err := config.NewArtifact("test-org/artifact1", []string{"v1.1.0", "v1.2.0"}, "", nil, nil)
+		assert.NoError(t, err)
+		groups, err := splitArtifacts([]*config.Artifact{artifact}, 2, TagOrderingSemver, nil)
+		assert.NoError(t, err)
+		assert.Len(t, groups, 1)
+
+		artifact, err = config.NewArtifact("test-org/artifact1", []string{"v1.1.0", "v1.2.0", "v1.3.0"}, "", nil, nil)
+		assert.NoError(t, err)
+		newGroups, err := splitArtifacts([]*config.Artifact{artifact}, 2, TagOrderingSemver, nil)
+		assert.NoError(t, err)
+		assert.Len(t, newGroups, 2)
+		assert.Equal(t, []string{"v1.3.0"}, newGroups[0][0].Tags)
+		assert.Equal(t, groups[0], newGroups[1])
+	})
+
+	t.Run("should produce the same groups regardless of input order", func(t *testing.T) {
+		artifacts := newArtifacts(t)
+		reversed := []*config.Artifact{artifacts[1], artifacts[0]}
+		groups, err := splitArtifacts(artifacts, 1, TagOrderingSemver, nil)
+		assert.NoError(t, err)
+		reversedGroups, err := splitArtifacts(reversed, 1, TagOrderingSemver, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, groups, reversedGroups)
+	})
+
+	t.Run("should order tags according to the tag ordering", func(t *testing.T) {
+		artifact, err := config.NewArtifact("test-org/artifact1", []string{"2024.9.1", "2024.10.1", "2023.12.1"}, "", nil, nil)
+		assert.NoError(t, err)
+		groups, err := splitArtifacts([]*config.Artifact{artifact}, 1, TagOrderingCalendar, nil)
+		assert.NoError(t, err)
+		assert.Len(t, groups, 3)
+		assert.Equal(t, []string{"2024.10.1"}, groups[0][0].Tags)
+		assert.Equal(t, []string{"2024.9.1"}, groups[1][0].Tags)
+		assert.Equal(t, []string{"2023.12.1"}, groups[2][0].Tags)
+	})
+
+	t.Run("should order tags by creation time", func(t *testing.T) {
+		artifact1, err := config.NewArtifact("test-org/artifact1", []string{"stable"}, "", nil, nil)
+		assert.NoError(t, err)
+		artifact2, err := config.NewArtifact("test-org/artifact2", []string{"latest"}, "", nil, nil)
+		assert.NoError(t, err)
+		creationTimes := map[string]time.Time{
+			"test-org/artifact1:stable": time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC),
+			"test-org/artifact2:latest": time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		}
+		groups, err := splitArtifacts([]*config.Artifact{artifact2, artifact1}, 1, TagOrderingCreationTime, creationTimes)
+		assert.NoError(t, err)
+		assert.Len(t, groups, 2)
+		assert.Equal(t, "test-org/artifact1", groups[0][0].SourceArtifact)
+		assert.Equal(t, "test-org/artifact2", groups[1][0].SourceArtifact)
+	})
+
+	t.Run("should put tags that cannot be ordered last", func(t *testing.T) {
+		artifact, err := config.NewArtifact("test-org/artifact1", []string{"latest", "v1.0.0", "v2.0.0", "edge"}, "", nil, nil)
+		assert.NoError(t, err)
+		groups, err := splitArtifacts([]*config.Artifact{artifact}, 2, TagOrderingSemver, nil)
+		assert.NoError(t, err)
+		assert.Len(t, groups, 2)
+		assert.Equal(t, []string{"v2.0.0", "v1.0.0"}, groups[0][0].Tags)
+		assert.Equal(t, []string{"latest", "edge"}, groups[1][0].Tags)
+	})
+}
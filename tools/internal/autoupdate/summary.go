@@ -0,0 +1,157 @@
+package autoupdate
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// Outcome describes what happened when an autoupdate entry was run.
+type Outcome string
+
+const (
+	OutcomeNoUpdates           Outcome = "no updates"
+	OutcomeExistingPullRequest Outcome = "existing pull request"
+	OutcomePullRequestCreated  Outcome = "pull request created"
+	OutcomeDryRun              Outcome = "dry run"
+	OutcomeError               Outcome = "error"
+)
+
+// outcomePriority is used to pick the outcome of an entry that made
+// several pull requests. The outcome with the highest priority wins.
+var outcomePriority = map[Outcome]int{
+	OutcomeNoUpdates:           0,
+	OutcomeExistingPullRequest: 1,
+	OutcomeDryRun:              2,
+	OutcomePullRequestCreated:  3,
+	OutcomeError:               4,
+}
+
+// EntryResult records the result of running a single autoupdate entry.
+type EntryResult struct {
+	Name    string  `json:"name"`
+	Outcome Outcome `json:"outcome"`
+	// ProposedTags are the full references of the tags that were proposed,
+	// whether in new or existing pull requests.
+	ProposedTags []string     `json:"proposedTags,omitempty"`
+	SkippedTags  []SkippedTag `json:"skippedTags,omitempty"`
+	// DeferredTags are tags that were held back because they were
+	// published less than MinAge ago, or because they did not fit in
+	// MaxPullRequests.
+	DeferredTags    []SkippedTag `json:"deferredTags,omitempty"`
+	PullRequestURLs []string     `json:"pullRequestURLs,omitempty"`
+	// UnmappedImages are images that were found by the update strategy,
+	// but that are not mapped to config.yaml.
+	UnmappedImages []string      `json:"unmappedImages,omitempty"`
+	Error          string        `json:"error,omitempty"`
+	Duration       time.Duration `json:"-"`
+}
+
+func (result *EntryResult) setOutcome(outcome Outcome) {
+	if outcomePriority[outcome] >= outcomePriority[result.Outcome] {
+		result.Outcome = outcome
+	}
+}
+
+func (result EntryResult) MarshalJSON() ([]byte, error) {
+	type plainEntryResult EntryResult
+	return json.Marshal(struct {
+		plainEntryResult
+		DurationSeconds float64 `json:"durationSeconds"`
+	}{
+		plainEntryResult: plainEntryResult(result),
+		DurationSeconds:  result.Duration.Seconds(),
+	})
+}
+
+// Summary records the results of an autoupdate run.
+type Summary struct {
+	Entries []EntryResult `json:"entries"`
+}
+
+// WriteSummary writes summary to filePath in the given format, which may be
+// "json" or "markdown".
+func WriteSummary(filePath, format string, summary Summary) error {
+	var writeFunc func(io.Writer) error
+	switch format {
+	case "json":
+		writeFunc = summary.WriteJSON
+	case "markdown":
+		writeFunc = summary.WriteMarkdown
+	default:
+		return fmt.Errorf("unknown summary format %q", format)
+	}
+
+	file, err := os.Create(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer file.Close()
+	if err := writeFunc(file); err != nil {
+		return fmt.Errorf("failed to write summary: %w", err)
+	}
+	return file.Close()
+}
+
+func (summary Summary) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(summary)
+}
+
+// WriteMarkdown writes summary in a form that is suitable for
+// $GITHUB_STEP_SUMMARY.
+func (summary Summary) WriteMarkdown(w io.Writer) error {
+	builder := &strings.Builder{}
+	builder.WriteString("## Autoupdate summary\n\n")
+	builder.WriteString("| Entry | Outcome | Proposed tags | Pull requests | Duration |\n")
+	builder.WriteString("|-------|---------|---------------|---------------|----------|\n")
+	for _, result := range summary.Entries {
+		pullRequestLinks := make([]string, 0, len(result.PullRequestURLs))
+		for _, url := range result.PullRequestURLs {
+			pullRequestLinks = append(pullRequestLinks, "<"+url+">")
+		}
+		fmt.Fprintf(builder, "| `%s` | %s | %d | %s | %s |\n",
+			result.Name, result.Outcome, len(result.ProposedTags), strings.Join(pullRequestLinks, " "), result.Duration.Round(time.Millisecond))
+	}
+
+	for _, result := range summary.Entries {
+		if result.Error == "" && len(result.ProposedTags) == 0 && len(result.SkippedTags) == 0 && len(result.DeferredTags) == 0 && len(result.UnmappedImages) == 0 {
+			continue
+		}
+		fmt.Fprintf(builder, "\n### `%s`\n", result.Name)
+		if result.Error != "" {
+			fmt.Fprintf(builder, "\nError:\n```\n%s\n```\n", result.Error)
+		}
+		if len(result.ProposedTags) > 0 {
+			builder.WriteString("\nProposed tags:\n")
+			for _, proposedTag := range result.ProposedTags {
+				fmt.Fprintf(builder, "- `%s`\n", proposedTag)
+			}
+		}
+		if len(result.SkippedTags) > 0 {
+			builder.WriteString("\nSkipped tags:\n")
+			for _, skippedTag := range result.SkippedTags {
+				fmt.Fprintf(builder, "- `%s:%s`: %s\n", skippedTag.SourceArtifact, skippedTag.Tag, skippedTag.Reason)
+			}
+		}
+		if len(result.DeferredTags) > 0 {
+			builder.WriteString("\nDeferred tags:\n")
+			for _, deferredTag := range result.DeferredTags {
+				fmt.Fprintf(builder, "- `%s:%s`: %s\n", deferredTag.SourceArtifact, deferredTag.Tag, deferredTag.Reason)
+			}
+		}
+		if len(result.UnmappedImages) > 0 {
+			builder.WriteString("\nUnmapped images:\n")
+			for _, image := range result.UnmappedImages {
+				fmt.Fprintf(builder, "- `%s`\n", image)
+			}
+		}
+	}
+
+	_, err := io.WriteString(w, builder.String())
+	return err
+}
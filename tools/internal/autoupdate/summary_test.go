@@ -0,0 +1,67 @@
+package autoupdate
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSummary(t *testing.T) {
+	summary := Summary{
+		Entries: []EntryResult{
+			{
+				Name:            "test-entry",
+				Outcome:         OutcomePullRequestCreated,
+				ProposedTags:    []string{"test-org/test-artifact:v1.1.0"},
+				SkippedTags:     []SkippedTag{{SourceArtifact: "test-org/test-artifact", Tag: "v1.2.0", Reason: "tag not found"}},
+				DeferredTags:    []SkippedTag{{SourceArtifact: "test-org/test-artifact", Tag: "v1.3.0", Reason: "publish time is unknown"}},
+				PullRequestURLs: []string{"https://codehost.example/pulls/1"},
+				UnmappedImages:  []string{"test-org/unmapped:v1.0.0"},
+				Duration:        1500 * time.Millisecond,
+			},
+			{
+				Name:     "failing-entry",
+				Outcome:  OutcomeError,
+				Error:    "something went wrong",
+				Duration: time.Second,
+			},
+		},
+	}
+
+	t.Run("WriteJSON should include duration in seconds", func(t *testing.T) {
+		buffer := &bytes.Buffer{}
+		assert.NoError(t, summary.WriteJSON(buffer))
+
+		var decoded map[string][]map[string]any
+		assert.NoError(t, json.Unmarshal(buffer.Bytes(), &decoded))
+		assert.Len(t, decoded["entries"], 2)
+		assert.Equal(t, "test-entry", decoded["entries"][0]["name"])
+		assert.Equal(t, "pull request created", decoded["entries"][0]["outcome"])
+		assert.Equal(t, 1.5, decoded["entries"][0]["durationSeconds"])
+		assert.Equal(t, "something went wrong", decoded["entries"][1]["error"])
+	})
+
+	t.Run("WriteMarkdown should include a row and details for each entry", func(t *testing.T) {
+		buffer := &bytes.Buffer{}
+		assert.NoError(t, summary.WriteMarkdown(buffer))
+
+		output := buffer.String()
+		assert.Contains(t, output, "| `test-entry` | pull request created | 1 | <https://codehost.example/pulls/1> | 1.5s |")
+		assert.Contains(t, output, "| `failing-entry` | error | 0 |  | 1s |")
+		assert.Contains(t, output, "- `test-org/test-artifact:v1.1.0`")
+		assert.Contains(t, output, "- `test-org/test-artifact:v1.2.0`: tag not found")
+		assert.Contains(t, output, "Deferred tags:\n- `test-org/test-artifact:v1.3.0`: publish time is unknown")
+		assert.Contains(t, output, "Unmapped images:\n- `test-org/unmapped:v1.0.0`")
+		assert.Contains(t, output, "```\nsomething went wrong\n```")
+	})
+
+	t.Run("setOutcome should keep the outcome with the highest priority", func(t *testing.T) {
+		result := EntryResult{Outcome: OutcomeNoUpdates}
+		result.setOutcome(OutcomePullRequestCreated)
+		result.setOutcome(OutcomeExistingPullRequest)
+		assert.Equal(t, OutcomePullRequestCreated, result.Outcome)
+	})
+}
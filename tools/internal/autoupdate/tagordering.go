@@ -0,0 +1,173 @@
+package autoupdate
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// TagOrdering determines how tags are compared to find the latest one.
+type TagOrdering string
+
+const (
+	// TagOrderingSemver compares tags as semantic versions.
+	TagOrderingSemver TagOrdering = "Semver"
+	// TagOrderingCalendar compares tags that consist of numeric parts
+	// separated by ".", "-" or "_", such as 2024.01.15 or 24.04.
+	TagOrderingCalendar TagOrdering = "Calendar"
+	// TagOrderingAppCo compares tags in the format used by the SUSE
+	// Application Collection, such as 1.2.3-4.5, where the suffix is a
+	// build revision rather than a prerelease.
+	TagOrderingAppCo TagOrdering = "AppCo"
+	// TagOrderingLexical compares tags as strings.
+	TagOrderingLexical TagOrdering = "Lexical"
+	// TagOrderingCreationTime compares tags by the time the registry
+	// reports they were created.
+	TagOrderingCreationTime TagOrdering = "CreationTime"
+)
+
+var calendarPartSeparator = regexp.MustCompile(`[.\-_]`)
+var appCoTagRegex = regexp.MustCompile(`^(v?\d+(?:\.\d+){0,2})(?:-(\d+(?:\.\d+)*))?$`)
+
+func (o TagOrdering) Validate() error {
+	switch o {
+	case "", TagOrderingSemver, TagOrderingCalendar, TagOrderingAppCo, TagOrderingLexical, TagOrderingCreationTime:
+		return nil
+	default:
+		return fmt.Errorf("unknown tag ordering %q", o)
+	}
+}
+
+// SortTags returns tags sorted newest first. Tags that cannot be parsed
+// according to the ordering are dropped. creationTimes is only used by
+// TagOrderingCreationTime. The returned tags are always the original
+// strings that were passed in.
+func (o TagOrdering) SortTags(tags []string, creationTimes map[string]time.Time) ([]string, error) {
+	type sortableTag struct {
+		tag string
+		key any
+	}
+	parse, compare, err := o.keyFuncs(creationTimes)
+	if err != nil {
+		return nil, err
+	}
+
+	sortableTags := make([]sortableTag, 0, len(tags))
+	for _, tag := range tags {
+		key, err := parse(tag)
+		if err != nil {
+			continue
+		}
+		sortableTags = append(sortableTags, sortableTag{tag: tag, key: key})
+	}
+	slices.SortStableFunc(sortableTags, func(a, b sortableTag) int {
+		if value := compare(b.key, a.key); value != 0 {
+			return value
+		}
+		return strings.Compare(b.tag, a.tag)
+	})
+
+	sortedTags := make([]string, 0, len(sortableTags))
+	for _, sortable := range sortableTags {
+		sortedTags = append(sortedTags, sortable.tag)
+	}
+	return sortedTags, nil
+}
+
+// keyFuncs returns a function that parses a tag into a key, and a function
+// that compares two such keys according to the ordering. creationTimes is
+// only used by TagOrderingCreationTime.
+func (o TagOrdering) keyFuncs(creationTimes map[string]time.Time) (func(string) (any, error), func(a, b any) int, error) {
+	switch o {
+	case "", TagOrderingSemver:
+		parse := func(tag string) (any, error) {
+			return semver.NewVersion(tag)
+		}
+		compare := func(a, b any) int {
+			return a.(*semver.Version).Compare(b.(*semver.Version))
+		}
+		return parse, compare, nil
+	case TagOrderingCalendar:
+		parse := func(tag string) (any, error) {
+			return parseNumericParts(calendarPartSeparator.Split(strings.TrimPrefix(tag, "v"), -1))
+		}
+		compare := func(a, b any) int {
+			return slices.Compare(a.([]int), b.([]int))
+		}
+		return parse, compare, nil
+	case TagOrderingAppCo:
+		compare := func(a, b any) int {
+			return compareAppCoTags(a.(appCoTag), b.(appCoTag))
+		}
+		return parseAppCoTag, compare, nil
+	case TagOrderingLexical:
+		parse := func(tag string) (any, error) {
+			return tag, nil
+		}
+		compare := func(a, b any) int {
+			return strings.Compare(a.(string), b.(string))
+		}
+		return parse, compare, nil
+	case TagOrderingCreationTime:
+		parse := func(tag string) (any, error) {
+			creationTime, ok := creationTimes[tag]
+			if !ok {
+				return nil, errors.New("creation time unknown")
+			}
+			return creationTime, nil
+		}
+		compare := func(a, b any) int {
+			return a.(time.Time).Compare(b.(time.Time))
+		}
+		return parse, compare, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown tag ordering %q", o)
+	}
+}
+
+type appCoTag struct {
+	version  *semver.Version
+	revision []int
+}
+
+func parseAppCoTag(tag string) (any, error) {
+	matches := appCoTagRegex.FindStringSubmatch(tag)
+	if matches == nil {
+		return nil, fmt.Errorf("%q is not in the format <version>-<revision>", tag)
+	}
+	version, err := semver.NewVersion(matches[1])
+	if err != nil {
+		return nil, err
+	}
+	revision := []int{}
+	if matches[2] != "" {
+		revision, err = parseNumericParts(strings.Split(matches[2], "."))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return appCoTag{version: version, revision: revision}, nil
+}
+
+func compareAppCoTags(a, b appCoTag) int {
+	return cmp.Or(a.version.Compare(b.version), slices.Compare(a.revision, b.revision))
+}
+
+func parseNumericParts(parts []string) ([]int, error) {
+	numbers := make([]int, 0, len(parts))
+	for _, part := range parts {
+		number, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not numeric", part)
+		}
+		numbers = append(numbers, number)
+	}
+	return numbers, nil
+}
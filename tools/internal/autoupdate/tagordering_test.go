@@ -0,0 +1,75 @@
+package autoupdate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTagOrdering(t *testing.T) {
+	t.Run("SortTags", func(t *testing.T) {
+		now := time.Now()
+		type testCase struct {
+			Message       string
+			Ordering      TagOrdering
+			Tags          []string
+			CreationTimes map[string]time.Time
+			ExpectedTags  []string
+		}
+		testCases := []testCase{
+			{
+				Message:      "should sort semver tags and preserve the original strings",
+				Ordering:     TagOrderingSemver,
+				Tags:         []string{"v1.2", "v1.10.0", "v1.9.1", "latest"},
+				ExpectedTags: []string{"v1.10.0", "v1.9.1", "v1.2"},
+			},
+			{
+				Message:      "should default to semver",
+				Ordering:     "",
+				Tags:         []string{"1.2.0", "1.10.0"},
+				ExpectedTags: []string{"1.10.0", "1.2.0"},
+			},
+			{
+				Message:      "should sort calendar versions numerically",
+				Ordering:     TagOrderingCalendar,
+				Tags:         []string{"2024.09.1", "2024.10.0", "2023.12.31", "24.04", "nightly"},
+				ExpectedTags: []string{"2024.10.0", "2024.09.1", "2023.12.31", "24.04"},
+			},
+			{
+				Message:      "should sort appco tags by version and then revision",
+				Ordering:     TagOrderingAppCo,
+				Tags:         []string{"1.2.3-4.5", "1.2.3-4.10", "1.2.3", "1.2.2-9.9", "1.2.3-rc1"},
+				ExpectedTags: []string{"1.2.3-4.10", "1.2.3-4.5", "1.2.3", "1.2.2-9.9"},
+			},
+			{
+				Message:      "should sort lexically",
+				Ordering:     TagOrderingLexical,
+				Tags:         []string{"b", "c", "a"},
+				ExpectedTags: []string{"c", "b", "a"},
+			},
+			{
+				Message:  "should sort by creation time and drop tags without one",
+				Ordering: TagOrderingCreationTime,
+				Tags:     []string{"old", "new", "unknown"},
+				CreationTimes: map[string]time.Time{
+					"old": now.Add(-time.Hour),
+					"new": now,
+				},
+				ExpectedTags: []string{"new", "old"},
+			},
+		}
+		for _, testCase := range testCases {
+			t.Run(testCase.Message, func(t *testing.T) {
+				sortedTags, err := testCase.Ordering.SortTags(testCase.Tags, testCase.CreationTimes)
+				assert.NoError(t, err)
+				assert.Equal(t, testCase.ExpectedTags, sortedTags)
+			})
+		}
+	})
+
+	t.Run("Validate", func(t *testing.T) {
+		assert.NoError(t, TagOrderingAppCo.Validate())
+		assert.EqualError(t, TagOrdering("asdf").Validate(), `unknown tag ordering "asdf"`)
+	})
+}
@@ -0,0 +1,202 @@
+package autoupdate
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/rancher/artifact-mirror/internal/config"
+)
+
+const (
+	MinimumVersionOldest = "Oldest"
+	MinimumVersionNewest = "Newest"
+)
+
+// VersionPolicy selects which of the tags found by an update strategy are
+// proposed. It is applied in the same way regardless of the strategy, and
+// to each artifact separately. Tags that are not valid semantic versions
+// are dropped when a VersionPolicy is in use.
+type VersionPolicy struct {
+	// Constraint is a semver constraint that tags must satisfy.
+	Constraint         string              `json:",omitempty"`
+	compiledConstraint *semver.Constraints `json:"-"`
+	// MinimumVersion drops tags based on the tags that are already present
+	// for the artifact in config.yaml. If "Oldest", tags older than the
+	// oldest present tag are dropped. If "Newest", tags that are not newer
+	// than the newest present tag are dropped.
+	MinimumVersion string `json:",omitempty"`
+	// LatestPerMajor keeps only the latest tag of each major version.
+	LatestPerMajor bool `json:",omitempty"`
+	// LatestPatchPerMinor keeps only the latest tag of each minor version.
+	LatestPatchPerMinor bool `json:",omitempty"`
+	// KeepLatest keeps only the given number of latest tags. It is applied
+	// after the other fields.
+	KeepLatest int `json:",omitempty"`
+}
+
+type taggedVersion struct {
+	tag     string
+	version *semver.Version
+}
+
+func (vp *VersionPolicy) Validate() error {
+	if vp.Constraint != "" {
+		compiledConstraint, err := semver.NewConstraint(vp.Constraint)
+		if err != nil {
+			return fmt.Errorf("invalid Constraint: %w", err)
+		}
+		vp.compiledConstraint = compiledConstraint
+	}
+	switch vp.MinimumVersion {
+	case "", MinimumVersionOldest, MinimumVersionNewest:
+	default:
+		return fmt.Errorf("MinimumVersion must be %q or %q", MinimumVersionOldest, MinimumVersionNewest)
+	}
+	if vp.LatestPerMajor && vp.LatestPatchPerMinor {
+		return errors.New("must not specify both LatestPerMajor and LatestPatchPerMinor")
+	}
+	if vp.KeepLatest < 0 {
+		return errors.New("KeepLatest must not be negative")
+	}
+	return nil
+}
+
+// strategySelection returns whether the update strategy of entry finds only
+// the latest release or tag, through LatestOnly or Latest, and the
+// VersionConstraint that it applies, if any.
+func (entry ConfigEntry) strategySelection() (bool, string) {
+	switch {
+	case entry.GithubRelease != nil:
+		return entry.GithubRelease.LatestOnly, entry.GithubRelease.VersionConstraint
+	case entry.GitTag != nil:
+		return false, entry.GitTag.VersionConstraint
+	case entry.HelmChart != nil:
+		return false, entry.HelmChart.VersionConstraint
+	case entry.Manifest != nil:
+		return entry.Manifest.LatestOnly, entry.Manifest.VersionConstraint
+	case entry.Registry != nil:
+		return entry.Registry.Latest, ""
+	case entry.ReleaseAsset != nil:
+		return entry.ReleaseAsset.LatestOnly, entry.ReleaseAsset.VersionConstraint
+	default:
+		return false, ""
+	}
+}
+
+// validateVersions rejects Versions fields that conflict with the fields
+// of the update strategy that select versions. The update strategy selects
+// first, and Versions is applied to what it finds, so a second constraint
+// or a second way of picking the latest tags would only be confusing.
+func (entry ConfigEntry) validateVersions() error {
+	latestOnly, versionConstraint := entry.strategySelection()
+	if entry.Versions.Constraint != "" && versionConstraint != "" {
+		return errors.New("must not specify both Versions.Constraint and VersionConstraint")
+	}
+	if latestOnly && (entry.Versions.Constraint != "" || entry.Versions.LatestPerMajor || entry.Versions.LatestPatchPerMinor || entry.Versions.KeepLatest > 0) {
+		return errors.New("must only specify MinimumVersion in Versions when LatestOnly or Latest is true")
+	}
+	return nil
+}
+
+// SelectTags returns the tags that satisfy the policy, newest first.
+// existingTags are the tags already present for the artifact in config.yaml.
+func (vp *VersionPolicy) SelectTags(tags, existingTags []string) []string {
+	versions := parseTaggedVersions(tags)
+
+	if vp.compiledConstraint != nil {
+		versions = slices.DeleteFunc(versions, func(tv taggedVersion) bool {
+			return !vp.compiledConstraint.Check(tv.version)
+		})
+	}
+
+	if existingVersions := parseTaggedVersions(existingTags); vp.MinimumVersion != "" && len(existingVersions) > 0 {
+		switch vp.MinimumVersion {
+		case MinimumVersionOldest:
+			oldest := existingVersions[len(existingVersions)-1].version
+			versions = slices.DeleteFunc(versions, func(tv taggedVersion) bool {
+				return tv.version.LessThan(oldest)
+			})
+		case MinimumVersionNewest:
+			newest := existingVersions[0].version
+			versions = slices.DeleteFunc(versions, func(tv taggedVersion) bool {
+				return !tv.version.GreaterThan(newest)
+			})
+		}
+	}
+
+	if vp.LatestPerMajor {
+		versions = latestPerGroup(versions, func(v *semver.Version) string {
+			return fmt.Sprintf("%d", v.Major())
+		})
+	}
+	if vp.LatestPatchPerMinor {
+		versions = latestPerGroup(versions, func(v *semver.Version) string {
+			return fmt.Sprintf("%d.%d", v.Major(), v.Minor())
+		})
+	}
+
+	if vp.KeepLatest > 0 && len(versions) > vp.KeepLatest {
+		versions = versions[:vp.KeepLatest]
+	}
+
+	selectedTags := make([]string, 0, len(versions))
+	for _, tv := range versions {
+		selectedTags = append(selectedTags, tv.tag)
+	}
+	return selectedTags
+}
+
+// SelectArtifactTags applies the policy to the tags of each artifact.
+func (vp *VersionPolicy) SelectArtifactTags(artifacts []*config.Artifact, configYaml *config.Config) []*config.Artifact {
+	selectedArtifacts := make([]*config.Artifact, 0, len(artifacts))
+	for _, artifact := range artifacts {
+		existingTags := make([]string, 0)
+		for _, existingArtifact := range configYaml.Artifacts {
+			if config.CompareArtifacts(artifact, existingArtifact) == 0 {
+				existingTags = append(existingTags, existingArtifact.Tags...)
+			}
+		}
+		selectedArtifact := artifact.DeepCopy()
+		selectedArtifact.Tags = vp.SelectTags(artifact.Tags, existingTags)
+		if len(selectedArtifact.Tags) == 0 {
+			continue
+		}
+		selectedArtifacts = append(selectedArtifacts, selectedArtifact)
+	}
+	return selectedArtifacts
+}
+
+// parseTaggedVersions parses tags as semantic versions, dropping any that
+// cannot be parsed. The result is sorted newest first.
+func parseTaggedVersions(tags []string) []taggedVersion {
+	versions := make([]taggedVersion, 0, len(tags))
+	for _, tag := range tags {
+		version, err := semver.NewVersion(tag)
+		if err != nil {
+			continue
+		}
+		versions = append(versions, taggedVersion{tag: tag, version: version})
+	}
+	slices.SortStableFunc(versions, func(a, b taggedVersion) int {
+		return b.version.Compare(a.version)
+	})
+	return versions
+}
+
+// latestPerGroup keeps the first of versions for each key returned by
+// keyFunc. versions must be sorted newest first.
+func latestPerGroup(versions []taggedVersion, keyFunc func(*semver.Version) string) []taggedVersion {
+	seen := map[string]struct{}{}
+	latest := make([]taggedVersion, 0, len(versions))
+	for _, tv := range versions {
+		key := keyFunc(tv.version)
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		latest = append(latest, tv)
+	}
+	return latest
+}
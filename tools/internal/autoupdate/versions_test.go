@@ -0,0 +1,143 @@
+package autoupdate
+
+import (
+	"testing"
+
+	"github.com/rancher/artifact-mirror/internal/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVersionPolicy(t *testing.T) {
+	t.Run("Validate", func(t *testing.T) {
+		type testCase struct {
+			Message       string
+			VersionPolicy *VersionPolicy
+			ExpectedError string
+		}
+		testCases := []testCase{
+			{
+				Message: "should return nil for a valid VersionPolicy",
+				VersionPolicy: &VersionPolicy{
+					Constraint:          ">=1.2.3",
+					MinimumVersion:      MinimumVersionNewest,
+					LatestPatchPerMinor: true,
+					KeepLatest:          3,
+				},
+			},
+			{
+				Message:       "should return error for invalid Constraint",
+				VersionPolicy: &VersionPolicy{Constraint: "InvalidConstraint"},
+				ExpectedError: "invalid Constraint: improper constraint: InvalidConstraint",
+			},
+			{
+				Message:       "should return error for invalid MinimumVersion",
+				VersionPolicy: &VersionPolicy{MinimumVersion: "asdf"},
+				ExpectedError: `MinimumVersion must be "Oldest" or "Newest"`,
+			},
+			{
+				Message:       "should return error when both LatestPerMajor and LatestPatchPerMinor are set",
+				VersionPolicy: &VersionPolicy{LatestPerMajor: true, LatestPatchPerMinor: true},
+				ExpectedError: "must not specify both LatestPerMajor and LatestPatchPerMinor",
+			},
+			{
+				Message:       "should return error for negative KeepLatest",
+				VersionPolicy: &VersionPolicy{KeepLatest: -1},
+				ExpectedError: "KeepLatest must not be negative",
+			},
+		}
+		for _, testCase := range testCases {
+			t.Run(testCase.Message, func(t *testing.T) {
+				err := testCase.VersionPolicy.Validate()
+				if testCase.ExpectedError == "" {
+					assert.NoError(t, err)
+				} else {
+					assert.EqualError(t, err, testCase.ExpectedError)
+				}
+			})
+		}
+	})
+
+	t.Run("SelectTags", func(t *testing.T) {
+		tags := []string{"v1.30.1", "v1.31.0", "v1.30.2", "v2.0.0", "v1.29.9", "latest", "v1.31.1-rc.1"}
+		type testCase struct {
+			Message       string
+			VersionPolicy *VersionPolicy
+			ExistingTags  []string
+			ExpectedTags  []string
+		}
+		testCases := []testCase{
+			{
+				Message:       "should return all semver tags newest first for an empty policy",
+				VersionPolicy: &VersionPolicy{},
+				ExpectedTags:  []string{"v2.0.0", "v1.31.1-rc.1", "v1.31.0", "v1.30.2", "v1.30.1", "v1.29.9"},
+			},
+			{
+				Message:       "should return only tags that satisfy Constraint",
+				VersionPolicy: &VersionPolicy{Constraint: ">=1.30.0, <2.0.0"},
+				ExpectedTags:  []string{"v1.31.0", "v1.30.2", "v1.30.1"},
+			},
+			{
+				Message:       "should drop tags older than the oldest existing tag",
+				VersionPolicy: &VersionPolicy{MinimumVersion: MinimumVersionOldest},
+				ExistingTags:  []string{"v1.30.2", "v1.30.1"},
+				ExpectedTags:  []string{"v2.0.0", "v1.31.1-rc.1", "v1.31.0", "v1.30.2", "v1.30.1"},
+			},
+			{
+				Message:       "should drop tags not newer than the newest existing tag",
+				VersionPolicy: &VersionPolicy{MinimumVersion: MinimumVersionNewest},
+				ExistingTags:  []string{"v1.30.2", "v1.31.0"},
+				ExpectedTags:  []string{"v2.0.0", "v1.31.1-rc.1"},
+			},
+			{
+				Message:       "should keep only the latest tag of each major version",
+				VersionPolicy: &VersionPolicy{LatestPerMajor: true},
+				ExpectedTags:  []string{"v2.0.0", "v1.31.1-rc.1"},
+			},
+			{
+				Message:       "should keep only the latest patch of each minor version",
+				VersionPolicy: &VersionPolicy{Constraint: "<2.0.0", LatestPatchPerMinor: true},
+				ExpectedTags:  []string{"v1.31.0", "v1.30.2", "v1.29.9"},
+			},
+			{
+				Message:       "should keep only the given number of latest tags",
+				VersionPolicy: &VersionPolicy{LatestPatchPerMinor: true, KeepLatest: 2},
+				ExpectedTags:  []string{"v2.0.0", "v1.31.1-rc.1"},
+			},
+		}
+		for _, testCase := range testCases {
+			t.Run(testCase.Message, func(t *testing.T) {
+				assert.NoError(t, testCase.VersionPolicy.Validate())
+				selectedTags := testCase.VersionPolicy.SelectTags(tags, testCase.ExistingTags)
+				assert.Equal(t, testCase.ExpectedTags, selectedTags)
+			})
+		}
+	})
+
+	t.Run("SelectArtifactTags", func(t *testing.T) {
+		t.Run("should use tags of the matching config.yaml artifact", func(t *testing.T) {
+			existingArtifact, err := config.NewArtifact("test-org/test-artifact", []string{"v1.1.0"}, "", nil, nil)
+			assert.NoError(t, err)
+			otherArtifact, err := config.NewArtifact("test-org/test-artifact", []string{"v1.5.0"}, "other-name", nil, nil)
+			assert.NoError(t, err)
+			configYaml := &config.Config{Artifacts: []*config.Artifact{existingArtifact, otherArtifact}}
+			newArtifact, err := config.NewArtifact("test-org/test-artifact", []string{"v1.0.0", "v1.1.0", "v1.2.0"}, "", nil, nil)
+			assert.NoError(t, err)
+
+			versionPolicy := &VersionPolicy{MinimumVersion: MinimumVersionNewest}
+			assert.NoError(t, versionPolicy.Validate())
+			selectedArtifacts := versionPolicy.SelectArtifactTags([]*config.Artifact{newArtifact}, configYaml)
+			assert.Len(t, selectedArtifacts, 1)
+			assert.Equal(t, []string{"v1.2.0"}, selectedArtifacts[0].Tags)
+		})
+
+		t.Run("should drop artifacts without selected tags", func(t *testing.T) {
+			newArtifact, err := config.NewArtifact("test-org/test-artifact", []string{"latest"}, "", nil, nil)
+			assert.NoError(t, err)
+
+			versionPolicy := &VersionPolicy{}
+			selectedArtifacts := versionPolicy.SelectArtifactTags([]*config.Artifact{newArtifact}, &config.Config{})
+			assert.Empty(t, selectedArtifacts)
+		})
+	})
+}
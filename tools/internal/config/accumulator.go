@@ -58,6 +58,7 @@ func (ia *ArtifactAccumulator) TagDifference(artifact *Artifact) (*Artifact, err
 	if err != nil {
 		return nil, fmt.Errorf("failed to construct new artifact from passed artifact: %w", err)
 	}
+	artifactToReturn.RequiredPlatforms = slices.Clone(artifact.RequiredPlatforms)
 	for _, tag := range artifact.Tags {
 		if !slices.Contains(existingArtifact.Tags, tag) {
 			artifactToReturn.Tags = append(artifactToReturn.Tags, tag)
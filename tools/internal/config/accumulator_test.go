@@ -45,9 +45,11 @@ func TestArtifactAccumulator(t *testing.T) {
 			accumulator.AddArtifacts(artifact1)
 			artifact2, err := NewArtifact("test-org/artifact", []string{"asdf", "qwer"}, "", nil, nil)
 			assert.Nil(t, err)
+			artifact2.RequiredPlatforms = []string{"linux/amd64"}
 			diffArtifact, err := accumulator.TagDifference(artifact2)
 			assert.Nil(t, err)
 			assert.Equal(t, diffArtifact.Tags, []string{"asdf"})
+			assert.Equal(t, diffArtifact.RequiredPlatforms, []string{"linux/amd64"})
 		})
 
 		t.Run("should return nil for artifact if all tags are accounted for", func(t *testing.T) {
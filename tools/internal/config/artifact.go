@@ -7,6 +7,7 @@ import (
 	"slices"
 	"strings"
 
+	"github.com/rancher/artifact-mirror/internal/reference"
 	"github.com/rancher/artifact-mirror/internal/regsync"
 )
 
@@ -21,6 +22,10 @@ type Artifact struct {
 	// The source artifact without any tags.
 	SourceArtifact            string
 	defaultTargetArtifactName string
+	// The platforms, in the form os/arch[/variant], that every tag must
+	// provide. Overrides the RequiredPlatforms of the target Repositories.
+	// See Config.RequiredPlatforms().
+	RequiredPlatforms []string `json:",omitempty"`
 	// Set via DoNotMirror.
 	excludeAllTags bool
 	// Set via DoNotMirror.
@@ -134,16 +139,15 @@ func (artifact *Artifact) CombineSourceArtifactAndTags() []string {
 func (artifact *Artifact) ToRegsyncArtifacts(repositories []Repository) ([]regsync.ConfigSync, error) {
 	entries := make([]regsync.ConfigSync, 0)
 	for _, repository := range repositories {
-		if !repository.DefaultTarget && len(artifact.TargetRepositories) == 0 {
-			continue
-		}
-		if len(artifact.TargetRepositories) > 0 && !slices.Contains(artifact.TargetRepositories, repository.BaseUrl) {
+		if !artifact.targetsRepository(repository) {
 			continue
 		}
 		// do not include if source and destination artifacts are the same
-		trimmedSourceArtifact := strings.TrimPrefix(artifact.SourceArtifact, "docker.io/")
-		trimmedTargetArtifact := strings.TrimPrefix(repository.BaseUrl+"/"+artifact.TargetArtifactName(), "docker.io/")
-		if trimmedSourceArtifact == trimmedTargetArtifact {
+		sameArtifact, err := reference.SameRepository(artifact.SourceArtifact, repository.BaseUrl+"/"+artifact.TargetArtifactName())
+		if err != nil {
+			return nil, fmt.Errorf("failed to compare source and target of Artifact with SourceArtifact %q: %w", artifact.SourceArtifact, err)
+		}
+		if sameArtifact {
 			continue
 		}
 		syncEntries, err := artifact.ToRegsyncArtifactsForSingleRepository(repository)
@@ -155,6 +159,14 @@ func (artifact *Artifact) ToRegsyncArtifacts(repositories []Repository) ([]regsy
 	return entries, nil
 }
 
+// targetsRepository returns whether artifact is mirrored to repository.
+func (artifact *Artifact) targetsRepository(repository Repository) bool {
+	if len(artifact.TargetRepositories) == 0 {
+		return repository.DefaultTarget
+	}
+	return slices.Contains(artifact.TargetRepositories, repository.BaseUrl)
+}
+
 // ToRegsyncArtifactsForSingleRepository converts artifact into one ConfigSync
 // (i.e. an artifact for regsync to sync) for each tag present in artifact.
 // repo provides the target repository for each ConfigSync.
@@ -183,6 +195,7 @@ func (artifact *Artifact) ToRegsyncArtifactsForSingleRepository(repo Repository)
 func (artifact *Artifact) DeepCopy() *Artifact {
 	copiedArtifact := &Artifact{
 		DoNotMirror:                 artifact.DoNotMirror,
+		RequiredPlatforms:           slices.Clone(artifact.RequiredPlatforms),
 		SourceArtifact:              artifact.SourceArtifact,
 		defaultTargetArtifactName:   artifact.defaultTargetArtifactName,
 		SpecifiedTargetArtifactName: artifact.SpecifiedTargetArtifactName,
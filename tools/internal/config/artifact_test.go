@@ -268,6 +268,7 @@ func TestArtifact(t *testing.T) {
 		t.Run("should copy all fields", func(t *testing.T) {
 			original, err := NewArtifact("test-org/test-artifact", []string{"v1.0.0", "v2.0.0"}, "custom-image-name", []any{"v1.0.0"}, nil)
 			assert.NoError(t, err)
+			original.RequiredPlatforms = []string{"linux/amd64"}
 
 			copy := original.DeepCopy()
 
@@ -279,6 +280,7 @@ func TestArtifact(t *testing.T) {
 			assert.Equal(t, original.excludeAllTags, copy.excludeAllTags)
 			assert.Equal(t, original.excludedTags, copy.excludedTags)
 			assert.Equal(t, original.TargetRepositories, copy.TargetRepositories)
+			assert.Equal(t, original.RequiredPlatforms, copy.RequiredPlatforms)
 		})
 	})
 }
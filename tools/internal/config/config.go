@@ -13,6 +13,11 @@ import (
 	"sigs.k8s.io/yaml"
 )
 
+// DefaultRequiredPlatforms are the platforms that every tag of an Artifact
+// must provide if neither the Artifact nor any of its target Repositories
+// set RequiredPlatforms.
+var DefaultRequiredPlatforms = []string{"linux/amd64", "linux/arm64"}
+
 type Config struct {
 	Artifacts    []*Artifact
 	Repositories []Repository
@@ -40,6 +45,10 @@ type Repository struct {
 	// repository. For more information please see
 	// https://github.com/regclient/regclient/blob/main/docs/regsync.md
 	RepoAuth bool `json:",omitempty"`
+	// RequiredPlatforms are the platforms, in the form os/arch[/variant],
+	// that every tag of the Artifacts mirrored to this Repository must
+	// provide. See Config.RequiredPlatforms().
+	RequiredPlatforms []string `json:",omitempty"`
 	// ReqConcurrent is what goes into the "reqConcurrent" field of
 	// regsync.yaml for this repository. For more information please see
 	// https://github.com/regclient/regclient/blob/main/docs/regsync.md
@@ -134,6 +143,32 @@ func (config *Config) ToRegsyncConfig() (regsync.Config, error) {
 	return regsyncYaml, nil
 }
 
+// RequiredPlatforms returns the platforms that every tag of artifact must
+// provide. These are the RequiredPlatforms of artifact if set, or else the
+// RequiredPlatforms of all Repositories that artifact is mirrored to. If
+// neither are set, DefaultRequiredPlatforms is returned.
+func (config *Config) RequiredPlatforms(artifact *Artifact) []string {
+	if len(artifact.RequiredPlatforms) > 0 {
+		return slices.Clone(artifact.RequiredPlatforms)
+	}
+	platforms := make([]string, 0)
+	for _, repository := range config.Repositories {
+		if !artifact.targetsRepository(repository) {
+			continue
+		}
+		for _, platform := range repository.RequiredPlatforms {
+			if !slices.Contains(platforms, platform) {
+				platforms = append(platforms, platform)
+			}
+		}
+	}
+	if len(platforms) == 0 {
+		return slices.Clone(DefaultRequiredPlatforms)
+	}
+	slices.Sort(platforms)
+	return platforms
+}
+
 func (config *Config) DeepCopy() *Config {
 	copiedConfig := &Config{
 		Artifacts:    make([]*Artifact, 0, len(config.Artifacts)),
@@ -37,4 +37,59 @@ func TestConfig(t *testing.T) {
 			assert.Len(t, regsyncYaml.Creds, 2)
 		})
 	})
+	t.Run("RequiredPlatforms", func(t *testing.T) {
+		config := &Config{
+			Repositories: []Repository{
+				{
+					BaseUrl:       "docker.io/rancher",
+					DefaultTarget: true,
+				},
+				{
+					BaseUrl:           "registry.rancher.com/rancher",
+					DefaultTarget:     true,
+					RequiredPlatforms: []string{"linux/s390x", "linux/amd64"},
+				},
+				{
+					BaseUrl:           "registry.example.com/rancher",
+					RequiredPlatforms: []string{"windows/amd64"},
+				},
+			},
+		}
+
+		type testCase struct {
+			Message            string
+			RequiredPlatforms  []string
+			TargetRepositories []string
+			Expected           []string
+		}
+		testCases := []testCase{
+			{
+				Message:           "should use the RequiredPlatforms of the artifact if set",
+				RequiredPlatforms: []string{"linux/amd64"},
+				Expected:          []string{"linux/amd64"},
+			},
+			{
+				Message:  "should combine the RequiredPlatforms of the default target repositories",
+				Expected: []string{"linux/amd64", "linux/s390x"},
+			},
+			{
+				Message:            "should only use the RequiredPlatforms of TargetRepositories",
+				TargetRepositories: []string{"docker.io/rancher", "registry.example.com/rancher"},
+				Expected:           []string{"windows/amd64"},
+			},
+			{
+				Message:            "should default to DefaultRequiredPlatforms",
+				TargetRepositories: []string{"docker.io/rancher"},
+				Expected:           []string{"linux/amd64", "linux/arm64"},
+			},
+		}
+		for _, testCase := range testCases {
+			t.Run(testCase.Message, func(t *testing.T) {
+				artifact, err := NewArtifact("rancher/rancher", []string{"v2.12.0"}, "", nil, testCase.TargetRepositories)
+				assert.NoError(t, err)
+				artifact.RequiredPlatforms = testCase.RequiredPlatforms
+				assert.Equal(t, testCase.Expected, config.RequiredPlatforms(artifact))
+			})
+		}
+	})
 }
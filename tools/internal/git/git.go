@@ -1,13 +1,15 @@
 package git
 
 import (
+	"context"
 	"fmt"
 	"os/exec"
 	"strings"
 )
 
-func IsWorkingTreeClean() (bool, error) {
-	cmd := exec.Command("git", "diff", "--quiet")
+func IsWorkingTreeClean(ctx context.Context) (bool, error) {
+	// Compare to HEAD so that staged changes are also detected.
+	cmd := exec.CommandContext(ctx, "git", "diff", "--quiet", "HEAD")
 	err := cmd.Run()
 	if err != nil {
 		if _, ok := err.(*exec.ExitError); ok {
@@ -19,13 +21,13 @@ func IsWorkingTreeClean() (bool, error) {
 	return true, nil
 }
 
-func CreateAndCheckoutBranch(baseBranch, branchName string) error {
-	baseCheckoutCmd := exec.Command("git", "checkout", baseBranch)
+func CreateAndCheckoutBranch(ctx context.Context, baseBranch, branchName string) error {
+	baseCheckoutCmd := exec.CommandContext(ctx, "git", "checkout", baseBranch)
 	if err := baseCheckoutCmd.Run(); err != nil {
 		return fmt.Errorf("failed to checkout %s: %w", baseBranch, err)
 	}
 
-	newCheckoutCmd := exec.Command("git", "checkout", "-b", branchName)
+	newCheckoutCmd := exec.CommandContext(ctx, "git", "checkout", "-b", branchName)
 	if err := newCheckoutCmd.Run(); err != nil {
 		return fmt.Errorf("failed to checkout new branch: %w", err)
 	}
@@ -33,24 +35,38 @@ func CreateAndCheckoutBranch(baseBranch, branchName string) error {
 	return nil
 }
 
-func Commit(msg string) error {
-	cmd := exec.Command("git", "commit", "--all", "--message", msg)
+func Commit(ctx context.Context, msg string) error {
+	cmd := exec.CommandContext(ctx, "git", "commit", "--all", "--message", msg)
 	if err := cmd.Run(); err != nil {
 		return fmt.Errorf("failed to run git commit: %w", err)
 	}
 	return nil
 }
 
-func PushBranch(branchName, remote string) error {
-	cmd := exec.Command("git", "push", remote, branchName)
+// DiscardChanges resets the index and working tree to HEAD, and checks
+// out branch.
+func DiscardChanges(ctx context.Context, branch string) error {
+	resetCmd := exec.CommandContext(ctx, "git", "reset", "--hard", "HEAD")
+	if out, err := resetCmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to run git reset: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	checkoutCmd := exec.CommandContext(ctx, "git", "checkout", branch)
+	if out, err := checkoutCmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to checkout %s: %w: %s", branch, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func PushBranch(ctx context.Context, branchName, remote string) error {
+	cmd := exec.CommandContext(ctx, "git", "push", remote, branchName)
 	if err := cmd.Run(); err != nil {
 		return fmt.Errorf("failed to run git push: %w", err)
 	}
 	return nil
 }
 
-func GetMergeBase(branch string) (string, error) {
-	cmd := exec.Command("git", "merge-base", "HEAD", branch)
+func GetMergeBase(ctx context.Context, branch string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", "merge-base", "HEAD", branch)
 	out, err := cmd.Output()
 	if err != nil {
 		return "", fmt.Errorf("failed to get merge base with %s: %w", branch, err)
@@ -58,11 +74,21 @@ func GetMergeBase(branch string) (string, error) {
 	return strings.TrimSpace(string(out)), nil
 }
 
-func GetFileContentAtCommit(commit, filePath string) ([]byte, error) {
-	cmd := exec.Command("git", "show", fmt.Sprintf("%s:%s", commit, filePath))
+func GetFileContentAtCommit(ctx context.Context, commit, filePath string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "git", "show", fmt.Sprintf("%s:%s", commit, filePath))
 	out, err := cmd.Output()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get file content for %s at commit %s: %w", filePath, commit, err)
 	}
 	return out, nil
 }
+
+// ShallowClone clones the commit that ref, a branch or tag, points to in the
+// repository at url into dir.
+func ShallowClone(ctx context.Context, url, ref, dir string) error {
+	cmd := exec.CommandContext(ctx, "git", "clone", "--quiet", "--depth", "1", "--branch", ref, url, dir)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to clone %s at %s: %w: %s", url, ref, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+// Package reference parses references to artifacts in OCI registries, such
+// as "rancher/rancher:v2.12.0" or "registry.example:5000/org/image@sha256:...".
+// It follows the same rules as docker: references without a registry refer
+// to Docker Hub, and Docker Hub references without a namespace refer to
+// the "library" namespace.
+package reference
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	// DockerHubRegistry is the registry used for references that do not
+	// specify one.
+	DockerHubRegistry = "docker.io"
+	// dockerHubNamespace is the namespace used for Docker Hub references
+	// that do not specify one.
+	dockerHubNamespace = "library"
+)
+
+var (
+	pathComponentRegex = regexp.MustCompile(`^[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*$`)
+	tagRegex           = regexp.MustCompile(`^[\w][\w.-]{0,127}$`)
+	digestRegex        = regexp.MustCompile(`^[a-z0-9]+(?:[.+_-][a-z0-9]+)*:[a-zA-Z0-9=_-]+$`)
+)
+
+// dockerHubAliases are registry names that refer to Docker Hub.
+var dockerHubAliases = map[string]struct{}{
+	DockerHubRegistry:      {},
+	"index.docker.io":      {},
+	"registry-1.docker.io": {},
+}
+
+// Reference is a parsed reference to an artifact. Registry and Repository
+// are always set; Tag and Digest are set if they were present.
+type Reference struct {
+	// Registry is the host of the registry, including the port if present.
+	Registry string
+	// Repository is the path of the artifact within the registry.
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+// Parse parses rawReference. The registry is normalized, so that all
+// references to Docker Hub have a Registry of "docker.io" and a
+// Repository with a namespace.
+func Parse(rawReference string) (Reference, error) {
+	if rawReference == "" {
+		return Reference{}, errors.New("reference is empty")
+	}
+
+	reference := Reference{}
+	remainder := rawReference
+	if name, digest, found := strings.Cut(remainder, "@"); found {
+		if !digestRegex.MatchString(digest) {
+			return Reference{}, fmt.Errorf("invalid digest %q in reference %q", digest, rawReference)
+		}
+		reference.Digest = digest
+		remainder = name
+	}
+	lastSlash := strings.LastIndex(remainder, "/")
+	if lastColon := strings.LastIndex(remainder, ":"); lastColon > lastSlash {
+		tag := remainder[lastColon+1:]
+		if !tagRegex.MatchString(tag) {
+			return Reference{}, fmt.Errorf("invalid tag %q in reference %q", tag, rawReference)
+		}
+		reference.Tag = tag
+		remainder = remainder[:lastColon]
+	}
+
+	registry, repository, found := strings.Cut(remainder, "/")
+	if !found || !isRegistry(registry) {
+		registry = DockerHubRegistry
+		repository = remainder
+	}
+	if _, ok := dockerHubAliases[registry]; ok {
+		registry = DockerHubRegistry
+		if !strings.Contains(repository, "/") {
+			repository = dockerHubNamespace + "/" + repository
+		}
+	}
+	for _, component := range strings.Split(repository, "/") {
+		if !pathComponentRegex.MatchString(component) {
+			return Reference{}, fmt.Errorf("invalid repository %q in reference %q", repository, rawReference)
+		}
+	}
+	reference.Registry = registry
+	reference.Repository = repository
+
+	return reference, nil
+}
+
+// isRegistry returns whether the first component of a reference is a
+// registry host, as opposed to the first component of a Docker Hub
+// repository.
+func isRegistry(component string) bool {
+	return strings.ContainsAny(component, ".:") || component == "localhost" || strings.ToLower(component) != component
+}
+
+// Name returns the fully qualified name of the repository, e.g.
+// "docker.io/library/nginx".
+func (reference Reference) Name() string {
+	return reference.Registry + "/" + reference.Repository
+}
+
+// ShortName returns the name of the repository in the form used in
+// config.yaml, which omits the registry for Docker Hub, e.g.
+// "library/nginx".
+func (reference Reference) ShortName() string {
+	if reference.Registry == DockerHubRegistry {
+		return reference.Repository
+	}
+	return reference.Name()
+}
+
+// String returns the fully qualified reference, including the tag and the
+// digest if they are present.
+func (reference Reference) String() string {
+	value := reference.Name()
+	if reference.Tag != "" {
+		value += ":" + reference.Tag
+	}
+	if reference.Digest != "" {
+		value += "@" + reference.Digest
+	}
+	return value
+}
+
+// SameRepository returns whether a and b refer to the same repository
+// once they are normalized.
+func SameRepository(a, b string) (bool, error) {
+	referenceA, err := Parse(a)
+	if err != nil {
+		return false, err
+	}
+	referenceB, err := Parse(b)
+	if err != nil {
+		return false, err
+	}
+	return referenceA.Name() == referenceB.Name(), nil
+}
@@ -0,0 +1,157 @@
+package reference
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	type testCase struct {
+		Message           string
+		RawReference      string
+		ExpectedReference Reference
+		ExpectedName      string
+		ExpectedShortName string
+		ExpectedError     string
+	}
+	testCases := []testCase{
+		{
+			Message:           "should default to docker hub",
+			RawReference:      "rancher/rancher:v2.12.0",
+			ExpectedReference: Reference{Registry: "docker.io", Repository: "rancher/rancher", Tag: "v2.12.0"},
+			ExpectedName:      "docker.io/rancher/rancher",
+			ExpectedShortName: "rancher/rancher",
+		},
+		{
+			Message:           "should add library namespace to docker hub reference without namespace",
+			RawReference:      "nginx",
+			ExpectedReference: Reference{Registry: "docker.io", Repository: "library/nginx"},
+			ExpectedName:      "docker.io/library/nginx",
+			ExpectedShortName: "library/nginx",
+		},
+		{
+			Message:           "should add library namespace to docker hub reference with registry",
+			RawReference:      "docker.io/nginx:1.27",
+			ExpectedReference: Reference{Registry: "docker.io", Repository: "library/nginx", Tag: "1.27"},
+			ExpectedName:      "docker.io/library/nginx",
+			ExpectedShortName: "library/nginx",
+		},
+		{
+			Message:           "should normalize docker hub aliases",
+			RawReference:      "index.docker.io/rancher/rancher",
+			ExpectedReference: Reference{Registry: "docker.io", Repository: "rancher/rancher"},
+			ExpectedName:      "docker.io/rancher/rancher",
+			ExpectedShortName: "rancher/rancher",
+		},
+		{
+			Message:           "should parse registry with port",
+			RawReference:      "registry:5000/img:tag",
+			ExpectedReference: Reference{Registry: "registry:5000", Repository: "img", Tag: "tag"},
+			ExpectedName:      "registry:5000/img",
+			ExpectedShortName: "registry:5000/img",
+		},
+		{
+			Message:           "should parse localhost registry",
+			RawReference:      "localhost/org/img",
+			ExpectedReference: Reference{Registry: "localhost", Repository: "org/img"},
+			ExpectedName:      "localhost/org/img",
+			ExpectedShortName: "localhost/org/img",
+		},
+		{
+			Message:           "should parse long paths",
+			RawReference:      "gcr.io/cloud-provider-vsphere/csi/release/syncer:v3.3.1",
+			ExpectedReference: Reference{Registry: "gcr.io", Repository: "cloud-provider-vsphere/csi/release/syncer", Tag: "v3.3.1"},
+			ExpectedName:      "gcr.io/cloud-provider-vsphere/csi/release/syncer",
+			ExpectedShortName: "gcr.io/cloud-provider-vsphere/csi/release/syncer",
+		},
+		{
+			Message:      "should parse tag and digest",
+			RawReference: "quay.io/skopeo/stable:v1.16@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
+			ExpectedReference: Reference{
+				Registry:   "quay.io",
+				Repository: "skopeo/stable",
+				Tag:        "v1.16",
+				Digest:     "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
+			},
+			ExpectedName:      "quay.io/skopeo/stable",
+			ExpectedShortName: "quay.io/skopeo/stable",
+		},
+		{
+			Message:      "should parse digest without tag on registry with port",
+			RawReference: "registry:5000/img@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
+			ExpectedReference: Reference{
+				Registry:   "registry:5000",
+				Repository: "img",
+				Digest:     "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
+			},
+			ExpectedName:      "registry:5000/img",
+			ExpectedShortName: "registry:5000/img",
+		},
+		{
+			Message:       "should return error for empty reference",
+			RawReference:  "",
+			ExpectedError: "reference is empty",
+		},
+		{
+			Message:       "should return error for invalid tag",
+			RawReference:  "rancher/rancher:-v1",
+			ExpectedError: `invalid tag "-v1" in reference "rancher/rancher:-v1"`,
+		},
+		{
+			Message:       "should return error for invalid digest",
+			RawReference:  "rancher/rancher@asdf",
+			ExpectedError: `invalid digest "asdf" in reference "rancher/rancher@asdf"`,
+		},
+		{
+			Message:       "should return error for uppercase repository",
+			RawReference:  "quay.io/Org/image",
+			ExpectedError: `invalid repository "Org/image" in reference "quay.io/Org/image"`,
+		},
+		{
+			Message:       "should return error for empty path component",
+			RawReference:  "quay.io/org//image",
+			ExpectedError: `invalid repository "org//image" in reference "quay.io/org//image"`,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.Message, func(t *testing.T) {
+			reference, err := Parse(testCase.RawReference)
+			if testCase.ExpectedError != "" {
+				assert.EqualError(t, err, testCase.ExpectedError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, testCase.ExpectedReference, reference)
+			assert.Equal(t, testCase.ExpectedName, reference.Name())
+			assert.Equal(t, testCase.ExpectedShortName, reference.ShortName())
+		})
+	}
+}
+
+func TestString(t *testing.T) {
+	reference := Reference{Registry: "docker.io", Repository: "library/nginx", Tag: "1.27", Digest: "sha256:abcd"}
+	assert.Equal(t, "docker.io/library/nginx:1.27@sha256:abcd", reference.String())
+}
+
+func TestSameRepository(t *testing.T) {
+	type testCase struct {
+		Message  string
+		A        string
+		B        string
+		Expected bool
+	}
+	testCases := []testCase{
+		{Message: "should match with and without docker.io", A: "docker.io/rancher/rancher", B: "rancher/rancher", Expected: true},
+		{Message: "should match with and without library", A: "nginx", B: "docker.io/library/nginx", Expected: true},
+		{Message: "should ignore tags", A: "quay.io/org/image:v1", B: "quay.io/org/image", Expected: true},
+		{Message: "should not match different registries", A: "quay.io/org/image", B: "ghcr.io/org/image", Expected: false},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.Message, func(t *testing.T) {
+			same, err := SameRepository(testCase.A, testCase.B)
+			assert.NoError(t, err)
+			assert.Equal(t, testCase.Expected, same)
+		})
+	}
+}
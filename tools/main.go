@@ -7,26 +7,43 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"slices"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/rancher/artifact-mirror/internal/autoupdate"
 	"github.com/rancher/artifact-mirror/internal/config"
 	"github.com/rancher/artifact-mirror/internal/git"
 	"github.com/rancher/artifact-mirror/internal/paths"
+	"github.com/rancher/artifact-mirror/internal/reference"
 	"github.com/rancher/artifact-mirror/internal/regsync"
 
 	"github.com/google/go-github/v80/github"
 	"github.com/urfave/cli/v3"
-	oras "oras.land/oras-go/v2"
 	"oras.land/oras-go/v2/content/oci"
 	"oras.land/oras-go/v2/registry/remote"
 )
 
+var cacheDir string
+var cacheTTL time.Duration
+var cacheMaxAge time.Duration
+var codeHostName string
 var dryRun bool
 var entryName string
+var entryTimeout time.Duration
+var fullPull bool
 var mergeBaseBranch string
+var noCache bool
+var summaryFile string
+var summaryFormat string
+var validateConcurrency int
+var verifyArtifact string
+var verifyConcurrency int
+var verifyReportFile string
+var verifyRepository string
 
 func main() {
 	cmd := &cli.Command{
@@ -45,6 +62,34 @@ func main() {
 				Usage:  fmt.Sprintf("Use contents of %s to make pull requests that update %s", paths.AutoUpdateYaml, paths.ConfigYaml),
 				Action: autoUpdate,
 				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:        "cache-dir",
+						Usage:       "The directory in which HTTP responses are cached (default: artifact-mirror/http in the user cache directory)",
+						Destination: &cacheDir,
+					},
+					&cli.DurationFlag{
+						Name:        "cache-ttl",
+						Value:       10 * time.Minute,
+						Usage:       "How long cached HTTP responses are used before they are revalidated",
+						Destination: &cacheTTL,
+					},
+					&cli.DurationFlag{
+						Name:        "cache-max-age",
+						Value:       7 * 24 * time.Hour,
+						Usage:       "How long cached HTTP responses are kept without being revalidated before they are deleted, or 0 to keep them indefinitely",
+						Destination: &cacheMaxAge,
+					},
+					&cli.BoolFlag{
+						Name:        "no-cache",
+						Usage:       "Do not cache HTTP responses",
+						Destination: &noCache,
+					},
+					&cli.StringFlag{
+						Name:        "code-host",
+						Value:       "github",
+						Usage:       "The service to open pull requests against (github or gitlab)",
+						Destination: &codeHostName,
+					},
 					&cli.BoolFlag{
 						Name:        "dry-run",
 						Aliases:     []string{"n"},
@@ -57,6 +102,23 @@ func main() {
 						Usage:       "Autoupdate specific entry instead of all",
 						Destination: &entryName,
 					},
+					&cli.DurationFlag{
+						Name:        "entry-timeout",
+						Value:       30 * time.Minute,
+						Usage:       "The maximum time that a single entry may take (0 for no limit)",
+						Destination: &entryTimeout,
+					},
+					&cli.StringFlag{
+						Name:        "summary-file",
+						Usage:       "Write a summary of the run to this file",
+						Destination: &summaryFile,
+					},
+					&cli.StringFlag{
+						Name:        "summary-format",
+						Value:       "json",
+						Usage:       "The format of the summary file (json or markdown)",
+						Destination: &summaryFormat,
+					},
 				},
 			},
 			{
@@ -74,6 +136,17 @@ func main() {
 				Usage:  "Validate the state of various files",
 				Action: validate,
 				Flags: []cli.Flag{
+					&cli.IntFlag{
+						Name:        "concurrency",
+						Value:       8,
+						Usage:       "The number of new tags that are checked at once",
+						Destination: &validateConcurrency,
+					},
+					&cli.BoolFlag{
+						Name:        "full-pull",
+						Usage:       "Pull new tags including their layers, instead of only resolving their manifests and blobs",
+						Destination: &fullPull,
+					},
 					&cli.StringFlag{
 						Name:        "merge-base-branch",
 						Value:       "master",
@@ -82,10 +155,45 @@ func main() {
 					},
 				},
 			},
+			{
+				Name:   "verify",
+				Usage:  "Verify that each target in regsync.yaml has the same digest as its source",
+				Action: verifyMirror,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:        "artifact",
+						Aliases:     []string{"a"},
+						Usage:       "Only verify the artifact with this SourceArtifact",
+						Destination: &verifyArtifact,
+					},
+					&cli.IntFlag{
+						Name:        "concurrency",
+						Value:       8,
+						Usage:       "The number of source artifacts that are verified at once",
+						Destination: &verifyConcurrency,
+					},
+					&cli.StringFlag{
+						Name:        "report-file",
+						Usage:       "Write a JSON report of the targets that failed verification to this file",
+						Destination: &verifyReportFile,
+					},
+					&cli.StringFlag{
+						Name:        "repository",
+						Aliases:     []string{"r"},
+						Usage:       "Only verify targets in the repository with this BaseUrl",
+						Destination: &verifyRepository,
+					},
+				},
+			},
 		},
 	}
 
-	if err := cmd.Run(context.Background(), os.Args); err != nil {
+	// Cancel the context on SIGINT and SIGTERM, so that requests and git
+	// commands in progress are stopped.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := cmd.Run(ctx, os.Args); err != nil {
+		stop()
 		fmt.Printf("error: %s\n", err)
 		os.Exit(1)
 	}
@@ -134,8 +242,12 @@ func formatFiles(_ context.Context, _ *cli.Command) error {
 // autoUpdate uses the contents of autoupdate.yaml to make pull requests
 // that update config.yaml.
 func autoUpdate(ctx context.Context, _ *cli.Command) error {
+	if summaryFile != "" && !slices.Contains([]string{"json", "markdown"}, summaryFormat) {
+		return fmt.Errorf("invalid summary format %q", summaryFormat)
+	}
+
 	if !dryRun {
-		if clean, err := git.IsWorkingTreeClean(); err != nil {
+		if clean, err := git.IsWorkingTreeClean(ctx); err != nil {
 			return fmt.Errorf("failed to get status of working tree: %w", err)
 		} else if !clean {
 			return errors.New("working tree or index has changes")
@@ -152,47 +264,82 @@ func autoUpdate(ctx context.Context, _ *cli.Command) error {
 		return fmt.Errorf("failed to parse %s: %w", paths.AutoUpdateYaml, err)
 	}
 
-	ghClient := github.NewClient(nil)
-	if !dryRun {
-		githubToken := os.Getenv("GITHUB_TOKEN")
-		if githubToken == "" {
-			return errors.New("must define GITHUB_TOKEN")
-		}
-		ghClient = ghClient.WithAuthToken(githubToken)
+	codeHost, err := newCodeHost(codeHostName)
+	if err != nil {
+		return err
 	}
 
-	value := os.Getenv("GITHUB_REPOSITORY")
-	if value == "" {
-		return errors.New("must define GITHUB_REPOSITORY")
+	if !noCache {
+		if err := useHTTPCache(); err != nil {
+			return err
+		}
 	}
-	parts := strings.Split(value, "/")
-	if len(parts) != 2 {
-		return errors.New("must define GITHUB_REPOSITORY in form <owner>/<repo>")
+
+	credentials, err := autoupdate.NewRegistryCredentials(configYaml.Repositories)
+	if err != nil {
+		return fmt.Errorf("failed to load registry credentials: %w", err)
 	}
-	githubOwner := parts[0]
-	githubRepo := parts[1]
 
+	summary := autoupdate.Summary{
+		Entries: make([]autoupdate.EntryResult, 0, len(autoUpdateEntries)),
+	}
 	errorPresent := false
 	for _, autoUpdateEntry := range autoUpdateEntries {
 		if entryName != "" && autoUpdateEntry.Name != entryName {
 			fmt.Printf("%s: skipped\n", autoUpdateEntry.Name)
 			continue
 		}
+		if ctx.Err() != nil {
+			fmt.Printf("%s: not run because autoupdate was interrupted\n", autoUpdateEntry.Name)
+			summary.Entries = append(summary.Entries, autoupdate.EntryResult{
+				Name:    autoUpdateEntry.Name,
+				Outcome: autoupdate.OutcomeError,
+				Error:   fmt.Sprintf("not run because autoupdate was interrupted: %s", ctx.Err()),
+			})
+			errorPresent = true
+			continue
+		}
+		// A previous entry that failed while committing may have left
+		// changes behind, which this entry would otherwise commit.
+		if !dryRun {
+			clean, err := git.IsWorkingTreeClean(ctx)
+			if err == nil && !clean {
+				err = errors.New("working tree or index has changes")
+			}
+			if err != nil {
+				fmt.Printf("%s: not run: %s\n", autoUpdateEntry.Name, err)
+				summary.Entries = append(summary.Entries, autoupdate.EntryResult{
+					Name:    autoUpdateEntry.Name,
+					Outcome: autoupdate.OutcomeError,
+					Error:   fmt.Sprintf("not run: %s", err),
+				})
+				errorPresent = true
+				continue
+			}
+		}
 
 		autoUpdateOptions := autoupdate.AutoUpdateOptions{
 			BaseBranch:   "master",
 			ConfigYaml:   configYaml.DeepCopy(),
 			DryRun:       dryRun,
-			GithubOwner:  githubOwner,
-			GithubRepo:   githubRepo,
-			GithubClient: ghClient,
+			CodeHost:     codeHost,
+			TagResolver:  autoupdate.OrasTagResolver{Credentials: credentials},
+			Credentials:  credentials,
+			EntryTimeout: entryTimeout,
 		}
-		if err := autoUpdateEntry.Run(ctx, autoUpdateOptions); err != nil {
+		result, err := autoUpdateEntry.Run(ctx, autoUpdateOptions)
+		summary.Entries = append(summary.Entries, result)
+		if err != nil {
 			fmt.Printf("%s: error: %s\n", autoUpdateEntry.Name, err)
 			errorPresent = true
 			continue
 		}
 	}
+	if summaryFile != "" {
+		if err := autoupdate.WriteSummary(summaryFile, summaryFormat, summary); err != nil {
+			return fmt.Errorf("failed to write summary to %s: %w", summaryFile, err)
+		}
+	}
 	if errorPresent {
 		return fmt.Errorf("one or more %s entries failed to update; please see above logs for details", paths.AutoUpdateYaml)
 	}
@@ -200,9 +347,75 @@ func autoUpdate(ctx context.Context, _ *cli.Command) error {
 	return nil
 }
 
+// useHTTPCache makes the autoupdate strategies cache HTTP responses in
+// cacheDir.
+func useHTTPCache() error {
+	dir := cacheDir
+	if dir == "" {
+		userCacheDir, err := os.UserCacheDir()
+		if err != nil {
+			return fmt.Errorf("failed to get user cache directory: %w", err)
+		}
+		dir = filepath.Join(userCacheDir, "artifact-mirror", "http")
+	}
+	cache, err := autoupdate.NewHTTPCache(dir, cacheTTL, cacheMaxAge)
+	if err != nil {
+		return fmt.Errorf("failed to set up HTTP cache: %w", err)
+	}
+	autoupdate.UseHTTPCache(cache)
+	return nil
+}
+
+// newCodeHost constructs the CodeHost that autoupdate opens pull requests
+// against. It is configured via the environment variables that are set by
+// the CI system of the respective code host.
+func newCodeHost(name string) (autoupdate.CodeHost, error) {
+	switch name {
+	case "github":
+		ghClient := github.NewClient(nil)
+		if !dryRun {
+			githubToken := os.Getenv("GITHUB_TOKEN")
+			if githubToken == "" {
+				return nil, errors.New("must define GITHUB_TOKEN")
+			}
+			ghClient = ghClient.WithAuthToken(githubToken)
+		}
+
+		value := os.Getenv("GITHUB_REPOSITORY")
+		if value == "" {
+			return nil, errors.New("must define GITHUB_REPOSITORY")
+		}
+		parts := strings.Split(value, "/")
+		if len(parts) != 2 {
+			return nil, errors.New("must define GITHUB_REPOSITORY in form <owner>/<repo>")
+		}
+		return autoupdate.NewGitHub(ghClient, parts[0], parts[1]), nil
+	case "gitlab":
+		gitlabToken := os.Getenv("GITLAB_TOKEN")
+		if !dryRun && gitlabToken == "" {
+			return nil, errors.New("must define GITLAB_TOKEN")
+		}
+		apiURL := os.Getenv("CI_API_V4_URL")
+		if apiURL == "" {
+			apiURL = "https://gitlab.com/api/v4"
+		}
+		project := os.Getenv("CI_PROJECT_PATH")
+		if project == "" {
+			return nil, errors.New("must define CI_PROJECT_PATH")
+		}
+		return autoupdate.NewGitLab(apiURL, project, gitlabToken), nil
+	default:
+		return nil, fmt.Errorf("unknown code host %q", name)
+	}
+}
+
 // validate is used to run validations based in Go code against
 // the state of the artifact-mirror repo.
-func validate(_ context.Context, _ *cli.Command) error {
+func validate(ctx context.Context, _ *cli.Command) error {
+	if validateConcurrency < 1 {
+		return fmt.Errorf("concurrency must be at least 1, got %d", validateConcurrency)
+	}
+
 	configYaml, err := config.Parse(paths.ConfigYaml)
 	if err != nil {
 		return fmt.Errorf("failed to parse %s: %w", paths.ConfigYaml, err)
@@ -211,9 +424,10 @@ func validate(_ context.Context, _ *cli.Command) error {
 	// Run validations
 	errs := make([]error, 0)
 	validateSourceArtifactAndTargetArtifactName(&errs, configYaml)
-	validateNoTagsRemoved(&errs, configYaml)
-	validateNewTagsPullable(&errs, configYaml)
-	validateDockerHubRepoExists(&errs, configYaml)
+	validateRequiredPlatforms(&errs, configYaml)
+	validateNoTagsRemoved(ctx, &errs, configYaml)
+	validateNewTagsPullable(ctx, &errs, configYaml)
+	validateDockerHubRepoExists(ctx, &errs, configYaml)
 
 	// Format results into one error, if any
 	if len(errs) > 0 {
@@ -244,8 +458,25 @@ func validateSourceArtifactAndTargetArtifactName(errs *[]error, configYaml *conf
 	}
 }
 
-func validateNoTagsRemoved(errs *[]error, newConfigYaml *config.Config) {
-	oldConfigYaml, err := loadMergeBaseConfigYaml(mergeBaseBranch)
+func validateRequiredPlatforms(errs *[]error, configYaml *config.Config) {
+	for _, repository := range configYaml.Repositories {
+		for _, platform := range repository.RequiredPlatforms {
+			if _, err := parsePlatform(platform); err != nil {
+				*errs = append(*errs, fmt.Errorf("repository %s: %w", repository.BaseUrl, err))
+			}
+		}
+	}
+	for _, artifact := range configYaml.Artifacts {
+		for _, platform := range artifact.RequiredPlatforms {
+			if _, err := parsePlatform(platform); err != nil {
+				*errs = append(*errs, fmt.Errorf("artifact %s (TargetArtifactName %q): %w", artifact.SourceArtifact, artifact.TargetArtifactName(), err))
+			}
+		}
+	}
+}
+
+func validateNoTagsRemoved(ctx context.Context, errs *[]error, newConfigYaml *config.Config) {
+	oldConfigYaml, err := loadMergeBaseConfigYaml(ctx, mergeBaseBranch)
 	if err != nil {
 		*errs = append(*errs, fmt.Errorf("failed to load %s from merge base %q: %w", paths.ConfigYaml, mergeBaseBranch, err))
 		return
@@ -253,12 +484,12 @@ func validateNoTagsRemoved(errs *[]error, newConfigYaml *config.Config) {
 	checkNoTagsRemoved(errs, oldConfigYaml.Artifacts, newConfigYaml.Artifacts)
 }
 
-func loadMergeBaseConfigYaml(branch string) (*config.Config, error) {
-	mergeBase, err := git.GetMergeBase(branch)
+func loadMergeBaseConfigYaml(ctx context.Context, branch string) (*config.Config, error) {
+	mergeBase, err := git.GetMergeBase(ctx, branch)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get merge base: %w", err)
 	}
-	oldContent, err := git.GetFileContentAtCommit(mergeBase, paths.ConfigYaml)
+	oldContent, err := git.GetFileContentAtCommit(ctx, mergeBase, paths.ConfigYaml)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get file content at %s: %w", mergeBase, err)
 	}
@@ -289,8 +520,8 @@ func checkNoTagsRemoved(errs *[]error, oldArtifacts, newArtifacts []*config.Arti
 	}
 }
 
-func validateNewTagsPullable(errs *[]error, newConfigYaml *config.Config) {
-	oldConfigYaml, err := loadMergeBaseConfigYaml(mergeBaseBranch)
+func validateNewTagsPullable(ctx context.Context, errs *[]error, newConfigYaml *config.Config) {
+	oldConfigYaml, err := loadMergeBaseConfigYaml(ctx, mergeBaseBranch)
 	if err != nil {
 		*errs = append(*errs, fmt.Errorf("failed to load %s from merge base %q: %w", paths.ConfigYaml, mergeBaseBranch, err))
 		return
@@ -313,20 +544,8 @@ func validateNewTagsPullable(errs *[]error, newConfigYaml *config.Config) {
 		artifactsWithNewTags = append(artifactsWithNewTags, diffArtifact)
 	}
 
-	// Instantiate oras store
-	dirPath, err := os.MkdirTemp("", "artifact-mirror-validation-*")
-	if err != nil {
-		*errs = append(*errs, fmt.Errorf("failed to create temp dir: %w", err))
-		return
-	}
-	defer os.RemoveAll(dirPath)
-	store, err := oci.New(dirPath)
-	if err != nil {
-		*errs = append(*errs, fmt.Errorf("failed to instantiate oras store: %w", err))
-		return
-	}
-
-	// Try pulling each tag
+	// Collect the tags to check
+	tagsToPull := make([]tagToPull, 0)
 	for _, newTagArtifact := range artifactsWithNewTags {
 		repo, err := parseRepository(newTagArtifact.SourceArtifact)
 		if err != nil {
@@ -339,37 +558,58 @@ func validateNewTagsPullable(errs *[]error, newConfigYaml *config.Config) {
 		// is needed to get secrets from EIO's setup. Pulling artifacts from
 		// the application collection requires one of these secrets. So,
 		// we do not try pulling the artifact if it is from the appco.
-		if strings.HasPrefix(newTagArtifact.SourceArtifact, "dp.apps.rancher.io") {
+		if repo.Reference.Registry == "dp.apps.rancher.io" {
 			continue
 		}
+		requiredPlatforms := newConfigYaml.RequiredPlatforms(newTagArtifact)
 		for _, newTag := range newTagArtifact.Tags {
-			_, err := oras.Copy(context.Background(), repo, newTag, store, newTag, oras.DefaultCopyOptions)
-			if err != nil {
-				*errs = append(*errs, fmt.Errorf("failed to pull %s:%s: %w", newTagArtifact.SourceArtifact, newTag, err))
-				continue
-			}
+			tagsToPull = append(tagsToPull, tagToPull{
+				sourceArtifact:    newTagArtifact.SourceArtifact,
+				tag:               newTag,
+				source:            repo,
+				requiredPlatforms: requiredPlatforms,
+			})
+		}
+	}
+	if len(tagsToPull) == 0 {
+		return
+	}
+
+	// By default, only manifests are fetched and blobs are checked with
+	// HEAD requests. With --full-pull, each tag is copied, layers
+	// included, into a temporary OCI layout.
+	check := checkTagResolvable
+	if fullPull {
+		dirPath, err := os.MkdirTemp("", "artifact-mirror-validation-*")
+		if err != nil {
+			*errs = append(*errs, fmt.Errorf("failed to create temp dir: %w", err))
+			return
+		}
+		defer os.RemoveAll(dirPath)
+		store, err := oci.New(dirPath)
+		if err != nil {
+			*errs = append(*errs, fmt.Errorf("failed to instantiate oras store: %w", err))
+			return
 		}
+		check = newFullPullCheck(store)
 	}
+	*errs = append(*errs, checkTagsPullable(ctx, tagsToPull, validateConcurrency, check)...)
 }
 
 func parseRepository(repository string) (*remote.Repository, error) {
-	preparedRepository := repository
-	parts := strings.SplitN(repository, "/", 2)
-	if len(parts) != 2 {
-		return nil, errors.New("invalid format")
-	}
-	if !strings.Contains(parts[0], ".") {
-		preparedRepository = "docker.io/" + preparedRepository
+	ref, err := reference.Parse(repository)
+	if err != nil {
+		return nil, err
 	}
-	repo, err := remote.NewRepository(preparedRepository)
+	repo, err := remote.NewRepository(ref.Name())
 	if err != nil {
 		return nil, fmt.Errorf("failed to instantiate repository: %w", err)
 	}
 	return repo, nil
 }
 
-func validateDockerHubRepoExists(errs *[]error, newConfigYaml *config.Config) {
-	oldConfigYaml, err := loadMergeBaseConfigYaml(mergeBaseBranch)
+func validateDockerHubRepoExists(ctx context.Context, errs *[]error, newConfigYaml *config.Config) {
+	oldConfigYaml, err := loadMergeBaseConfigYaml(ctx, mergeBaseBranch)
 	if err != nil {
 		*errs = append(*errs, fmt.Errorf("failed to load %s from merge base %q: %w", paths.ConfigYaml, mergeBaseBranch, err))
 		return
@@ -393,7 +633,7 @@ func validateDockerHubRepoExists(errs *[]error, newConfigYaml *config.Config) {
 	}
 
 	// fetch existing repositories from dockerhub
-	existingRepositories, err := fetchDockerHubRepositories()
+	existingRepositories, err := fetchDockerHubRepositories(ctx)
 	if err != nil {
 		*errs = append(*errs, fmt.Errorf("failed to fetch existing repositories from dockerhub: %w", err))
 		return
@@ -408,7 +648,7 @@ func validateDockerHubRepoExists(errs *[]error, newConfigYaml *config.Config) {
 	}
 }
 
-func fetchDockerHubRepositories() (map[string]struct{}, error) {
+func fetchDockerHubRepositories(ctx context.Context) (map[string]struct{}, error) {
 	type DockerAPIResponseRepository struct {
 		Name string `json:"name"`
 	}
@@ -423,7 +663,7 @@ func fetchDockerHubRepositories() (map[string]struct{}, error) {
 	repos := map[string]struct{}{}
 	nextURL := "https://hub.docker.com/v2/namespaces/rancher/repositories?page_size=100"
 	for nextURL != "" {
-		req, err := http.NewRequest("GET", nextURL, nil)
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, nextURL, nil)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create request: %w", err)
 		}
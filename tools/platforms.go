@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content"
+)
+
+// Docker media types, which are used by many images instead of their OCI
+// equivalents.
+const (
+	dockerMediaTypeManifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
+	dockerMediaTypeManifest     = "application/vnd.docker.distribution.manifest.v2+json"
+	dockerMediaTypeImageConfig  = "application/vnd.docker.container.image.v1+json"
+)
+
+// parsePlatform parses a platform in the form os/arch[/variant].
+func parsePlatform(value string) (ocispec.Platform, error) {
+	parts := strings.Split(value, "/")
+	if len(parts) < 2 || len(parts) > 3 || slices.Contains(parts, "") {
+		return ocispec.Platform{}, fmt.Errorf("invalid platform %q: must be in the form os/arch[/variant]", value)
+	}
+	platform := ocispec.Platform{
+		OS:           parts[0],
+		Architecture: parts[1],
+	}
+	if len(parts) == 3 {
+		platform.Variant = parts[2]
+	}
+	return platform, nil
+}
+
+// platformMatches returns whether platform satisfies required. A required
+// platform without a variant is satisfied by any variant.
+func platformMatches(platform, required ocispec.Platform) bool {
+	if platform.OS != required.OS || platform.Architecture != required.Architecture {
+		return false
+	}
+	return required.Variant == "" || platform.Variant == required.Variant
+}
+
+// missingPlatforms returns the platforms in required that the artifact
+// described by desc does not provide. The platforms of an index are those
+// of its manifests, and the platform of an image manifest is that of its
+// config. Other artifacts, such as helm charts, are not platform specific
+// and never miss a platform.
+func missingPlatforms(ctx context.Context, fetcher content.Fetcher, desc ocispec.Descriptor, required []string) ([]string, error) {
+	var platforms []ocispec.Platform
+	switch desc.MediaType {
+	case ocispec.MediaTypeImageIndex, dockerMediaTypeManifestList:
+		index := ocispec.Index{}
+		if err := fetchJSON(ctx, fetcher, desc, &index); err != nil {
+			return nil, fmt.Errorf("failed to fetch index: %w", err)
+		}
+		for _, manifest := range index.Manifests {
+			if manifest.Platform != nil {
+				platforms = append(platforms, *manifest.Platform)
+			}
+		}
+	case ocispec.MediaTypeImageManifest, dockerMediaTypeManifest:
+		manifest := ocispec.Manifest{}
+		if err := fetchJSON(ctx, fetcher, desc, &manifest); err != nil {
+			return nil, fmt.Errorf("failed to fetch manifest: %w", err)
+		}
+		if manifest.Config.MediaType != ocispec.MediaTypeImageConfig && manifest.Config.MediaType != dockerMediaTypeImageConfig {
+			return nil, nil
+		}
+		image := ocispec.Image{}
+		if err := fetchJSON(ctx, fetcher, manifest.Config, &image); err != nil {
+			return nil, fmt.Errorf("failed to fetch image config: %w", err)
+		}
+		platforms = append(platforms, image.Platform)
+	default:
+		return nil, nil
+	}
+
+	missing := make([]string, 0)
+	for _, value := range required {
+		requiredPlatform, err := parsePlatform(value)
+		if err != nil {
+			return nil, err
+		}
+		if !slices.ContainsFunc(platforms, func(platform ocispec.Platform) bool {
+			return platformMatches(platform, requiredPlatform)
+		}) {
+			missing = append(missing, value)
+		}
+	}
+	return missing, nil
+}
+
+// fetchJSON fetches the content described by desc from fetcher, verifies
+// it and unmarshals it into v.
+func fetchJSON(ctx context.Context, fetcher content.Fetcher, desc ocispec.Descriptor, v any) error {
+	contents, err := content.FetchAll(ctx, fetcher, desc)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(contents, v)
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/content/memory"
+)
+
+// pushJSON pushes v, marshalled to JSON, to store.
+func pushJSON(t *testing.T, store content.Storage, mediaType string, v any) ocispec.Descriptor {
+	t.Helper()
+	contents, err := json.Marshal(v)
+	assert.NoError(t, err)
+	return pushBytes(t, store, mediaType, contents)
+}
+
+func pushBytes(t *testing.T, store content.Storage, mediaType string, contents []byte) ocispec.Descriptor {
+	t.Helper()
+	desc := ocispec.Descriptor{
+		MediaType: mediaType,
+		Digest:    digest.FromBytes(contents),
+		Size:      int64(len(contents)),
+	}
+	exists, err := store.Exists(t.Context(), desc)
+	assert.NoError(t, err)
+	if !exists {
+		assert.NoError(t, store.Push(t.Context(), desc, bytes.NewReader(contents)))
+	}
+	return desc
+}
+
+// pushImage pushes an image for platform with the given layers to store.
+func pushImage(t *testing.T, store content.Storage, platform ocispec.Platform, layers ...ocispec.Descriptor) ocispec.Descriptor {
+	t.Helper()
+	config := pushJSON(t, store, ocispec.MediaTypeImageConfig, ocispec.Image{Platform: platform})
+	desc := pushJSON(t, store, ocispec.MediaTypeImageManifest, ocispec.Manifest{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: ocispec.MediaTypeImageManifest,
+		Config:    config,
+		Layers:    layers,
+	})
+	desc.Platform = &platform
+	return desc
+}
+
+// pushIndex pushes an index with an image for each of platforms to store.
+func pushIndex(t *testing.T, store content.Storage, platforms ...ocispec.Platform) ocispec.Descriptor {
+	t.Helper()
+	index := ocispec.Index{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: ocispec.MediaTypeImageIndex,
+	}
+	for _, platform := range platforms {
+		index.Manifests = append(index.Manifests, pushImage(t, store, platform))
+	}
+	return pushJSON(t, store, ocispec.MediaTypeImageIndex, index)
+}
+
+func TestMissingPlatforms(t *testing.T) {
+	store := memory.New()
+	amd64 := ocispec.Platform{OS: "linux", Architecture: "amd64"}
+	arm64 := ocispec.Platform{OS: "linux", Architecture: "arm64", Variant: "v8"}
+	armV6 := ocispec.Platform{OS: "linux", Architecture: "arm", Variant: "v6"}
+	attestation := ocispec.Platform{OS: "unknown", Architecture: "unknown"}
+	helmChart := pushJSON(t, store, ocispec.MediaTypeImageManifest, ocispec.Manifest{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: ocispec.MediaTypeImageManifest,
+		Config:    pushJSON(t, store, "application/vnd.cncf.helm.config.v1+json", map[string]string{"name": "chart"}),
+	})
+
+	type testCase struct {
+		Message  string
+		Desc     ocispec.Descriptor
+		Required []string
+		Expected []string
+	}
+	testCases := []testCase{
+		{
+			Message:  "should accept an index with all required platforms",
+			Desc:     pushIndex(t, store, amd64, arm64, attestation),
+			Required: []string{"linux/amd64", "linux/arm64"},
+			Expected: []string{},
+		},
+		{
+			Message:  "should report platforms missing from an index",
+			Desc:     pushIndex(t, store, amd64, attestation),
+			Required: []string{"linux/amd64", "linux/arm64"},
+			Expected: []string{"linux/arm64"},
+		},
+		{
+			Message:  "should match variants if they are required",
+			Desc:     pushIndex(t, store, amd64, arm64, armV6),
+			Required: []string{"linux/arm64/v8", "linux/arm/v7"},
+			Expected: []string{"linux/arm/v7"},
+		},
+		{
+			Message:  "should use the platform of the config of an image manifest",
+			Desc:     pushImage(t, store, amd64),
+			Required: []string{"linux/amd64", "linux/arm64"},
+			Expected: []string{"linux/arm64"},
+		},
+		{
+			Message:  "should ignore artifacts that are not images",
+			Desc:     helmChart,
+			Required: []string{"linux/amd64", "linux/arm64"},
+			Expected: nil,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.Message, func(t *testing.T) {
+			missing, err := missingPlatforms(t.Context(), store, testCase.Desc, testCase.Required)
+			assert.NoError(t, err)
+			assert.Equal(t, testCase.Expected, missing)
+		})
+	}
+
+	t.Run("should return an error for invalid platforms", func(t *testing.T) {
+		_, err := missingPlatforms(t.Context(), store, pushIndex(t, store, amd64), []string{"linux"})
+		assert.EqualError(t, err, `invalid platform "linux": must be in the form os/arch[/variant]`)
+	})
+}
+
+func TestParsePlatform(t *testing.T) {
+	platform, err := parsePlatform("linux/arm/v7")
+	assert.NoError(t, err)
+	assert.Equal(t, ocispec.Platform{OS: "linux", Architecture: "arm", Variant: "v7"}, platform)
+
+	for _, value := range []string{"", "linux", "linux/", "linux/arm/v7/extra"} {
+		_, err := parsePlatform(value)
+		assert.Error(t, err, value)
+	}
+}
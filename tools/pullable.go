@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	oras "oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/content"
+)
+
+// tagToPull is a new tag whose pullability is checked by
+// validateNewTagsPullable.
+type tagToPull struct {
+	sourceArtifact    string
+	tag               string
+	source            oras.ReadOnlyTarget
+	requiredPlatforms []string
+}
+
+// checkTagsPullable runs check for each of tags, with at most concurrency
+// checks running at once. The errors are returned in the order of tags.
+func checkTagsPullable(ctx context.Context, tags []tagToPull, concurrency int, check func(context.Context, tagToPull) error) []error {
+	results := make([]error, len(tags))
+	semaphore := make(chan struct{}, concurrency)
+	wg := sync.WaitGroup{}
+	for i, tag := range tags {
+		semaphore <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+			results[i] = check(ctx, tag)
+		}()
+	}
+	wg.Wait()
+
+	errs := make([]error, 0)
+	for _, err := range results {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// checkTagResolvable checks that tag can be pulled without downloading
+// it. The manifests and indexes that tag refers to are fetched, and the
+// existence of every other blob is checked with a HEAD request. It also
+// checks that tag provides each of the required platforms.
+func checkTagResolvable(ctx context.Context, tag tagToPull) error {
+	desc, err := tag.source.Resolve(ctx, tag.tag)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s:%s: %w", tag.sourceArtifact, tag.tag, err)
+	}
+	fetcher := &cachingFetcher{fetcher: tag.source}
+	if err := checkContentExists(ctx, fetcher, tag.source, desc); err != nil {
+		return fmt.Errorf("failed to resolve %s:%s: %w", tag.sourceArtifact, tag.tag, err)
+	}
+	return checkRequiredPlatforms(ctx, fetcher, desc, tag)
+}
+
+// newFullPullCheck returns a check for checkTagsPullable that copies
+// each tag, layers included, into store, and then checks that it provides
+// each of the required platforms.
+func newFullPullCheck(store oras.Target) func(context.Context, tagToPull) error {
+	return func(ctx context.Context, tag tagToPull) error {
+		desc, err := oras.Copy(ctx, tag.source, tag.tag, store, tag.tag, oras.DefaultCopyOptions)
+		if err != nil {
+			return fmt.Errorf("failed to pull %s:%s: %w", tag.sourceArtifact, tag.tag, err)
+		}
+		return checkRequiredPlatforms(ctx, store, desc, tag)
+	}
+}
+
+func checkRequiredPlatforms(ctx context.Context, fetcher content.Fetcher, desc ocispec.Descriptor, tag tagToPull) error {
+	missing, err := missingPlatforms(ctx, fetcher, desc, tag.requiredPlatforms)
+	if err != nil {
+		return fmt.Errorf("failed to check platforms of %s:%s: %w", tag.sourceArtifact, tag.tag, err)
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%s:%s is missing required platforms %s", tag.sourceArtifact, tag.tag, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// checkContentExists checks that the content described by desc exists in
+// storage, along with everything it refers to. Manifests and indexes are
+// fetched with fetcher to find what they refer to; other blobs are only
+// checked for existence.
+func checkContentExists(ctx context.Context, fetcher content.Fetcher, storage content.ReadOnlyStorage, desc ocispec.Descriptor) error {
+	switch desc.MediaType {
+	case ocispec.MediaTypeImageIndex, dockerMediaTypeManifestList, ocispec.MediaTypeImageManifest, dockerMediaTypeManifest:
+		successors, err := content.Successors(ctx, fetcher, desc)
+		if err != nil {
+			return fmt.Errorf("failed to fetch %s: %w", desc.Digest, err)
+		}
+		for _, successor := range successors {
+			if err := checkContentExists(ctx, fetcher, storage, successor); err != nil {
+				return err
+			}
+		}
+	default:
+		exists, err := storage.Exists(ctx, desc)
+		if err != nil {
+			return fmt.Errorf("failed to check blob %s: %w", desc.Digest, err)
+		}
+		if !exists {
+			return fmt.Errorf("blob %s not found", desc.Digest)
+		}
+	}
+	return nil
+}
+
+// cachingFetcher remembers the content that it fetches, so that manifests
+// that are read more than once, e.g. to find their blobs and then their
+// platforms, are only requested once.
+type cachingFetcher struct {
+	fetcher  content.Fetcher
+	contents map[digest.Digest][]byte
+}
+
+func (f *cachingFetcher) Fetch(ctx context.Context, desc ocispec.Descriptor) (io.ReadCloser, error) {
+	contents, ok := f.contents[desc.Digest]
+	if !ok {
+		var err error
+		contents, err = content.FetchAll(ctx, f.fetcher, desc)
+		if err != nil {
+			return nil, err
+		}
+		if f.contents == nil {
+			f.contents = map[digest.Digest][]byte{}
+		}
+		f.contents[desc.Digest] = contents
+	}
+	return io.NopCloser(bytes.NewReader(contents)), nil
+}
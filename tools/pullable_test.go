@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	oras "oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/content/memory"
+)
+
+// layerFetchCountingTarget counts the layers that are fetched from it.
+type layerFetchCountingTarget struct {
+	oras.ReadOnlyTarget
+	lock          sync.Mutex
+	layersFetched int
+}
+
+func (target *layerFetchCountingTarget) Fetch(ctx context.Context, desc ocispec.Descriptor) (io.ReadCloser, error) {
+	if desc.MediaType == ocispec.MediaTypeImageLayerGzip {
+		target.lock.Lock()
+		target.layersFetched++
+		target.lock.Unlock()
+	}
+	return target.ReadOnlyTarget.Fetch(ctx, desc)
+}
+
+func TestCheckTagResolvable(t *testing.T) {
+	store := memory.New()
+	amd64 := ocispec.Platform{OS: "linux", Architecture: "amd64"}
+	arm64 := ocispec.Platform{OS: "linux", Architecture: "arm64"}
+	layer := pushBytes(t, store, ocispec.MediaTypeImageLayerGzip, []byte("layer"))
+	missingLayer := ocispec.Descriptor{
+		MediaType: ocispec.MediaTypeImageLayerGzip,
+		Digest:    "sha256:0000000000000000000000000000000000000000000000000000000000000000",
+		Size:      5,
+	}
+	tagIndex := func(t *testing.T, tag string, manifests ...ocispec.Descriptor) {
+		t.Helper()
+		desc := pushJSON(t, store, ocispec.MediaTypeImageIndex, ocispec.Index{
+			Versioned: specs.Versioned{SchemaVersion: 2},
+			MediaType: ocispec.MediaTypeImageIndex,
+			Manifests: manifests,
+		})
+		assert.NoError(t, store.Tag(t.Context(), desc, tag))
+	}
+	tagIndex(t, "complete", pushImage(t, store, amd64, layer), pushImage(t, store, arm64, layer))
+	tagIndex(t, "missing-blob", pushImage(t, store, amd64, layer), pushImage(t, store, arm64, missingLayer))
+	tagIndex(t, "amd64-only", pushImage(t, store, amd64, layer))
+
+	type testCase struct {
+		Message       string
+		Tag           string
+		ExpectedError string
+	}
+	testCases := []testCase{
+		{
+			Message: "should accept a tag whose content all exists",
+			Tag:     "complete",
+		},
+		{
+			Message:       "should return an error for a missing blob",
+			Tag:           "missing-blob",
+			ExpectedError: fmt.Sprintf("failed to resolve test/image:missing-blob: blob %s not found", missingLayer.Digest),
+		},
+		{
+			Message:       "should return an error for a missing tag",
+			Tag:           "missing",
+			ExpectedError: "failed to resolve test/image:missing: missing: not found",
+		},
+		{
+			Message:       "should return an error for a missing platform",
+			Tag:           "amd64-only",
+			ExpectedError: "test/image:amd64-only is missing required platforms linux/arm64",
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.Message, func(t *testing.T) {
+			target := &layerFetchCountingTarget{ReadOnlyTarget: store}
+			err := checkTagResolvable(t.Context(), tagToPull{
+				sourceArtifact:    "test/image",
+				tag:               testCase.Tag,
+				source:            target,
+				requiredPlatforms: []string{"linux/amd64", "linux/arm64"},
+			})
+			if testCase.ExpectedError == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, testCase.ExpectedError)
+			}
+			assert.Equal(t, 0, target.layersFetched)
+		})
+	}
+
+	t.Run("should copy layers with a full pull", func(t *testing.T) {
+		target := &layerFetchCountingTarget{ReadOnlyTarget: store}
+		check := newFullPullCheck(memory.New())
+		err := check(t.Context(), tagToPull{
+			sourceArtifact:    "test/image",
+			tag:               "complete",
+			source:            target,
+			requiredPlatforms: []string{"linux/amd64", "linux/arm64"},
+		})
+		assert.NoError(t, err)
+		// Both images share the same layer, which is copied once.
+		assert.Equal(t, 1, target.layersFetched)
+	})
+}
+
+func TestCheckTagsPullable(t *testing.T) {
+	tags := make([]tagToPull, 0)
+	for i := range 10 {
+		tags = append(tags, tagToPull{sourceArtifact: "test/image", tag: fmt.Sprintf("v%d", i)})
+	}
+
+	lock := sync.Mutex{}
+	running := 0
+	maxRunning := 0
+	errs := checkTagsPullable(t.Context(), tags, 3, func(ctx context.Context, tag tagToPull) error {
+		lock.Lock()
+		running++
+		maxRunning = max(maxRunning, running)
+		lock.Unlock()
+		defer func() {
+			lock.Lock()
+			running--
+			lock.Unlock()
+		}()
+		if tag.tag == "v2" || tag.tag == "v7" {
+			return errors.New(tag.tag + " failed")
+		}
+		return nil
+	})
+
+	assert.Equal(t, []error{errors.New("v2 failed"), errors.New("v7 failed")}, errs)
+	assert.LessOrEqual(t, maxRunning, 3)
+}
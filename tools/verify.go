@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+
+	"github.com/rancher/artifact-mirror/internal/autoupdate"
+	"github.com/rancher/artifact-mirror/internal/config"
+	"github.com/rancher/artifact-mirror/internal/paths"
+	"github.com/rancher/artifact-mirror/internal/reference"
+	"github.com/rancher/artifact-mirror/internal/regsync"
+
+	"github.com/urfave/cli/v3"
+	"oras.land/oras-go/v2/errdef"
+	"oras.land/oras-go/v2/registry/remote"
+	"oras.land/oras-go/v2/registry/remote/auth"
+)
+
+type verifyStatus string
+
+const (
+	verifyStatusOK       verifyStatus = "ok"
+	verifyStatusMissing  verifyStatus = "missing"
+	verifyStatusMismatch verifyStatus = "mismatch"
+	verifyStatusError    verifyStatus = "error"
+)
+
+// verifyResult is the result of comparing the target of a ConfigSync to
+// its source.
+type verifyResult struct {
+	Source       string       `json:"source"`
+	Target       string       `json:"target"`
+	Status       verifyStatus `json:"status"`
+	SourceDigest string       `json:"sourceDigest,omitempty"`
+	TargetDigest string       `json:"targetDigest,omitempty"`
+	Error        string       `json:"error,omitempty"`
+}
+
+// verifyReport is what the verify subcommand writes to --report-file.
+type verifyReport struct {
+	Checked    int `json:"checked"`
+	OK         int `json:"ok"`
+	Missing    int `json:"missing"`
+	Mismatched int `json:"mismatched"`
+	Errors     int `json:"errors"`
+	// Failures are the results whose status is not ok.
+	Failures []verifyResult `json:"failures"`
+}
+
+// digestResolver resolves references of the form <repository>:<tag> to
+// the digest of their manifest. If the reference does not exist, the
+// returned error wraps errdef.ErrNotFound.
+type digestResolver interface {
+	resolveDigest(ctx context.Context, rawReference string) (string, error)
+}
+
+// orasDigestResolver is a digestResolver that resolves references with a
+// HEAD request to the registry, so that manifests are not downloaded.
+type orasDigestResolver struct {
+	client *auth.Client
+}
+
+func (resolver orasDigestResolver) resolveDigest(ctx context.Context, rawReference string) (string, error) {
+	ref, err := reference.Parse(rawReference)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse reference: %w", err)
+	}
+	if ref.Tag == "" {
+		return "", fmt.Errorf("reference %q has no tag", rawReference)
+	}
+	registry := ref.Registry
+	if registry == reference.DockerHubRegistry {
+		registry = autoupdate.DockerHubHost
+	}
+	repo, err := remote.NewRepository(registry + "/" + ref.Repository)
+	if err != nil {
+		return "", fmt.Errorf("failed to instantiate repository: %w", err)
+	}
+	repo.Client = resolver.client
+	desc, err := repo.Resolve(ctx, ref.Tag)
+	if err != nil {
+		return "", err
+	}
+	return desc.Digest.String(), nil
+}
+
+// verifyMirror checks that the target of each ConfigSync in regsync.yaml,
+// as generated from config.yaml, has the same digest as its source.
+func verifyMirror(ctx context.Context, _ *cli.Command) error {
+	if verifyConcurrency < 1 {
+		return fmt.Errorf("concurrency must be at least 1, got %d", verifyConcurrency)
+	}
+
+	configYaml, err := config.Parse(paths.ConfigYaml)
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", paths.ConfigYaml, err)
+	}
+	credentials, err := autoupdate.NewRegistryCredentials(configYaml.Repositories)
+	if err != nil {
+		return fmt.Errorf("failed to load registry credentials: %w", err)
+	}
+
+	scopedConfigYaml, err := scopeConfig(configYaml, verifyArtifact, verifyRepository)
+	if err != nil {
+		return err
+	}
+	regsyncYaml, err := scopedConfigYaml.ToRegsyncConfig()
+	if err != nil {
+		return err
+	}
+
+	resolver := orasDigestResolver{client: autoupdate.NewAuthClient(credentials)}
+	results := verifySyncs(ctx, resolver, regsyncYaml.Sync, verifyConcurrency)
+	report := newVerifyReport(results)
+	for _, result := range report.Failures {
+		switch result.Status {
+		case verifyStatusMissing:
+			fmt.Printf("%s: missing (source %s)\n", result.Target, result.Source)
+		case verifyStatusMismatch:
+			fmt.Printf("%s: digest %s does not match %s of source %s\n", result.Target, result.TargetDigest, result.SourceDigest, result.Source)
+		default:
+			fmt.Printf("%s: error: %s\n", result.Target, result.Error)
+		}
+	}
+	fmt.Printf("checked %d targets: %d ok, %d missing, %d mismatched, %d errors\n",
+		report.Checked, report.OK, report.Missing, report.Mismatched, report.Errors)
+
+	if verifyReportFile != "" {
+		if err := writeVerifyReport(verifyReportFile, report); err != nil {
+			return fmt.Errorf("failed to write report to %s: %w", verifyReportFile, err)
+		}
+	}
+	if len(report.Failures) > 0 {
+		return fmt.Errorf("%d of %d targets do not match their source", len(report.Failures), report.Checked)
+	}
+
+	return nil
+}
+
+// scopeConfig returns a copy of configYaml that only contains the
+// artifacts whose SourceArtifact is artifact, and the repositories whose
+// BaseUrl is repository. Empty values do not restrict the copy.
+func scopeConfig(configYaml *config.Config, artifact, repository string) (*config.Config, error) {
+	scoped := configYaml.DeepCopy()
+	if artifact != "" {
+		scoped.Artifacts = slices.DeleteFunc(scoped.Artifacts, func(a *config.Artifact) bool {
+			same, err := reference.SameRepository(a.SourceArtifact, artifact)
+			return err != nil || !same
+		})
+		if len(scoped.Artifacts) == 0 {
+			return nil, fmt.Errorf("no artifact with SourceArtifact %q found in %s", artifact, paths.ConfigYaml)
+		}
+	}
+	if repository != "" {
+		scoped.Repositories = slices.DeleteFunc(scoped.Repositories, func(r config.Repository) bool {
+			return r.BaseUrl != repository
+		})
+		if len(scoped.Repositories) == 0 {
+			return nil, fmt.Errorf("no repository with BaseUrl %q found in %s", repository, paths.ConfigYaml)
+		}
+	}
+	return scoped, nil
+}
+
+// verifySyncs compares the target of each of syncs to its source, with at
+// most concurrency sources being checked at once. Each source is resolved
+// only once, no matter how many targets it is synced to. The results are
+// in the same order as syncs.
+func verifySyncs(ctx context.Context, resolver digestResolver, syncs []regsync.ConfigSync, concurrency int) []verifyResult {
+	indexesBySource := map[string][]int{}
+	sources := make([]string, 0)
+	for i, configSync := range syncs {
+		if _, ok := indexesBySource[configSync.Source]; !ok {
+			sources = append(sources, configSync.Source)
+		}
+		indexesBySource[configSync.Source] = append(indexesBySource[configSync.Source], i)
+	}
+
+	results := make([]verifyResult, len(syncs))
+	semaphore := make(chan struct{}, concurrency)
+	wg := sync.WaitGroup{}
+	for _, source := range sources {
+		semaphore <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+
+			sourceDigest, sourceErr := resolver.resolveDigest(ctx, source)
+			for _, i := range indexesBySource[source] {
+				result := verifyResult{
+					Source:       source,
+					Target:       syncs[i].Target,
+					SourceDigest: sourceDigest,
+				}
+				if sourceErr != nil {
+					result.Status = verifyStatusError
+					result.Error = fmt.Sprintf("failed to resolve source: %s", sourceErr)
+					results[i] = result
+					continue
+				}
+				targetDigest, err := resolver.resolveDigest(ctx, syncs[i].Target)
+				result.TargetDigest = targetDigest
+				switch {
+				case errors.Is(err, errdef.ErrNotFound):
+					result.Status = verifyStatusMissing
+				case err != nil:
+					result.Status = verifyStatusError
+					result.Error = fmt.Sprintf("failed to resolve target: %s", err)
+				case targetDigest != sourceDigest:
+					result.Status = verifyStatusMismatch
+				default:
+					result.Status = verifyStatusOK
+				}
+				results[i] = result
+			}
+		}()
+	}
+	wg.Wait()
+
+	return results
+}
+
+func newVerifyReport(results []verifyResult) verifyReport {
+	report := verifyReport{
+		Checked:  len(results),
+		Failures: make([]verifyResult, 0),
+	}
+	for _, result := range results {
+		switch result.Status {
+		case verifyStatusOK:
+			report.OK++
+			continue
+		case verifyStatusMissing:
+			report.Missing++
+		case verifyStatusMismatch:
+			report.Mismatched++
+		default:
+			report.Errors++
+		}
+		report.Failures = append(report.Failures, result)
+	}
+	return report
+}
+
+func writeVerifyReport(fileName string, report verifyReport) error {
+	contents, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal: %w", err)
+	}
+	if err := os.WriteFile(fileName, append(contents, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/rancher/artifact-mirror/internal/config"
+	"github.com/rancher/artifact-mirror/internal/regsync"
+
+	"github.com/stretchr/testify/assert"
+	"oras.land/oras-go/v2/errdef"
+)
+
+// fakeDigestResolver resolves references to the digests in digests, and
+// to errdef.ErrNotFound if they are not present.
+type fakeDigestResolver struct {
+	digests map[string]string
+	errs    map[string]error
+
+	lock     sync.Mutex
+	resolved map[string]int
+}
+
+func (resolver *fakeDigestResolver) resolveDigest(_ context.Context, rawReference string) (string, error) {
+	resolver.lock.Lock()
+	defer resolver.lock.Unlock()
+	if resolver.resolved == nil {
+		resolver.resolved = map[string]int{}
+	}
+	resolver.resolved[rawReference]++
+	if err, ok := resolver.errs[rawReference]; ok {
+		return "", err
+	}
+	digest, ok := resolver.digests[rawReference]
+	if !ok {
+		return "", fmt.Errorf("%s: %w", rawReference, errdef.ErrNotFound)
+	}
+	return digest, nil
+}
+
+func TestVerifySyncs(t *testing.T) {
+	resolver := &fakeDigestResolver{
+		digests: map[string]string{
+			"rancher/rancher:v2.12.0":                                       "sha256:aaa",
+			"docker.io/rancher/mirrored-rancher-rancher:v2.12.0":            "sha256:aaa",
+			"registry.rancher.com/rancher/mirrored-rancher-rancher:v2.12.0": "sha256:aaa",
+			"rancher/rancher:v2.12.1":                                       "sha256:bbb",
+			"docker.io/rancher/mirrored-rancher-rancher:v2.12.1":            "sha256:ccc",
+		},
+		errs: map[string]error{
+			"rancher/rancher:v2.12.2": errors.New("unauthorized"),
+			"registry.rancher.com/rancher/mirrored-rancher-rancher:v2.12.1": errors.New("connection reset"),
+		},
+	}
+	syncs := []regsync.ConfigSync{
+		{Source: "rancher/rancher:v2.12.0", Target: "docker.io/rancher/mirrored-rancher-rancher:v2.12.0"},
+		{Source: "rancher/rancher:v2.12.1", Target: "docker.io/rancher/mirrored-rancher-rancher:v2.12.1"},
+		{Source: "rancher/rancher:v2.12.2", Target: "docker.io/rancher/mirrored-rancher-rancher:v2.12.2"},
+		{Source: "rancher/rancher:v2.12.0", Target: "registry.rancher.com/rancher/mirrored-rancher-rancher:v2.12.0"},
+		{Source: "rancher/rancher:v2.12.1", Target: "registry.rancher.com/rancher/mirrored-rancher-rancher:v2.12.1"},
+		{Source: "rancher/rancher:v2.12.3", Target: "docker.io/rancher/mirrored-rancher-rancher:v2.12.3"},
+	}
+
+	results := verifySyncs(t.Context(), resolver, syncs, 2)
+	expected := []verifyResult{
+		{
+			Source:       "rancher/rancher:v2.12.0",
+			Target:       "docker.io/rancher/mirrored-rancher-rancher:v2.12.0",
+			Status:       verifyStatusOK,
+			SourceDigest: "sha256:aaa",
+			TargetDigest: "sha256:aaa",
+		},
+		{
+			Source:       "rancher/rancher:v2.12.1",
+			Target:       "docker.io/rancher/mirrored-rancher-rancher:v2.12.1",
+			Status:       verifyStatusMismatch,
+			SourceDigest: "sha256:bbb",
+			TargetDigest: "sha256:ccc",
+		},
+		{
+			Source: "rancher/rancher:v2.12.2",
+			Target: "docker.io/rancher/mirrored-rancher-rancher:v2.12.2",
+			Status: verifyStatusError,
+			Error:  "failed to resolve source: unauthorized",
+		},
+		{
+			Source:       "rancher/rancher:v2.12.0",
+			Target:       "registry.rancher.com/rancher/mirrored-rancher-rancher:v2.12.0",
+			Status:       verifyStatusOK,
+			SourceDigest: "sha256:aaa",
+			TargetDigest: "sha256:aaa",
+		},
+		{
+			Source:       "rancher/rancher:v2.12.1",
+			Target:       "registry.rancher.com/rancher/mirrored-rancher-rancher:v2.12.1",
+			Status:       verifyStatusError,
+			SourceDigest: "sha256:bbb",
+			Error:        "failed to resolve target: connection reset",
+		},
+		{
+			Source: "rancher/rancher:v2.12.3",
+			Target: "docker.io/rancher/mirrored-rancher-rancher:v2.12.3",
+			Status: verifyStatusError,
+			Error:  "failed to resolve source: rancher/rancher:v2.12.3: not found",
+		},
+	}
+	assert.Equal(t, expected, results)
+	assert.Equal(t, 1, resolver.resolved["rancher/rancher:v2.12.0"], "each source should be resolved once")
+	assert.Equal(t, 1, resolver.resolved["rancher/rancher:v2.12.1"], "each source should be resolved once")
+
+	report := newVerifyReport(results)
+	assert.Equal(t, 6, report.Checked)
+	assert.Equal(t, 2, report.OK)
+	assert.Equal(t, 0, report.Missing)
+	assert.Equal(t, 1, report.Mismatched)
+	assert.Equal(t, 3, report.Errors)
+	assert.Len(t, report.Failures, 4)
+
+	t.Run("should report missing targets", func(t *testing.T) {
+		syncs := []regsync.ConfigSync{
+			{Source: "rancher/rancher:v2.12.0", Target: "docker.io/rancher/mirrored-rancher-rancher:v2.12.9"},
+		}
+		results := verifySyncs(t.Context(), resolver, syncs, 1)
+		assert.Equal(t, verifyStatusMissing, results[0].Status)
+		assert.Empty(t, results[0].Error)
+		assert.Equal(t, 1, newVerifyReport(results).Missing)
+	})
+}
+
+func TestScopeConfig(t *testing.T) {
+	configYaml := &config.Config{
+		Artifacts: []*config.Artifact{
+			createArtifact(t, "rancher/rancher", []string{"v2.12.0"}, ""),
+			createArtifact(t, "library/nginx", []string{"1.29.0"}, ""),
+		},
+		Repositories: []config.Repository{
+			{BaseUrl: "docker.io/rancher", DefaultTarget: true},
+			{BaseUrl: "registry.rancher.com/rancher", DefaultTarget: true},
+		},
+	}
+
+	t.Run("should not change the config without scope", func(t *testing.T) {
+		scoped, err := scopeConfig(configYaml, "", "")
+		assert.NoError(t, err)
+		assert.Equal(t, configYaml, scoped)
+	})
+
+	t.Run("should scope to artifacts with a normalized SourceArtifact", func(t *testing.T) {
+		scoped, err := scopeConfig(configYaml, "docker.io/library/nginx", "")
+		assert.NoError(t, err)
+		assert.Len(t, scoped.Artifacts, 1)
+		assert.Equal(t, "library/nginx", scoped.Artifacts[0].SourceArtifact)
+		assert.Len(t, scoped.Repositories, 2)
+		assert.Len(t, configYaml.Artifacts, 2, "the original config should not be modified")
+	})
+
+	t.Run("should scope to a repository", func(t *testing.T) {
+		scoped, err := scopeConfig(configYaml, "", "registry.rancher.com/rancher")
+		assert.NoError(t, err)
+		assert.Len(t, scoped.Artifacts, 2)
+		regsyncYaml, err := scoped.ToRegsyncConfig()
+		assert.NoError(t, err)
+		for _, configSync := range regsyncYaml.Sync {
+			assert.Regexp(t, `^registry\.rancher\.com/rancher/`, configSync.Target)
+		}
+		assert.Len(t, regsyncYaml.Sync, 2)
+	})
+
+	t.Run("should return an error for an unknown artifact", func(t *testing.T) {
+		_, err := scopeConfig(configYaml, "rancher/unknown", "")
+		assert.ErrorContains(t, err, `no artifact with SourceArtifact "rancher/unknown" found`)
+	})
+
+	t.Run("should return an error for an unknown repository", func(t *testing.T) {
+		_, err := scopeConfig(configYaml, "", "quay.io/rancher")
+		assert.ErrorContains(t, err, `no repository with BaseUrl "quay.io/rancher" found`)
+	})
+}